@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -31,21 +33,607 @@ type NetworkInterfaceSpec struct {
 	// Address is the address of the interface
 	// deprecated
 	Address string `json:"address,omitempty"`
+
+	// AddressFrom references the key of a Secret or of a ConfigMap holding the address of the interface,
+	// in CIDR notation, which the controller sets in the ResolvedAddress of the status.
+	// Address is ignored when it is set. Only supported on PrivateNetworks without IPAM
+	// +optional
+	AddressFrom *AddressSource `json:"addressFrom,omitempty"`
+
+	// AddressFamilyPreference is the address family preferred for the node outbound connections through this interface
+	// The address family of a connection is chosen node-wide by the resolver (RFC 6724, gai.conf) and can't be tuned per interface,
+	// so this only sets the interface address as the preferred source of the default route of this family
+	// +optional
+	AddressFamilyPreference AddressFamily `json:"addressFamilyPreference,omitempty"`
+
+	// Gateway configures the default routes of the interface
+	// +optional
+	Gateway *Gateway `json:"gateway,omitempty"`
+
+	// LinkMAC is the mac address to set on the interface, instead of the one assigned by Scaleway
+	// The interface is still matched with the metadata using Status.MacAddress, the original mac address
+	// is set back when the interface is torn down
+	// +optional
+	// +kubebuilder:validation:Pattern=`^([0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}$`
+	LinkMAC string `json:"linkMac,omitempty"`
+
+	// IngressRateLimit polices the inbound bandwidth of the interface, dropping the packets above the limit
+	// +optional
+	IngressRateLimit *RateLimit `json:"ingressRateLimit,omitempty"`
+
+	// Suspend cuts the traffic of the interface while true, without tearing it down
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// SuspendMode is how the traffic of the interface is cut while suspended, defaults to RouteFlush
+	// RouteFlush removes the routes of the interface, LinkDown also brings it down
+	// +optional
+	SuspendMode SuspendMode `json:"suspendMode,omitempty"`
+
+	// ClampMSS clamps the MSS of the TCP connections forwarded through the interface,
+	// to avoid hanging connections when the path MTU is lower than the one of the interface
+	// +optional
+	ClampMSS *MSSClamping `json:"clampMSS,omitempty"`
+
+	// SNAT masquerades the traffic of a source range leaving through the interface, for the NAT gateway nodes
+	// +optional
+	SNAT *SNAT `json:"snat,omitempty"`
+
+	// AcceptRA is how the IPv6 router advertisements received on the interface are handled,
+	// the node defaults are left untouched when unset
+	// +optional
+	AcceptRA AcceptRAMode `json:"acceptRA,omitempty"`
+
+	// AcceptLocal accepts the IPv4 packets received on the interface with a local source address,
+	// the node default is used when false
+	// +optional
+	AcceptLocal bool `json:"acceptLocal,omitempty"`
+
+	// RouteLocalnet allows routing the 127.0.0.0/8 addresses through the interface, exposing
+	// the services listening on the loopback to the private network, the node default is used when false
+	// +optional
+	RouteLocalnet bool `json:"routeLocalnet,omitempty"`
+
+	// Promiscuous sets the interface in promiscuous mode, for passive network monitoring. The node then processes
+	// every frame received on the interface, addressed to it or not, which costs CPU on busy private networks,
+	// so it is only set when explicitly enabled and cleared on teardown
+	// +optional
+	Promiscuous bool `json:"promiscuous,omitempty"`
+
+	// Neighbor tunes the neighbor table parameters of the interface, for ARP and NDP, to handle peers slow
+	// to answer. The node defaults are used for the unset ones, and set back on teardown
+	// +optional
+	Neighbor *NeighborSettings `json:"neighbor,omitempty"`
+
+	// UpdateNodeInternalIP adds the IPv4 address of the interface as the first InternalIP address in the status
+	// of the node, and removes it on teardown, so that the private address is used as the node address
+	// +optional
+	UpdateNodeInternalIP bool `json:"updateNodeInternalIP,omitempty"`
+
+	// TeardownGracePeriod is how long the link, addresses and routes of the interface are kept after
+	// the NetworkInterface is deleted, letting the connections drain before it is torn down
+	// +optional
+	TeardownGracePeriod *metav1.Duration `json:"teardownGracePeriod,omitempty"`
+
+	// TeardownPolicy is what is removed from the link when the NetworkInterface is deleted, defaults to DeleteManaged
+	// FlushConfig removes its routes, addresses and settings, DeleteManaged also deletes the VRF created for it
+	// +optional
+	TeardownPolicy TeardownPolicy `json:"teardownPolicy,omitempty"`
+
+	// TeardownLinkDown is whether the link is brought down when the NetworkInterface is deleted,
+	// defaults to Never with the FlushConfig teardown policy and to IfBroughtUp otherwise
+	// +optional
+	TeardownLinkDown TeardownLinkDown `json:"teardownLinkDown,omitempty"`
+}
+
+// AddressSource references the key holding an address, only one of its fields can be set
+type AddressSource struct {
+	// SecretKeyRef selects a key of a Secret
+	// +optional
+	SecretKeyRef *ObjectKeySelector `json:"secretKeyRef,omitempty"`
+
+	// ConfigMapKeyRef selects a key of a ConfigMap
+	// +optional
+	ConfigMapKeyRef *ObjectKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// ObjectKeySelector selects a key of a namespaced object
+type ObjectKeySelector struct {
+	// Namespace is the namespace of the object
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the object
+	Name string `json:"name"`
+
+	// Key is the key holding the value
+	Key string `json:"key"`
+}
+
+// Gateway configures the default routes of a NetworkInterface
+type Gateway struct {
+	// PreferredSource is the address of the interface set as the source of its default route of the same family,
+	// taking precedence over AddressFamilyPreference
+	// The address needs to be configured on the interface, otherwise the routes are not synced
+	// +optional
+	PreferredSource string `json:"preferredSource,omitempty"`
+}
+
+// MSSClamping defines the clamping of the MSS of TCP connections
+type MSSClamping struct {
+	// MSS is the value the MSS is clamped to, defaults to the path MTU
+	// +optional
+	// +kubebuilder:validation:Minimum=536
+	// +kubebuilder:validation:Maximum=65495
+	MSS int32 `json:"mss,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=iptables;nftables
+// SNATBackend is the firewall a masquerade rule is installed with
+type SNATBackend string
+
+const (
+	// SNATBackendIPTables installs the masquerade rule with iptables
+	SNATBackendIPTables SNATBackend = "iptables"
+	// SNATBackendNFTables installs the masquerade rule with nftables
+	SNATBackendNFTables SNATBackend = "nftables"
+)
+
+// SNAT defines the masquerading of the traffic of a source range leaving through an interface
+type SNAT struct {
+	// SourceCIDR is the source range of the masqueraded traffic
+	SourceCIDR string `json:"sourceCIDR"`
+
+	// Backend is the firewall the masquerade rule is installed with, defaults to iptables
+	// +optional
+	Backend SNATBackend `json:"backend,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=RouteFlush;LinkDown
+// SuspendMode is how the traffic of a suspended NetworkInterface is cut
+type SuspendMode string
+
+const (
+	// SuspendModeRouteFlush removes the routes of the interface, keeping it up with its addresses
+	SuspendModeRouteFlush SuspendMode = "RouteFlush"
+	// SuspendModeLinkDown removes the routes of the interface and brings it down
+	SuspendModeLinkDown SuspendMode = "LinkDown"
+)
+
+// +kubebuilder:validation:Enum=FlushConfig;DeleteManaged
+// TeardownPolicy is what is removed from the link of a deleted NetworkInterface
+type TeardownPolicy string
+
+const (
+	// TeardownPolicyFlushConfig removes the routes, addresses and settings of the interface
+	TeardownPolicyFlushConfig TeardownPolicy = "FlushConfig"
+	// TeardownPolicyDeleteManaged removes everything the node daemon manages on the interface.
+	// The interface itself is a NIC of the instance, it is never deleted
+	TeardownPolicyDeleteManaged TeardownPolicy = "DeleteManaged"
+)
+
+// +kubebuilder:validation:Enum=Always;Never;IfBroughtUp
+// TeardownLinkDown is whether the link of a deleted NetworkInterface is brought down
+type TeardownLinkDown string
+
+const (
+	// TeardownLinkDownAlways brings the interface down
+	TeardownLinkDownAlways TeardownLinkDown = "Always"
+	// TeardownLinkDownNever leaves the interface up
+	TeardownLinkDownNever TeardownLinkDown = "Never"
+	// TeardownLinkDownIfBroughtUp brings the interface down only if the node daemon brought it up
+	TeardownLinkDownIfBroughtUp TeardownLinkDown = "IfBroughtUp"
+)
+
+// +kubebuilder:validation:Enum=Off;On;RouteOnly;NoDefaultRoute
+// AcceptRAMode is how the IPv6 router advertisements received on an interface are handled
+type AcceptRAMode string
+
+const (
+	// AcceptRAOff ignores the router advertisements, removing the addresses configured from them
+	AcceptRAOff AcceptRAMode = "Off"
+	// AcceptRAOn configures addresses and routes from the router advertisements
+	AcceptRAOn AcceptRAMode = "On"
+	// AcceptRARouteOnly only learns the routes from the router advertisements, removing the addresses configured from them
+	AcceptRARouteOnly AcceptRAMode = "RouteOnly"
+	// AcceptRANoDefaultRoute configures addresses and routes from the router advertisements except the default route,
+	// leaving the IPv6 default route to the static routes
+	AcceptRANoDefaultRoute AcceptRAMode = "NoDefaultRoute"
+)
+
+// RateLimit defines a bandwidth limit
+type RateLimit struct {
+	// Rate is the bandwidth limit, in bits per second
+	Rate resource.Quantity `json:"rate"`
+
+	// Burst is the amount of bytes allowed above the rate at once
+	// Defaults to the amount of bytes sent in 10ms at the given rate, with a minimum of 1500 bytes
+	// +optional
+	Burst *resource.Quantity `json:"burst,omitempty"`
 }
 
+// NeighborSettings defines the neighbor table parameters of an interface
+type NeighborSettings struct {
+	// BaseReachableTimeMs is the base time, in milliseconds, a neighbor is considered reachable
+	// after its reachability is confirmed
+	// +optional
+	// +kubebuilder:validation:Minimum=1000
+	// +kubebuilder:validation:Maximum=3600000
+	BaseReachableTimeMs int32 `json:"baseReachableTimeMs,omitempty"`
+
+	// GCStaleTimeSeconds is the time, in seconds, after which an unused stale neighbor entry can be removed
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	GCStaleTimeSeconds int32 `json:"gcStaleTimeSeconds,omitempty"`
+
+	// MCastSolicit is the number of multicast, or broadcast for ARP, solicitations sent to resolve a neighbor
+	// before it is marked unreachable
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=255
+	MCastSolicit int32 `json:"mcastSolicit,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=IPv4;IPv6
+// AddressFamily represents an IP address family
+type AddressFamily string
+
+const (
+	// AddressFamilyIPv4 represents the IPv4 address family
+	AddressFamilyIPv4 AddressFamily = "IPv4"
+	// AddressFamilyIPv6 represents the IPv6 address family
+	AddressFamilyIPv6 AddressFamily = "IPv6"
+)
+
 // NetworkInterfaceStatus defines the observed state of NetworkInterface
 type NetworkInterfaceStatus struct {
-	// LinkName is the name of the Interface
+	// LinkName is the name of the Interface, as named by the kernel
 	LinkName string `json:"linkName"`
 
+	// LinkAlias is the stable identifier of the interface rendered from the link alias template of the node daemon,
+	// and set as the alias of the interface
+	// +optional
+	LinkAlias string `json:"linkAlias,omitempty"`
+
 	// MacAddress is the mac address of the interface
 	MacAddress string `json:"macAddress"`
 
+	// LinkMAC is the mac address currently set on the interface, when it differs from MacAddress
+	// +optional
+	LinkMAC string `json:"linkMac,omitempty"`
+
+	// LinkBroughtUp is whether the interface was down before the node daemon brought it up
+	// +optional
+	LinkBroughtUp bool `json:"linkBroughtUp,omitempty"`
+
 	// Address is the address of the interface
 	Address string `json:"address,omitempty"`
 
 	// ParentCIDR is the parent cidr of the Address
 	ParentCIDR string `json:"parentCidr,omitempty"`
+
+	// IPv6Address is the IPv6 address of the interface, on dual-stack private networks
+	// +optional
+	IPv6Address string `json:"ipv6Address,omitempty"`
+
+	// IPv6ParentCIDR is the parent cidr of the IPv6Address
+	// +optional
+	IPv6ParentCIDR string `json:"ipv6ParentCidr,omitempty"`
+
+	// ResolvedAddress is the address referenced by the AddressFrom of the spec, set by the controller
+	// +optional
+	ResolvedAddress string `json:"resolvedAddress,omitempty"`
+
+	// ConfiguredAddresses are the static addresses the node configured on the interface,
+	// the only ones removed from it when they are replaced
+	// +optional
+	ConfiguredAddresses []string `json:"configuredAddresses,omitempty"`
+
+	// IngressRateLimit is the inbound bandwidth limit applied on the interface
+	// +optional
+	IngressRateLimit *RateLimit `json:"ingressRateLimit,omitempty"`
+
+	// IngressQdiscAdded is whether the node daemon added the ingress qdisc of the interface for the IngressRateLimit,
+	// removed with it
+	// +optional
+	IngressQdiscAdded bool `json:"ingressQdiscAdded,omitempty"`
+
+	// DHCPLease is the DHCP lease of the interface, on private networks with the DHCP IPAM
+	// +optional
+	DHCPLease *DHCPLease `json:"dhcpLease,omitempty"`
+
+	// SavedMTU is the MTU of the interface before the MTU of its DHCP lease was applied,
+	// restored when it no longer is
+	// +optional
+	SavedMTU int `json:"savedMTU,omitempty"`
+
+	// Conditions are the conditions of the interface
+	// +optional
+	Conditions []NetworkInterfaceCondition `json:"conditions,omitempty"`
+
+	// SavedDefaultRoutes are the node default routes present before the interface got a default route
+	// They are restored when the interface is torn down
+	// +optional
+	SavedDefaultRoutes []DefaultRoute `json:"savedDefaultRoutes,omitempty"`
+
+	// RouteTable is the routing table holding the routes and looked up by the rules of the interface
+	// +optional
+	RouteTable int32 `json:"routeTable,omitempty"`
+
+	// VRF is the name of the VRF device the interface is enslaved to, if any
+	// +optional
+	VRF string `json:"vrf,omitempty"`
+
+	// SkippedRoutes are the routes not installed because their gateway is unreachable
+	// +optional
+	SkippedRoutes []string `json:"skippedRoutes,omitempty"`
+
+	// DiscardedRoutes are the routes not installed because another route has the same destination,
+	// with the reason they lost
+	// +optional
+	DiscardedRoutes []string `json:"discardedRoutes,omitempty"`
+
+	// BackupRoutes are the backup routes installed because the link of the PrivateNetwork they back up is down
+	// +optional
+	BackupRoutes []string `json:"backupRoutes,omitempty"`
+
+	// AppliedRoutes are the routes programmed on the node by the last reconcile, with their outcome,
+	// bounded to the first MaxAppliedRoutes routes
+	// +optional
+	AppliedRoutes []AppliedRoute `json:"appliedRoutes,omitempty"`
+
+	// OmittedAppliedRoutes is the number of routes programmed on the node left out of AppliedRoutes
+	// +optional
+	OmittedAppliedRoutes int32 `json:"omittedAppliedRoutes,omitempty"`
+
+	// KnownGoodRoutes are the last routes the route health check of the PrivateNetwork passed with,
+	// set back when a change of the routes fails it
+	// +optional
+	KnownGoodRoutes []KnownGoodRoute `json:"knownGoodRoutes,omitempty"`
+
+	// RolledBackRoutes are the routes rolled back after failing the route health check,
+	// not applied again until the routes of the interface change
+	// +optional
+	RolledBackRoutes []string `json:"rolledBackRoutes,omitempty"`
+
+	// SuspendMode is the mode the interface is suspended with, empty when it is not suspended
+	// +optional
+	SuspendMode SuspendMode `json:"suspendMode,omitempty"`
+
+	// AcceptRA is how the IPv6 router advertisements received on the interface are handled,
+	// empty when the node defaults are used
+	// +optional
+	AcceptRA AcceptRAMode `json:"acceptRA,omitempty"`
+
+	// AcceptLocal is whether the IPv4 packets with a local source address are accepted on the interface
+	// +optional
+	AcceptLocal bool `json:"acceptLocal,omitempty"`
+
+	// RouteLocalnet is whether the 127.0.0.0/8 addresses are routed through the interface
+	// +optional
+	RouteLocalnet bool `json:"routeLocalnet,omitempty"`
+
+	// Promiscuous is whether the interface is in promiscuous mode
+	// +optional
+	Promiscuous bool `json:"promiscuous,omitempty"`
+
+	// Neighbor is the effective neighbor table parameters of the interface, set when they are tuned
+	// +optional
+	Neighbor *NeighborSettings `json:"neighbor,omitempty"`
+
+	// NodeInternalIP is the address of the interface added as an InternalIP address of the node
+	// +optional
+	NodeInternalIP string `json:"nodeInternalIP,omitempty"`
+
+	// SNATBackend is the firewall holding the masquerade rule of the interface, if any
+	// +optional
+	SNATBackend SNATBackend `json:"snatBackend,omitempty"`
+}
+
+// DHCPLease is a DHCP lease obtained on the node
+type DHCPLease struct {
+	// Address is the leased address, in CIDR notation
+	Address string `json:"address"`
+
+	// Gateway is the router of the lease
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
+
+	// DNS are the name servers of the lease
+	// +optional
+	DNS []string `json:"dns,omitempty"`
+
+	// MTU is the interface MTU of the lease
+	// +optional
+	MTU int `json:"mtu,omitempty"`
+
+	// Routes are the classless static routes of the lease
+	// +optional
+	Routes []string `json:"routes,omitempty"`
+}
+
+// AppliedRouteResultApplied is the result of a route installed on the node
+const AppliedRouteResultApplied = "Applied"
+
+// AppliedRouteResultSkipped is the result of a route left out since another agent installed a route
+// to the same destination, with the Skip route conflict policy
+const AppliedRouteResultSkipped = "Skipped"
+
+// MaxAppliedRoutes is the maximum number of routes reported in the status of a NetworkInterface
+const MaxAppliedRoutes = 64
+
+// AppliedRoute is a route programmed on the node for a NetworkInterface
+type AppliedRoute struct {
+	// To is the destination of the route
+	To string `json:"to"`
+
+	// Via is the gateway of the route, empty for a connected route
+	Via string `json:"via"`
+
+	// Priority is the priority of the route as set in the kernel
+	Priority int32 `json:"priority"`
+
+	// Table is the routing table of the route, 0 being the main table
+	// +optional
+	Table int32 `json:"table,omitempty"`
+
+	// Result is Applied when the route is installed, Skipped when it is left out for a route of another agent,
+	// or the error it failed to be installed with
+	Result string `json:"result"`
+
+	// LastAppliedTime is the last time the route was installed or replaced on the node,
+	// unset when it was installed before being reported
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+}
+
+// KnownGoodRoute is a route the route health check of a PrivateNetwork passed with
+type KnownGoodRoute struct {
+	// To is the destination of the route
+	To string `json:"to"`
+
+	// Via is the gateway of the route, empty for a connected route
+	// +optional
+	Via string `json:"via,omitempty"`
+
+	// Src is the preferred source address of the route
+	// +optional
+	Src string `json:"src,omitempty"`
+
+	// Metric is the priority of the route
+	// +optional
+	Metric int32 `json:"metric,omitempty"`
+
+	// Protocol is the origin of the route
+	// +optional
+	Protocol int32 `json:"protocol,omitempty"`
+
+	// Pref is the preference of an IPv6 route
+	// +optional
+	Pref string `json:"pref,omitempty"`
+
+	// Realm is the realm of an IPv4 route
+	// +optional
+	Realm int32 `json:"realm,omitempty"`
+}
+
+// DefaultRoute defines a default route of the node
+type DefaultRoute struct {
+	// Family is the address family of the default route
+	// Inferred from Via when empty, on the routes saved by previous versions
+	// +optional
+	Family AddressFamily `json:"family,omitempty"`
+
+	// Via is the gateway of the default route
+	// +optional
+	Via string `json:"via,omitempty"`
+
+	// LinkName is the name of the interface of the default route
+	LinkName string `json:"linkName"`
+}
+
+// NetworkInterfaceConditionType is a type of condition of a NetworkInterface
+type NetworkInterfaceConditionType string
+
+const (
+	// NetworkInterfaceReady means the interface is configured on its node
+	NetworkInterfaceReady NetworkInterfaceConditionType = "Ready"
+	// NetworkInterfacePaused means the reconciliation of the interface is paused
+	NetworkInterfacePaused NetworkInterfaceConditionType = "Paused"
+	// NetworkInterfaceLinkPresent means the link of the interface exists on its node
+	NetworkInterfaceLinkPresent NetworkInterfaceConditionType = "LinkPresent"
+	// NetworkInterfaceLinkUp means the link of the interface is operationally up
+	NetworkInterfaceLinkUp NetworkInterfaceConditionType = "LinkUp"
+	// NetworkInterfaceRoutesConflicting means routes of the interface have the same destination and metric
+	// as routes of another interface of the node
+	NetworkInterfaceRoutesConflicting NetworkInterfaceConditionType = "RoutesConflicting"
+	// NetworkInterfaceGatewaysReachable means the probed route gateways of the interface are reachable
+	NetworkInterfaceGatewaysReachable NetworkInterfaceConditionType = "GatewaysReachable"
+	// NetworkInterfaceSuspended means the traffic of the interface is cut
+	NetworkInterfaceSuspended NetworkInterfaceConditionType = "Suspended"
+	// NetworkInterfaceDuplicateAddress means another host answers for an address of the interface,
+	// which is not added
+	NetworkInterfaceDuplicateAddress NetworkInterfaceConditionType = "DuplicateAddress"
+	// NetworkInterfaceAddressResolved means the address referenced by AddressFrom is set in the ResolvedAddress of the status
+	NetworkInterfaceAddressResolved NetworkInterfaceConditionType = "AddressResolved"
+	// NetworkInterfaceNICAvailable means the private NIC of the interface is reported available
+	// in the metadata of the instance
+	NetworkInterfaceNICAvailable NetworkInterfaceConditionType = "NICAvailable"
+	// NetworkInterfaceMTUChangeHeld means a change of the MTU of the interface is held by the
+	// hold-mtu-change annotation
+	NetworkInterfaceMTUChangeHeld NetworkInterfaceConditionType = "MTUChangeHeld"
+	// NetworkInterfaceSysctlsApplied means the sysctls of the interface are applied, its other settings
+	// being configured even when they are not
+	NetworkInterfaceSysctlsApplied NetworkInterfaceConditionType = "SysctlsApplied"
+	// NetworkInterfaceRoutesRolledBack means a change of the routes of the interface failed the route health check
+	// of its PrivateNetwork and was rolled back to the known good routes, the interface being degraded
+	NetworkInterfaceRoutesRolledBack NetworkInterfaceConditionType = "RoutesRolledBack"
+	// NetworkInterfaceRoutesUnhealthy means the routes of the interface failed the route health check of its
+	// PrivateNetwork with no known good routes to roll back to, and are kept until they pass it
+	NetworkInterfaceRoutesUnhealthy NetworkInterfaceConditionType = "RoutesUnhealthy"
+)
+
+// NetworkInterfaceCondition defines a condition of a NetworkInterface
+type NetworkInterfaceCondition struct {
+	// Type is the type of the condition
+	Type NetworkInterfaceConditionType `json:"type"`
+
+	// Status is the status of the condition, one of True, False or Unknown
+	Status corev1.ConditionStatus `json:"status"`
+
+	// LastTransitionTime is the last time the condition changed status
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Reason is a one-word CamelCase reason for the last transition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human readable message about the last transition
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// GetCondition returns the condition of the given type, or nil if not present
+func (s *NetworkInterfaceStatus) GetCondition(conditionType NetworkInterfaceConditionType) *NetworkInterfaceCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue returns whether the condition of the given type is present and true
+func (s *NetworkInterfaceStatus) IsConditionTrue(conditionType NetworkInterfaceConditionType) bool {
+	condition := s.GetCondition(conditionType)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+// SetCondition sets the condition of the given type, returning whether it changed
+func (s *NetworkInterfaceStatus) SetCondition(conditionType NetworkInterfaceConditionType, status corev1.ConditionStatus, reason, message string) bool {
+	condition := s.GetCondition(conditionType)
+	if condition == nil {
+		s.Conditions = append(s.Conditions, NetworkInterfaceCondition{
+			Type:               conditionType,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		})
+		return true
+	}
+
+	if condition.Status == status && condition.Reason == reason && condition.Message == message {
+		return false
+	}
+	if condition.Status != status {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	condition.Status = status
+	condition.Reason = reason
+	condition.Message = message
+	return true
 }
 
 // +kubebuilder:object:root=true
@@ -55,6 +643,7 @@ type NetworkInterfaceStatus struct {
 // +kubebuilder:printcolumn:name="node name",type="string",JSONPath=".spec.nodeName"
 // +kubebuilder:printcolumn:name="mac address",type="string",JSONPath=".status.macAddress"
 // +kubebuilder:printcolumn:name="link name",type="string",JSONPath=".status.linkName"
+// +kubebuilder:printcolumn:name="link alias",type="string",JSONPath=".status.linkAlias",priority=1
 
 // NetworkInterface is the Schema for the networkinterfaces API
 type NetworkInterface struct {
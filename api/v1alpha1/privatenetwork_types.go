@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -41,15 +43,172 @@ type PrivateNetworkSpec struct {
 	// +kubebuilder:default:=true
 	Masquerade bool `json:"masquerade,omitempty"`
 
+	// RouteMetric is the default metric of the routes of the PrivateNetwork
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	RouteMetric int32 `json:"routeMetric,omitempty"`
+
+	// DefaultRouteMetric is the metric of the default routes of the PrivateNetwork without a Metric
+	// Defaults to the RouteMetric, raised in the main table to 100 above the metric of the node default route
+	// when it is not higher, so that the node default route stays preferred
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	DefaultRouteMetric *int32 `json:"defaultRouteMetric,omitempty"`
+
+	// RouteRealm is the default realm of the IPv4 routes of the PrivateNetwork, for traffic accounting
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=255
+	RouteRealm int32 `json:"routeRealm,omitempty"`
+
+	// RouteConflictPolicy is what is done when a route of the PrivateNetwork is already installed by another agent,
+	// to the same destination at the same metric in the same table, defaults to Replace
+	// Replace overwrites it, Fail leaves it and fails the reconcile, Skip leaves it and skips the route
+	// +optional
+	RouteConflictPolicy RouteConflictPolicy `json:"routeConflictPolicy,omitempty"`
+
+	// RouteTable is the routing table of the routes of the PrivateNetwork, looked up by its Rules
+	// Defaults to the main table
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	RouteTable int32 `json:"routeTable,omitempty"`
+
+	// Rules are the policy routing rules looking up the RouteTable
+	// +optional
+	Rules []PrivateNetworkRule `json:"rules,omitempty"`
+
+	// VRF enslaves the interfaces of the PrivateNetwork to a VRF device using the RouteTable,
+	// isolating their routes from the other interfaces of the node
+	// +optional
+	VRF *PrivateNetworkVRF `json:"vrf,omitempty"`
+
+	// ConnectedRoutesInTable installs the connected routes of the addresses of the interfaces in the RouteTable,
+	// so that all the routes of the interfaces are looked up by its Rules
+	// +optional
+	ConnectedRoutesInTable bool `json:"connectedRoutesInTable,omitempty"`
+
+	// NoPrefixRoute adds the addresses of the interfaces without the connected routes the kernel installs
+	// in the main table, leaving them only in the RouteTable. It needs ConnectedRoutesInTable,
+	// and is not supported with the DHCP IPAM
+	// +optional
+	NoPrefixRoute bool `json:"noPrefixRoute,omitempty"`
+
+	// ProbeGateways represents whether the gateway of every route needs to be reachable
+	// before the route is installed
+	// +optional
+	ProbeGateways bool `json:"probeGateways,omitempty"`
+
+	// RouteHealthCheck checks a target stays reachable from the nodes after a change of their routes,
+	// rolling back to the last routes it was reachable with otherwise
+	// +optional
+	RouteHealthCheck *RouteHealthCheck `json:"routeHealthCheck,omitempty"`
+
 	// CIDR is the CIDR of the PrivateNetwork
 	// deprecated
 	CIDR string `json:"cidr,omitempty"`
 }
 
+// DefaultRouteHealthCheckWindow is how long the target of a RouteHealthCheck is waited for by default
+const DefaultRouteHealthCheckWindow = 10 * time.Second
+
+// MaxRouteHealthCheckWindow is the longest a target of a RouteHealthCheck can be waited for
+const MaxRouteHealthCheckWindow = time.Minute
+
+// RouteHealthCheck defines the reachability check run on a node after a change of the routes of a PrivateNetwork
+type RouteHealthCheck struct {
+	// Target is the address pinged from the node after a change of the routes
+	Target string `json:"target"`
+
+	// Window is how long a reply of the target is waited for, 10s by default and 1m at most
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty"`
+}
+
 // PrivateNetworkRoute defines a route from the PrivateNetwork
 type PrivateNetworkRoute struct {
-	To  string `json:"to"`
+	// To is the destination CIDR of the route, a single address or a /32 or /128 CIDR gives a host route
+	To string `json:"to"`
+	// Via is the gateway of the route, of the same family as To
 	Via string `json:"via"`
+
+	// ProbeGateway represents whether Via needs to be reachable before the route is installed
+	// +optional
+	ProbeGateway bool `json:"probeGateway,omitempty"`
+
+	// Metric is the metric of the route, the route with the lowest metric is preferred when
+	// several PrivateNetworks of a node have a route to the same destination
+	// Defaults to the RouteMetric of the PrivateNetwork
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Metric int32 `json:"metric,omitempty"`
+
+	// Pref is the preference of the route, choosing among routers with a route to the same destination,
+	// defaults to Medium. Only supported on IPv6 routes
+	// +optional
+	Pref RoutePreference `json:"pref,omitempty"`
+
+	// Realm is the realm of the route, for traffic accounting. Defaults to the RouteRealm of the PrivateNetwork,
+	// only supported on IPv4 routes
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=255
+	Realm int32 `json:"realm,omitempty"`
+
+	// BackupFor is the name of another PrivateNetwork, the route is then only installed on the nodes
+	// where the link of the NetworkInterface of this PrivateNetwork is down, and withdrawn when it recovers
+	// +optional
+	BackupFor string `json:"backupFor,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Low;Medium;High
+// RoutePreference is the preference of an IPv6 route
+type RoutePreference string
+
+const (
+	// RoutePreferenceLow is the low IPv6 route preference
+	RoutePreferenceLow RoutePreference = "Low"
+	// RoutePreferenceMedium is the medium IPv6 route preference
+	RoutePreferenceMedium RoutePreference = "Medium"
+	// RoutePreferenceHigh is the high IPv6 route preference
+	RoutePreferenceHigh RoutePreference = "High"
+)
+
+// +kubebuilder:validation:Enum=Replace;Fail;Skip
+// RouteConflictPolicy is what is done with a route installed by another agent in place of a route of a PrivateNetwork
+type RouteConflictPolicy string
+
+const (
+	// RouteConflictPolicyReplace overwrites the route installed by another agent
+	RouteConflictPolicyReplace RouteConflictPolicy = "Replace"
+	// RouteConflictPolicyFail leaves the route installed by another agent and fails the reconcile
+	RouteConflictPolicyFail RouteConflictPolicy = "Fail"
+	// RouteConflictPolicySkip leaves the route installed by another agent and skips the route of the PrivateNetwork
+	RouteConflictPolicySkip RouteConflictPolicy = "Skip"
+)
+
+// PrivateNetworkRule defines a policy routing rule looking up the routes of the PrivateNetwork
+type PrivateNetworkRule struct {
+	// From is the source CIDR matched by the rule
+	// +optional
+	From string `json:"from,omitempty"`
+
+	// To is the destination CIDR matched by the rule
+	// +optional
+	To string `json:"to,omitempty"`
+
+	// Priority orders the rule among the rules of the node, lower first
+	// The local, main and default tables are looked up at priorities 0, 32766 and 32767
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=32765
+	Priority int32 `json:"priority"`
+}
+
+// PrivateNetworkVRF defines the VRF device of a PrivateNetwork
+type PrivateNetworkVRF struct {
+	// Name is the name of the VRF device, created with the RouteTable of the PrivateNetwork when it does not exist
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=15
+	Name string `json:"name"`
 }
 
 // +kubebuilder:validation:Enum=DHCP;Static
@@ -69,16 +228,47 @@ type PrivateNetworkIPAMStatic struct {
 	// AvailableRanges allows to restrict which ranges of addresses should be used when choosing an IP address
 	// Defaults to the whole CIDR
 	AvailableRanges []string `json:"availableRanges,omitempty"`
+	// IPv6CIDR represents the IPv6 CIDR associated to this private network, making it dual-stack
+	// +optional
+	IPv6CIDR string `json:"ipv6Cidr,omitempty"`
+}
+
+// PrivateNetworkIPAMDHCP selects the options of the DHCP leases applied on the nodes, besides the leased address
+type PrivateNetworkIPAMDHCP struct {
+	// ApplyGateway installs a default route via the router of the lease
+	// +optional
+	ApplyGateway bool `json:"applyGateway,omitempty"`
+	// ApplyMTU sets the interface MTU of the lease on the link
+	// +optional
+	ApplyMTU bool `json:"applyMTU,omitempty"`
 }
 
 // PrivateNetworkIPAM defines the IPAM for the PrivateNetwork
 type PrivateNetworkIPAM struct {
 	Type   IPAMType                  `json:"type"`
 	Static *PrivateNetworkIPAMStatic `json:"static,omitempty"`
+	// DHCP selects the options of the leases applied with the DHCP IPAM
+	// +optional
+	DHCP *PrivateNetworkIPAMDHCP `json:"dhcp,omitempty"`
 }
 
 // PrivateNetworkStatus defines the observed state of PrivateNetwork
 type PrivateNetworkStatus struct {
+	// Members is the number of NetworkInterfaces attached to the PrivateNetwork
+	// +optional
+	Members int `json:"members,omitempty"`
+
+	// ReadyMembers is the number of ready NetworkInterfaces attached to the PrivateNetwork
+	// +optional
+	ReadyMembers int `json:"readyMembers,omitempty"`
+
+	// UnhealthyNodes are the nodes with a NetworkInterface of the PrivateNetwork not ready
+	// +optional
+	UnhealthyNodes []string `json:"unhealthyNodes,omitempty"`
+
+	// MemberAddresses are the addresses configured on the NetworkInterfaces of the PrivateNetwork, by node name
+	// +optional
+	MemberAddresses map[string][]string `json:"memberAddresses,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -86,6 +276,8 @@ type PrivateNetworkStatus struct {
 // +kubebuilder:resource:scope=Cluster,shortName=pn;privnet;privatenet;privatenetwork
 // +kubebuilder:printcolumn:name="id",type="string",JSONPath=".spec.id"
 // +kubebuilder:printcolumn:name="ipam type",type="string",JSONPath=".spec.ipam.type"
+// +kubebuilder:printcolumn:name="members",type="integer",JSONPath=".status.members"
+// +kubebuilder:printcolumn:name="ready",type="integer",JSONPath=".status.readyMembers"
 
 // PrivateNetwork is the Schema for the privatenetworks API
 type PrivateNetwork struct {
@@ -21,16 +21,161 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressSource) DeepCopyInto(out *AddressSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(ObjectKeySelector)
+		**out = **in
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(ObjectKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressSource.
+func (in *AddressSource) DeepCopy() *AddressSource {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppliedRoute) DeepCopyInto(out *AppliedRoute) {
+	*out = *in
+	if in.LastAppliedTime != nil {
+		in, out := &in.LastAppliedTime, &out.LastAppliedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppliedRoute.
+func (in *AppliedRoute) DeepCopy() *AppliedRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(AppliedRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DHCPLease) DeepCopyInto(out *DHCPLease) {
+	*out = *in
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DHCPLease.
+func (in *DHCPLease) DeepCopy() *DHCPLease {
+	if in == nil {
+		return nil
+	}
+	out := new(DHCPLease)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DefaultRoute) DeepCopyInto(out *DefaultRoute) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DefaultRoute.
+func (in *DefaultRoute) DeepCopy() *DefaultRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(DefaultRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Gateway) DeepCopyInto(out *Gateway) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Gateway.
+func (in *Gateway) DeepCopy() *Gateway {
+	if in == nil {
+		return nil
+	}
+	out := new(Gateway)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KnownGoodRoute) DeepCopyInto(out *KnownGoodRoute) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KnownGoodRoute.
+func (in *KnownGoodRoute) DeepCopy() *KnownGoodRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(KnownGoodRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MSSClamping) DeepCopyInto(out *MSSClamping) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MSSClamping.
+func (in *MSSClamping) DeepCopy() *MSSClamping {
+	if in == nil {
+		return nil
+	}
+	out := new(MSSClamping)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NeighborSettings) DeepCopyInto(out *NeighborSettings) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NeighborSettings.
+func (in *NeighborSettings) DeepCopy() *NeighborSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(NeighborSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInterface) DeepCopyInto(out *NetworkInterface) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterface.
@@ -51,6 +196,22 @@ func (in *NetworkInterface) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkInterfaceCondition) DeepCopyInto(out *NetworkInterfaceCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterfaceCondition.
+func (in *NetworkInterfaceCondition) DeepCopy() *NetworkInterfaceCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkInterfaceCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInterfaceList) DeepCopyInto(out *NetworkInterfaceList) {
 	*out = *in
@@ -86,6 +247,41 @@ func (in *NetworkInterfaceList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInterfaceSpec) DeepCopyInto(out *NetworkInterfaceSpec) {
 	*out = *in
+	if in.AddressFrom != nil {
+		in, out := &in.AddressFrom, &out.AddressFrom
+		*out = new(AddressSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = new(Gateway)
+		**out = **in
+	}
+	if in.IngressRateLimit != nil {
+		in, out := &in.IngressRateLimit, &out.IngressRateLimit
+		*out = new(RateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClampMSS != nil {
+		in, out := &in.ClampMSS, &out.ClampMSS
+		*out = new(MSSClamping)
+		**out = **in
+	}
+	if in.SNAT != nil {
+		in, out := &in.SNAT, &out.SNAT
+		*out = new(SNAT)
+		**out = **in
+	}
+	if in.Neighbor != nil {
+		in, out := &in.Neighbor, &out.Neighbor
+		*out = new(NeighborSettings)
+		**out = **in
+	}
+	if in.TeardownGracePeriod != nil {
+		in, out := &in.TeardownGracePeriod, &out.TeardownGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterfaceSpec.
@@ -101,6 +297,70 @@ func (in *NetworkInterfaceSpec) DeepCopy() *NetworkInterfaceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInterfaceStatus) DeepCopyInto(out *NetworkInterfaceStatus) {
 	*out = *in
+	if in.ConfiguredAddresses != nil {
+		in, out := &in.ConfiguredAddresses, &out.ConfiguredAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IngressRateLimit != nil {
+		in, out := &in.IngressRateLimit, &out.IngressRateLimit
+		*out = new(RateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DHCPLease != nil {
+		in, out := &in.DHCPLease, &out.DHCPLease
+		*out = new(DHCPLease)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]NetworkInterfaceCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SavedDefaultRoutes != nil {
+		in, out := &in.SavedDefaultRoutes, &out.SavedDefaultRoutes
+		*out = make([]DefaultRoute, len(*in))
+		copy(*out, *in)
+	}
+	if in.SkippedRoutes != nil {
+		in, out := &in.SkippedRoutes, &out.SkippedRoutes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DiscardedRoutes != nil {
+		in, out := &in.DiscardedRoutes, &out.DiscardedRoutes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BackupRoutes != nil {
+		in, out := &in.BackupRoutes, &out.BackupRoutes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AppliedRoutes != nil {
+		in, out := &in.AppliedRoutes, &out.AppliedRoutes
+		*out = make([]AppliedRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KnownGoodRoutes != nil {
+		in, out := &in.KnownGoodRoutes, &out.KnownGoodRoutes
+		*out = make([]KnownGoodRoute, len(*in))
+		copy(*out, *in)
+	}
+	if in.RolledBackRoutes != nil {
+		in, out := &in.RolledBackRoutes, &out.RolledBackRoutes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Neighbor != nil {
+		in, out := &in.Neighbor, &out.Neighbor
+		*out = new(NeighborSettings)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterfaceStatus.
@@ -113,13 +373,28 @@ func (in *NetworkInterfaceStatus) DeepCopy() *NetworkInterfaceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectKeySelector) DeepCopyInto(out *ObjectKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectKeySelector.
+func (in *ObjectKeySelector) DeepCopy() *ObjectKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetwork) DeepCopyInto(out *PrivateNetwork) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetwork.
@@ -148,6 +423,11 @@ func (in *PrivateNetworkIPAM) DeepCopyInto(out *PrivateNetworkIPAM) {
 		*out = new(PrivateNetworkIPAMStatic)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DHCP != nil {
+		in, out := &in.DHCP, &out.DHCP
+		*out = new(PrivateNetworkIPAMDHCP)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkIPAM.
@@ -160,6 +440,21 @@ func (in *PrivateNetworkIPAM) DeepCopy() *PrivateNetworkIPAM {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkIPAMDHCP) DeepCopyInto(out *PrivateNetworkIPAMDHCP) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkIPAMDHCP.
+func (in *PrivateNetworkIPAMDHCP) DeepCopy() *PrivateNetworkIPAMDHCP {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkIPAMDHCP)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkIPAMStatic) DeepCopyInto(out *PrivateNetworkIPAMStatic) {
 	*out = *in
@@ -227,9 +522,29 @@ func (in *PrivateNetworkRoute) DeepCopy() *PrivateNetworkRoute {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkRule) DeepCopyInto(out *PrivateNetworkRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkRule.
+func (in *PrivateNetworkRule) DeepCopy() *PrivateNetworkRule {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkSpec) DeepCopyInto(out *PrivateNetworkSpec) {
 	*out = *in
+	if in.DefaultRouteMetric != nil {
+		in, out := &in.DefaultRouteMetric, &out.DefaultRouteMetric
+		*out = new(int32)
+		**out = **in
+	}
 	if in.IPAM != nil {
 		in, out := &in.IPAM, &out.IPAM
 		*out = new(PrivateNetworkIPAM)
@@ -240,6 +555,21 @@ func (in *PrivateNetworkSpec) DeepCopyInto(out *PrivateNetworkSpec) {
 		*out = make([]PrivateNetworkRoute, len(*in))
 		copy(*out, *in)
 	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]PrivateNetworkRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.RouteHealthCheck != nil {
+		in, out := &in.RouteHealthCheck, &out.RouteHealthCheck
+		*out = new(RouteHealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VRF != nil {
+		in, out := &in.VRF, &out.VRF
+		*out = new(PrivateNetworkVRF)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkSpec.
@@ -255,6 +585,26 @@ func (in *PrivateNetworkSpec) DeepCopy() *PrivateNetworkSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrivateNetworkStatus) DeepCopyInto(out *PrivateNetworkStatus) {
 	*out = *in
+	if in.UnhealthyNodes != nil {
+		in, out := &in.UnhealthyNodes, &out.UnhealthyNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MemberAddresses != nil {
+		in, out := &in.MemberAddresses, &out.MemberAddresses
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkStatus.
@@ -266,3 +616,74 @@ func (in *PrivateNetworkStatus) DeepCopy() *PrivateNetworkStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateNetworkVRF) DeepCopyInto(out *PrivateNetworkVRF) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateNetworkVRF.
+func (in *PrivateNetworkVRF) DeepCopy() *PrivateNetworkVRF {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateNetworkVRF)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+	out.Rate = in.Rate.DeepCopy()
+	if in.Burst != nil {
+		in, out := &in.Burst, &out.Burst
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteHealthCheck) DeepCopyInto(out *RouteHealthCheck) {
+	*out = *in
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteHealthCheck.
+func (in *RouteHealthCheck) DeepCopy() *RouteHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SNAT) DeepCopyInto(out *SNAT) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SNAT.
+func (in *SNAT) DeepCopy() *SNAT {
+	if in == nil {
+		return nil
+	}
+	out := new(SNAT)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"time"
 
@@ -25,6 +27,7 @@ import (
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
 	"github.com/scaleway/scaleway-sdk-go/scw"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -32,6 +35,8 @@ import (
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/controllers"
@@ -57,34 +62,95 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var probeAddr string
 	var enableLeaderElection bool
-	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	var enableWebhooks bool
+	var zone string
+	var region string
+	var privateNetworkSelector string
+	var validateInput string
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "Deprecated: use --metrics-bind-address.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", "",
+		"The address the /healthz and /readyz probe endpoints bind to. Empty to disable.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the admission webhooks. Requires a serving certificate in /tmp/k8s-webhook-server/serving-certs.")
+	flag.StringVar(&zone, "zone", "",
+		"The default Scaleway zone, overriding the scaleway config file and the SCW_DEFAULT_ZONE env variable.")
+	flag.StringVar(&region, "region", "",
+		"The default Scaleway region, overriding the scaleway config file and the SCW_DEFAULT_REGION env variable. Defaults to the region of --zone when it is set.")
+	flag.StringVar(&privateNetworkSelector, "private-network-selector", "",
+		"A label selector of the PrivateNetworks managed by the controller, and of the PrivateNetworks whose NetworkInterfaces it manages, "+
+			"for several controllers to share a cluster. Empty to manage all of them.")
+	flag.StringVar(&validateInput, "validate", "",
+		"Validate the PrivateNetworks and NetworkInterfaces of the given YAML manifests file, - for stdin, as the webhook and the controllers would, "+
+			"and exit without connecting to a cluster, with a non-zero status if any is invalid.")
 	klog.InitFlags(nil)
 	flag.Parse()
 
 	ctrl.SetLogger(klogr.New())
 
+	if validateInput != "" {
+		invalid, err := validateManifests(validateInput, os.Stdout)
+		if err != nil {
+			setupLog.Error(err, "unable to validate manifests")
+			os.Exit(1)
+		}
+		if invalid != 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	var selector labels.Selector
+	if privateNetworkSelector != "" {
+		var err error
+		selector, err = labels.Parse(privateNetworkSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid private network selector")
+			os.Exit(1)
+		}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "be46b6df.scaleway.com",
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "be46b6df.scaleway.com",
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	scwClient, err := scw.NewClient(
-		scw.WithEnv(),
-		scw.WithUserAgent("scaleway-k8s-vpc"),
-	)
+	err = mgr.AddHealthzCheck("ping", healthz.Ping)
+	if err != nil {
+		setupLog.Error(err, "unable to add health check")
+		os.Exit(1)
+	}
+	err = mgr.AddReadyzCheck("ping", healthz.Ping)
+	if err != nil {
+		setupLog.Error(err, "unable to add ready check")
+		os.Exit(1)
+	}
+
+	scwClient, err := newScalewayClient(zone, region)
 	if err != nil {
 		setupLog.Error(err, "unable to init scaleway client")
+		os.Exit(1)
+	}
+	defaultZone, _ := scwClient.GetDefaultZone()
+	defaultRegion, _ := scwClient.GetDefaultRegion()
+	setupLog.Info("resolved scaleway locality", "zone", defaultZone, "region", defaultRegion)
+	err = validateScalewayClient(scwClient)
+	if err != nil {
+		setupLog.Error(err, "invalid scaleway credentials")
+		os.Exit(1)
 	}
 
 	stopCh := ctrl.SetupSignalHandler()
@@ -115,6 +181,9 @@ func main() {
 		IPAM:        ipam,
 		InstanceAPI: instance.NewAPI(scwClient),
 		VpcAPI:      vpc.NewAPI(scwClient),
+
+		PrivateNetworkSelector: selector,
+		Recorder:               mgr.GetEventRecorderFor("scaleway-k8s-vpc-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PrivateNetwork")
 		os.Exit(1)
@@ -125,10 +194,20 @@ func main() {
 		Scheme:      mgr.GetScheme(),
 		IPAM:        ipam,
 		InstanceAPI: instance.NewAPI(scwClient),
+		APIReader:   mgr.GetAPIReader(),
+
+		PrivateNetworkSelector: selector,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkInterface")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		mgr.GetWebhookServer().Register(controllers.PrivateNetworkValidatorPath, &webhook.Admission{
+			Handler: &controllers.PrivateNetworkValidator{
+				Client: mgr.GetClient(),
+			},
+		})
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -137,3 +216,74 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newScalewayClient creates a scaleway client from the active profile of the scaleway config file, if any,
+// overridden by the SCW_* env variables, then by the given zone and region if not empty
+func newScalewayClient(zone, region string) (*scw.Client, error) {
+	opts := []scw.ClientOption{}
+
+	config, err := scw.LoadConfig()
+	if err != nil {
+		var notFoundErr *scw.ConfigFileNotFoundError
+		if !errors.As(err, &notFoundErr) {
+			return nil, err
+		}
+	} else {
+		profile, err := config.GetActiveProfile()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, scw.WithProfile(profile))
+	}
+
+	opts = append(opts,
+		scw.WithEnv(),
+		scw.WithUserAgent("scaleway-k8s-vpc"),
+	)
+
+	if zone != "" {
+		parsedZone, err := scw.ParseZone(zone)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, scw.WithDefaultZone(parsedZone))
+		if region == "" {
+			zoneRegion, err := parsedZone.Region()
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, scw.WithDefaultRegion(zoneRegion))
+		}
+	}
+	if region != "" {
+		parsedRegion, err := scw.ParseRegion(region)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, scw.WithDefaultRegion(parsedRegion))
+	}
+
+	return scw.NewClient(opts...)
+}
+
+// validateScalewayClient checks the credentials of the client with an API call
+func validateScalewayClient(scwClient *scw.Client) error {
+	if _, ok := scwClient.GetAccessKey(); !ok {
+		return fmt.Errorf("no access key found in the scaleway config file or the SCW_ACCESS_KEY env variable")
+	}
+	if _, ok := scwClient.GetSecretKey(); !ok {
+		return fmt.Errorf("no secret key found in the scaleway config file or the SCW_SECRET_KEY env variable")
+	}
+
+	zone, ok := scwClient.GetDefaultZone()
+	if !ok {
+		setupLog.Info("no default zone found in the scaleway config file or the SCW_DEFAULT_ZONE env variable, the zone of all PrivateNetworks needs to be set")
+		zone = scw.ZoneFrPar1
+	}
+
+	_, err := vpc.NewAPI(scwClient).ListPrivateNetworks(&vpc.ListPrivateNetworksRequest{
+		Zone:     zone,
+		PageSize: scw.Uint32Ptr(1),
+	})
+	return err
+}
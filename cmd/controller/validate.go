@@ -0,0 +1,133 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/resources"
+)
+
+// validateManifests validates the PrivateNetworks and NetworkInterfaces of the YAML manifests of the input,
+// - for stdin, as the validating webhook and the controllers would, without a cluster. The other objects are
+// ignored. It writes the errors found to w and returns their number
+func validateManifests(input string, w io.Writer) (int, error) {
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	pns := []vpcv1alpha1.PrivateNetwork{}
+	nics := []vpcv1alpha1.NetworkInterface{}
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if runtime.IsNotRegisteredError(err) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("unable to decode manifest: %w", err)
+		}
+		switch o := obj.(type) {
+		case *vpcv1alpha1.PrivateNetwork:
+			pns = append(pns, *o)
+		case *vpcv1alpha1.NetworkInterface:
+			nics = append(nics, *o)
+		}
+	}
+
+	invalid := 0
+	report := func(kind, name string, err error) {
+		invalid++
+		fmt.Fprintf(w, "%s %s: %s\n", kind, name, err)
+	}
+
+	pnsByName := make(map[string]*vpcv1alpha1.PrivateNetwork)
+	for i := range pns {
+		pn := &pns[i]
+		pnsByName[pn.Name] = pn
+		err := resources.ValidatePrivateNetwork(pn)
+		if err != nil {
+			report("PrivateNetwork", pn.Name, err)
+			continue
+		}
+		conflicts := resources.RouteTableConflicts(pn, pns)
+		if len(conflicts) != 0 {
+			report("PrivateNetwork", pn.Name, fmt.Errorf("route table %d is also used by private networks %s", pn.Spec.RouteTable, strings.Join(conflicts, ", ")))
+		}
+	}
+
+	// a node has a single NetworkInterface per PrivateNetwork
+	attached := make(map[[2]string]string)
+	for i := range nics {
+		nic := &nics[i]
+		name := nic.Name
+		if name == "" {
+			name = nic.GenerateName
+		}
+		pnName, err := resources.NetworkInterfacePrivateNetwork(nic)
+		if err != nil {
+			report("NetworkInterface", name, err)
+			continue
+		}
+		pn, ok := pnsByName[pnName]
+		if !ok {
+			report("NetworkInterface", name, fmt.Errorf("private network %s not found in the manifests", pnName))
+			continue
+		}
+		err = resources.ValidateNetworkInterface(nic, pn)
+		if err != nil {
+			report("NetworkInterface", name, err)
+			continue
+		}
+		key := [2]string{nic.Spec.NodeName, pnName}
+		if other, ok := attached[key]; ok {
+			report("NetworkInterface", name, fmt.Errorf("node %s is already attached to private network %s by %s", nic.Spec.NodeName, pnName, other))
+			continue
+		}
+		attached[key] = name
+	}
+
+	fmt.Fprintf(w, "validated %d private networks and %d network interfaces, %d errors\n", len(pns), len(nics), invalid)
+	return invalid, nil
+}
@@ -0,0 +1,106 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// nodeState is the desired and actual state of the NetworkInterfaces of a node
+type nodeState struct {
+	NodeName          string                  `json:"nodeName"`
+	Time              time.Time               `json:"time"`
+	NetworkInterfaces []networkInterfaceState `json:"networkInterfaces"`
+}
+
+// networkInterfaceState is the desired and actual state of a NetworkInterface
+type networkInterfaceState struct {
+	Name   string                             `json:"name"`
+	Spec   vpcv1alpha1.NetworkInterfaceSpec   `json:"spec"`
+	Status vpcv1alpha1.NetworkInterfaceStatus `json:"status"`
+	// Link is the live state of the resolved link, nil if it can't be resolved
+	Link *nics.LinkState `json:"link,omitempty"`
+	// Error is the reason the link can't be resolved
+	Error string `json:"error,omitempty"`
+}
+
+// dumpState writes, as JSON, the NetworkInterfaces of the node with the live state of their links
+func dumpState(config *rest.Config, nodeName string, nicsHandler *nics.NICs, output string) error {
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = c.List(context.Background(), nicsList, client.MatchingLabels{
+		constants.NodeLabel: nodeName,
+	})
+	if err != nil {
+		return err
+	}
+
+	state := nodeState{
+		NodeName:          nodeName,
+		Time:              time.Now().UTC(),
+		NetworkInterfaces: []networkInterfaceState{},
+	}
+	for _, nic := range nicsList.Items {
+		if nic.Spec.NodeName != nodeName {
+			continue
+		}
+		nicState := networkInterfaceState{
+			Name:   nic.Name,
+			Spec:   nic.Spec,
+			Status: nic.Status,
+		}
+		// the link is resolved like the agent does, by its original mac, or by the one set on it
+		link, err := nicsHandler.GetLinkState(nic.Status.MacAddress)
+		if nics.IsNotFound(err) && nic.Spec.LinkMAC != "" {
+			link, err = nicsHandler.GetLinkState(nic.Spec.LinkMAC)
+		}
+		if err != nil {
+			nicState.Error = err.Error()
+		}
+		nicState.Link = link
+		state.NetworkInterfaces = append(state.NetworkInterfaces, nicState)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" && output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(state)
+}
@@ -0,0 +1,171 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	"golang.org/x/sys/unix"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// exportManifests writes, as YAML, a PrivateNetwork and a NetworkInterface for each private NIC of the instance,
+// reconstructed from the live addresses and routes of its link, to rebuild the declarative state of a node
+// configured by hand or by other tooling
+func exportManifests(md *instance.Metadata, nodeName string, nicsHandler *nics.NICs, output string) error {
+	serializer := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme, scheme)
+
+	var w io.Writer = os.Stdout
+	if output != "" && output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	first := true
+	for _, pnic := range md.PrivateNICs {
+		link, err := nicsHandler.GetLinkState(pnic.MacAddress)
+		if err != nil {
+			return fmt.Errorf("unable to get link of private nic %s: %w", pnic.ID, err)
+		}
+
+		pn, nic := exportedResources(pnic.PrivateNetworkID, pnic.ID, nodeName, link)
+		for _, obj := range []runtime.Object{pn, nic} {
+			if !first {
+				_, err = io.WriteString(w, "---\n")
+				if err != nil {
+					return err
+				}
+			}
+			first = false
+			err = serializer.Encode(obj, w)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportedResources returns the PrivateNetwork and the NetworkInterface matching the live state of a link:
+// its first global IPv4 address, and its routes via a gateway not added by the kernel or the router advertisements,
+// in the first table other than the main one holding some, used as route table, or in the main table otherwise
+func exportedResources(privateNetworkID, nicID, nodeName string, link *nics.LinkState) (*vpcv1alpha1.PrivateNetwork, *vpcv1alpha1.NetworkInterface) {
+	pnName := exportedName("pn-" + privateNetworkID)
+	pn := &vpcv1alpha1.PrivateNetwork{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: vpcv1alpha1.GroupVersion.String(),
+			Kind:       "PrivateNetwork",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: pnName,
+		},
+		Spec: vpcv1alpha1.PrivateNetworkSpec{
+			ID: privateNetworkID,
+		},
+	}
+
+	nic := &vpcv1alpha1.NetworkInterface{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: vpcv1alpha1.GroupVersion.String(),
+			Kind:       "NetworkInterface",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: exportedName(pnName + "-" + nodeName),
+			Labels: map[string]string{
+				constants.PrivateNetworkLabel: pnName,
+				constants.NodeLabel:           nodeName,
+			},
+		},
+		Spec: vpcv1alpha1.NetworkInterfaceSpec{
+			ID:       nicID,
+			NodeName: nodeName,
+		},
+	}
+
+	for _, address := range link.Addresses {
+		ip, _, err := net.ParseCIDR(address)
+		if err == nil && ip.To4() != nil && ip.IsGlobalUnicast() {
+			nic.Spec.Address = address
+			break
+		}
+	}
+
+	routes := []nics.RouteState{}
+	for _, route := range link.Routes {
+		if route.Via != "" && route.Protocol != unix.RTPROT_KERNEL && route.Protocol != unix.RTPROT_RA && route.Table != unix.RT_TABLE_LOCAL {
+			routes = append(routes, route)
+		}
+	}
+	table := unix.RT_TABLE_MAIN
+	for _, route := range routes {
+		if route.Table != unix.RT_TABLE_MAIN {
+			table = route.Table
+			break
+		}
+	}
+
+	for _, route := range routes {
+		if route.Table != table {
+			continue
+		}
+		to := route.To
+		if to == "default" {
+			to = "0.0.0.0/0"
+			if net.ParseIP(route.Via).To4() == nil {
+				to = "::/0"
+			}
+		}
+		pn.Spec.Routes = append(pn.Spec.Routes, vpcv1alpha1.PrivateNetworkRoute{
+			To:     to,
+			Via:    route.Via,
+			Metric: int32(route.Metric),
+		})
+	}
+	if table != unix.RT_TABLE_MAIN {
+		pn.Spec.RouteTable = int32(table)
+	}
+	sort.SliceStable(pn.Spec.Routes, func(i, j int) bool {
+		return pn.Spec.Routes[i].To < pn.Spec.Routes[j].To
+	})
+	return pn, nic
+}
+
+// exportedName returns the name as a valid resource name
+func exportedName(name string) string {
+	name = strings.ToLower(name)
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		name = name[:validation.DNS1123SubdomainMaxLength]
+	}
+	return strings.TrimRight(name, "-.")
+}
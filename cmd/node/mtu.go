@@ -0,0 +1,42 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/nodes"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// mtuCorrection returns a runnable setting back the MTU applied on the links of the node when it was reset,
+// as after a reboot, without waiting for the reconciles. It runs with the manager, so only under the node lease,
+// and a failure is only logged
+func mtuCorrection(c client.Reader, nodeName string, nicsHandler *nics.NICs) manager.Runnable {
+	return manager.RunnableFunc(func(<-chan struct{}) error {
+		log := ctrl.Log.WithName("startup-mtu")
+		err := nodes.CorrectMTUs(context.Background(), c, nodeName, nicsHandler, log)
+		if err != nil {
+			log.Error(err, "unable to correct the MTU of the links")
+		}
+		return nil
+	})
+}
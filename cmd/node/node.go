@@ -17,19 +17,34 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/nodes"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/bgp"
 	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	// +kubebuilder:scaffold:imports
@@ -51,20 +66,135 @@ func init() {
 
 func main() {
 	var metricsAddr string
-	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	var probeAddr string
+	var requeueInterval time.Duration
+	var resyncInterval time.Duration
+	var disablePrivateNetworkMetricsLabel bool
+	var gobgpAddress string
+	var gobgpPollInterval time.Duration
+	var gobgpHoldTime time.Duration
+	var enableNodeLease bool
+	var nodeLeaseNamespace string
+	var nodeLeaseDuration time.Duration
+	var nodeLeaseRenewDeadline time.Duration
+	var nodeLeaseRetryPeriod time.Duration
+	var dumpStateOutput string
+	var exportOutput string
+	var dadProbeCount int
+	var dadTimeout time.Duration
+	var watchdogThreshold time.Duration
+	var watchdogCrash bool
+	var slowReconcileThreshold time.Duration
+	var netlinkENOBUFSRetries int
+	var netlinkReceiveBufferSize int
+	var netlinkMaxReceiveBufferSize int
+	var replaceAddresses bool
+	var duplicateMACPolicy string
+	var linkUpTimeout time.Duration
+	var waitNICAvailable bool
+	var strictSysctls bool
+	var auditLogOutput string
+	var watchNetlink bool
+	var statusStreamSocket string
+	var runtimeConfigMap string
+	var penaltyBoxThreshold int
+	var penaltyBoxWindow time.Duration
+	var penaltyBoxDelay time.Duration
+	var linkAliasTemplate string
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "Deprecated: use --metrics-bind-address.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", "",
+		"The address the /healthz and /readyz probe endpoints bind to. Empty to disable.")
+	flag.DurationVar(&requeueInterval, "requeue-interval", 0,
+		"The interval after which a successfully reconciled NetworkInterface is reconciled again to correct drift, 0 to disable. "+
+			"Each reconcile reads the objects from the cache and fetches the instance metadata, and only writes to the API server when the status changes.")
+	flag.DurationVar(&resyncInterval, "resync-interval", 0,
+		"The interval at which all the NetworkInterfaces of the node are reconciled, whatever their events, as a safety net against missed events. 0 to disable.")
+	flag.BoolVar(&disablePrivateNetworkMetricsLabel, "disable-private-network-metrics-label", false,
+		"Leave the private_network label of the metrics empty, to bound their cardinality on clusters with many private networks.")
+	flag.StringVar(&gobgpAddress, "gobgp-address", "",
+		"The address of the gRPC API of a local gobgp speaker, whose learned routes are synced on the private networks. Empty to disable.")
+	flag.DurationVar(&gobgpPollInterval, "gobgp-poll-interval", time.Second*10, "The interval between two polls of the gobgp learned routes.")
+	flag.DurationVar(&gobgpHoldTime, "gobgp-hold-time", time.Second*90,
+		"How long the gobgp learned routes are kept when gobgp can't be reached. 0 to keep them until it is reached again.")
+	flag.BoolVar(&enableNodeLease, "enable-node-lease", false,
+		"Hold a per-node Lease while reconciling, so that only one agent configures the links of a node during a rollout.")
+	flag.StringVar(&nodeLeaseNamespace, "node-lease-namespace", "",
+		"The namespace of the node Leases, defaults to the POD_NAMESPACE environment variable.")
+	flag.DurationVar(&nodeLeaseDuration, "node-lease-duration", time.Second*15,
+		"The duration a standby agent waits before taking over a node Lease which is not renewed.")
+	flag.DurationVar(&nodeLeaseRenewDeadline, "node-lease-renew-deadline", time.Second*10,
+		"The duration the active agent retries to renew its node Lease before stepping back.")
+	flag.DurationVar(&nodeLeaseRetryPeriod, "node-lease-retry-period", time.Second*2, "The interval between two node Lease renewals or acquisition attempts.")
+	flag.StringVar(&dumpStateOutput, "dump-state", "",
+		"Write, as JSON, the NetworkInterfaces of the node with the live state of their links to the given file, - for stdout, and exit. "+
+			"Meant to be attached to support requests.")
+	flag.StringVar(&exportOutput, "export", "",
+		"Write, as YAML, a PrivateNetwork and a NetworkInterface for each private NIC of the instance, reconstructed from the live addresses and routes "+
+			"of its link, to the given file, - for stdout, and exit. Meant to adopt a node configured without the operator.")
+	flag.IntVar(&dadProbeCount, "dad-probe-count", 0,
+		"The number of ARP duplicate address detection probes sent with arping before adding a static IPv4 address, 0 to disable.")
+	flag.DurationVar(&dadTimeout, "dad-timeout", time.Second, "The time waited for a reply to the duplicate address detection probes, rounded up to the second.")
+	flag.DurationVar(&watchdogThreshold, "watchdog-threshold", time.Minute*5,
+		"The duration after which a running reconcile is reported as stalled, 0 to disable the watchdog.")
+	flag.BoolVar(&watchdogCrash, "watchdog-crash", false, "Exit when a reconcile is stalled, so that the node daemon is restarted.")
+	flag.DurationVar(&slowReconcileThreshold, "slow-reconcile-threshold", 0,
+		"The duration above which a reconcile is logged with the duration of each of its steps, and counted in the slow_reconcile_total metric. 0 to disable.")
 	klog.InitFlags(nil)
+	flag.IntVar(&netlinkENOBUFSRetries, "netlink-enobufs-retries", nics.DefaultENOBUFSRetries,
+		"The number of retries of the route and rule operations failing because the netlink socket receive buffer overflowed, growing it before each retry.")
+	flag.IntVar(&netlinkReceiveBufferSize, "netlink-receive-buffer-size", 0,
+		"The initial receive buffer size of the netlink socket, in bytes, 0 to keep the kernel default.")
+	flag.IntVar(&netlinkMaxReceiveBufferSize, "netlink-max-receive-buffer-size", nics.DefaultMaxReceiveBufferSize,
+		"The size, in bytes, up to which the netlink socket receive buffer is grown when it overflows.")
+	flag.BoolVar(&replaceAddresses, "replace-addresses", false,
+		"Update in place the static addresses whose attributes, such as their lifetime or prefix route flag, differ, rather than deleting and adding them again, "+
+			"so that an address is never briefly missing.")
+	flag.StringVar(&duplicateMACPolicy, "duplicate-mac-policy", nics.DuplicateMACPickFirst,
+		"How a NetworkInterface whose mac address matches several links of the node is handled, "+
+			"PickFirst to configure the link with the lowest index or Fail to fail its reconcile. A DuplicateMAC event is recorded in both cases.")
+	flag.DurationVar(&linkUpTimeout, "link-up-timeout", 0,
+		"The time waited for a configured link to be operationally up before installing its routes, the NetworkInterface being requeued otherwise. 0 to disable.")
+	flag.BoolVar(&waitNICAvailable, "wait-nic-available", true,
+		"Wait for the private NIC of a NetworkInterface to be reported available in the instance metadata before configuring it. "+
+			"The NICs are configured right away when the metadata does not report their state.")
+	flag.BoolVar(&strictSysctls, "strict-sysctls", false,
+		"Fail the reconcile of a NetworkInterface whose sysctls can't be applied, for instance on nodes where /proc/sys is read-only. "+
+			"By default, the NetworkInterface is configured without them and its SysctlsApplied condition is set to False.")
+	flag.StringVar(&auditLogOutput, "audit-log", "",
+		"Write a JSON audit entry for every change made to the links, addresses, routes and rules of the node to the given file, - for stdout. Empty to disable.")
+	flag.BoolVar(&watchNetlink, "watch-netlink", false,
+		"Reconcile a NetworkInterface as soon as the kernel notifies a change of its link, of its addresses or of the routes installed by the node daemon, "+
+			"instead of waiting for the next --requeue-interval.")
+	flag.StringVar(&linkAliasTemplate, "link-alias-template", "",
+		"A Go template rendering the alias set on the links and reported in the linkAlias status of the NetworkInterfaces, "+
+			"such as pn-{{.Index}} or {{.PrivateNetwork}}-{{.Address}}. Empty to disable.")
+	flag.IntVar(&penaltyBoxThreshold, "penalty-box-threshold", 5,
+		"The number of failed reconciles of a NetworkInterface within --penalty-box-window above which its retries are delayed by --penalty-box-delay, "+
+			"so that it doesn't delay the other NetworkInterfaces. 0 to disable.")
+	flag.DurationVar(&penaltyBoxWindow, "penalty-box-window", time.Minute*2, "The period the failed reconciles of a NetworkInterface are counted over.")
+	flag.DurationVar(&penaltyBoxDelay, "penalty-box-delay", time.Minute, "The delay of the retries of a NetworkInterface failing repeatedly.")
+	flag.StringVar(&runtimeConfigMap, "runtime-config", "",
+		"The namespace/name of a ConfigMap overriding the --requeue-interval, --link-up-timeout, --slow-reconcile-threshold, --dad-probe-count and -v flags, "+
+			"reloaded whenever it changes. Empty to disable.")
+	flag.StringVar(&statusStreamSocket, "status-stream-socket", "",
+		"The path of a Unix socket streaming, as newline-delimited JSON, a record whenever the status of a NetworkInterface of the node materially changes. Empty to disable.")
 	flag.Parse()
 
 	ctrl.SetLogger(klogr.New())
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     false,
-	})
-	if err != nil {
-		setupLog.Error(err, "unable to start manager")
+	var linkAlias *template.Template
+	if linkAliasTemplate != "" {
+		var err error
+		linkAlias, err = nodes.ParseLinkAliasTemplate(linkAliasTemplate)
+		if err != nil {
+			setupLog.Error(err, "invalid link alias template")
+			os.Exit(1)
+		}
+	}
+
+	if duplicateMACPolicy != nics.DuplicateMACPickFirst && duplicateMACPolicy != nics.DuplicateMACFail {
+		setupLog.Error(fmt.Errorf("unknown policy %s", duplicateMACPolicy), "invalid duplicate mac policy")
 		os.Exit(1)
 	}
 
@@ -74,6 +204,7 @@ func main() {
 		setupLog.Error(err, "unable to fetch Scaleway metdata")
 		os.Exit(1)
 	}
+	setupLog.Info("resolved scaleway locality from the instance metadata", "zone", md.Location.ZoneID)
 
 	nodeName := os.Getenv("NODE_NAME")
 	if nodeName == "" {
@@ -86,28 +217,311 @@ func main() {
 		macs = append(macs, pn.MacAddress)
 	}
 
+	primaryIPs := []net.IP{}
+	for _, address := range []string{md.PrivateIP, md.PublicIP.Address, md.IPv6.Address} {
+		if ip := net.ParseIP(address); ip != nil {
+			primaryIPs = append(primaryIPs, ip)
+		}
+	}
+
 	nics, err := nics.NewNICs(macs)
 	if err != nil {
 		setupLog.Error(err, "unable to init nics handler")
 		os.Exit(1)
 	}
+	nics.ENOBUFSRetries = netlinkENOBUFSRetries
+	nics.MaxReceiveBufferSize = netlinkMaxReceiveBufferSize
+	nics.ReplaceAddresses = replaceAddresses
+	nics.DuplicateMACPolicy = duplicateMACPolicy
+	if netlinkReceiveBufferSize != 0 {
+		err = nics.SetReceiveBufferSize(netlinkReceiveBufferSize)
+		if err != nil {
+			setupLog.Error(err, "unable to set the netlink receive buffer size")
+			os.Exit(1)
+		}
+	}
+
+	if exportOutput != "" {
+		err = exportManifests(md, nodeName, nics, exportOutput)
+		if err != nil {
+			setupLog.Error(err, "unable to export manifests")
+			os.Exit(1)
+		}
+		return
+	}
+
+	config := ctrl.GetConfigOrDie()
+	if dumpStateOutput != "" {
+		err = dumpState(config, nodeName, nics, dumpStateOutput)
+		if err != nil {
+			setupLog.Error(err, "unable to dump state")
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = selfCheck(config, nodeName, nics)
+	if err != nil {
+		setupLog.Error(err, "startup self-check failed")
+		os.Exit(1)
+	}
+
+	if auditLogOutput != "" {
+		nics.AuditLog, err = newAuditLogger(auditLogOutput, nodeName)
+		if err != nil {
+			setupLog.Error(err, "unable to open audit log")
+			os.Exit(1)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         false,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	err = mgr.AddHealthzCheck("ping", healthz.Ping)
+	if err != nil {
+		setupLog.Error(err, "unable to add health check")
+		os.Exit(1)
+	}
+	err = mgr.AddReadyzCheck("ping", healthz.Ping)
+	if err != nil {
+		setupLog.Error(err, "unable to add ready check")
+		os.Exit(1)
+	}
+
+	err = mgr.Add(mtuCorrection(mgr.GetAPIReader(), nodeName, nics))
+	if err != nil {
+		setupLog.Error(err, "unable to add startup MTU correction")
+		os.Exit(1)
+	}
+
+	var goBGP *bgp.GoBGP
+	if gobgpAddress != "" {
+		goBGP, err = bgp.NewGoBGP(gobgpAddress, gobgpPollInterval, gobgpHoldTime, ctrl.Log.WithName("gobgp"))
+		if err != nil {
+			setupLog.Error(err, "unable to init gobgp integration")
+			os.Exit(1)
+		}
+		err = mgr.Add(goBGP)
+		if err != nil {
+			setupLog.Error(err, "unable to add gobgp integration")
+			os.Exit(1)
+		}
+	}
+
+	resync := nodes.NewSignalResync(ctrl.Log.WithName("resync"), syscall.SIGUSR1)
+	resync.Interval = resyncInterval
+	err = mgr.Add(resync)
+	if err != nil {
+		setupLog.Error(err, "unable to add resync signal handler")
+		os.Exit(1)
+	}
+
+	var netlinkWatch *nodes.NetlinkWatch
+	if watchNetlink {
+		netlinkWatch = nodes.NewNetlinkWatch(ctrl.Log.WithName("netlink-watch"))
+		err = mgr.Add(netlinkWatch)
+		if err != nil {
+			setupLog.Error(err, "unable to add netlink watch")
+			os.Exit(1)
+		}
+	}
+
+	var watchdog *nodes.Watchdog
+	if watchdogThreshold != 0 {
+		watchdog = nodes.NewWatchdog(watchdogThreshold, watchdogCrash, ctrl.Log.WithName("watchdog"))
+		err = mgr.Add(watchdog)
+		if err != nil {
+			setupLog.Error(err, "unable to add reconcile watchdog")
+			os.Exit(1)
+		}
+	}
+
+	var statusStream *nodes.StatusStream
+	if statusStreamSocket != "" {
+		statusStream = nodes.NewStatusStream(statusStreamSocket, ctrl.Log.WithName("status-stream"))
+		err = mgr.Add(statusStream)
+		if err != nil {
+			setupLog.Error(err, "unable to add status stream")
+			os.Exit(1)
+		}
+	}
+
+	var nicStates nodes.NICStateGetter
+	if waitNICAvailable {
+		nicStates = nodes.NewMetadataNICStates()
+	}
+
+	var runtimeConfig *nodes.RuntimeConfig
+	if runtimeConfigMap != "" {
+		runtimeConfig, err = newRuntimeConfig(config, runtimeConfigMap, nodes.RuntimeSettings{
+			RequeueInterval:        requeueInterval,
+			LinkUpTimeout:          linkUpTimeout,
+			SlowReconcileThreshold: slowReconcileThreshold,
+			DADProbeCount:          dadProbeCount,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to init runtime config")
+			os.Exit(1)
+		}
+		err = mgr.Add(runtimeConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to add runtime config")
+			os.Exit(1)
+		}
+	}
+
+	var penaltyBox *nodes.PenaltyBox
+	if penaltyBoxThreshold != 0 {
+		penaltyBox = nodes.NewPenaltyBox(penaltyBoxThreshold, penaltyBoxWindow, penaltyBoxDelay)
+	}
 
 	if err = (&nodes.NetworkInterfaceReconciler{
-		Client:      mgr.GetClient(),
-		Log:         ctrl.Log.WithName("controllers").WithName("NetworkInterface"),
-		Scheme:      mgr.GetScheme(),
-		MetadataAPI: metadataAPI,
-		NodeName:    nodeName,
-		NICs:        nics,
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("controllers").WithName("NetworkInterface"),
+		Scheme:          mgr.GetScheme(),
+		MetadataAPI:     nodes.NewSingleFlightMetadata(metadataAPI),
+		NodeName:        nodeName,
+		NICs:            nics,
+		APIReader:       mgr.GetAPIReader(),
+		NICStates:       nicStates,
+		RequeueInterval: requeueInterval,
+
+		DisablePrivateNetworkMetricsLabel: disablePrivateNetworkMetricsLabel,
+		GoBGP:                             goBGP,
+		Resync:                            resync,
+		NetlinkWatch:                      netlinkWatch,
+		LinkAliasTemplate:                 linkAlias,
+		PrimaryIPs:                        primaryIPs,
+		Recorder:                          mgr.GetEventRecorderFor("scaleway-k8s-vpc-node"),
+		DADProbeCount:                     dadProbeCount,
+		DADTimeout:                        dadTimeout,
+		LinkUpTimeout:                     linkUpTimeout,
+		StrictSysctls:                     strictSysctls,
+		Watchdog:                          watchdog,
+		StatusStream:                      statusStream,
+		SlowReconcileThreshold:            slowReconcileThreshold,
+		RuntimeConfig:                     runtimeConfig,
+		PenaltyBox:                        penaltyBox,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkInterface")
 		os.Exit(1)
 	}
 	// +kubebuilder:scaffold:builder
 
-	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		setupLog.Error(err, "problem running manager")
+	stop := ctrl.SetupSignalHandler()
+	if !enableNodeLease {
+		setupLog.Info("starting manager")
+		if err := mgr.Start(stop); err != nil {
+			setupLog.Error(err, "problem running manager")
+			os.Exit(1)
+		}
+		return
+	}
+
+	if nodeLeaseNamespace == "" {
+		nodeLeaseNamespace = os.Getenv("POD_NAMESPACE")
+	}
+	if nodeLeaseNamespace == "" {
+		setupLog.Info("node lease namespace not specified, set --node-lease-namespace or the POD_NAMESPACE environment variable")
+		os.Exit(1)
+	}
+
+	lock, err := newNodeLeaseLock(config, nodeLeaseNamespace, nodeName)
+	if err != nil {
+		setupLog.Error(err, "unable to create node lease lock")
 		os.Exit(1)
 	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	setupLog.Info("waiting for node lease", "namespace", nodeLeaseNamespace, "name", lock.LeaseMeta.Name, "identity", lock.Identity())
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: nodeLeaseDuration,
+		RenewDeadline: nodeLeaseRenewDeadline,
+		RetryPeriod:   nodeLeaseRetryPeriod,
+		// release the lease on shutdown, so that the next agent of the rollout does not wait for it to expire
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				setupLog.Info("acquired node lease, starting manager")
+				if err := mgr.Start(ctx.Done()); err != nil {
+					setupLog.Error(err, "problem running manager")
+					os.Exit(1)
+				}
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() != nil {
+					setupLog.Info("released node lease")
+					return
+				}
+				// the manager can't be restarted, the kubelet restarts the agent which waits for the lease again
+				setupLog.Info("lost node lease, stepping back")
+				os.Exit(1)
+			},
+		},
+	})
+}
+
+// newRuntimeConfig returns a RuntimeConfig loading the ConfigMap with the given namespace/name, defaulting
+// to the given settings and to the verbosity of the -v flag
+func newRuntimeConfig(config *rest.Config, configMap string, defaults nodes.RuntimeSettings) (*nodes.RuntimeConfig, error) {
+	parts := strings.Split(configMap, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid runtime config %q, expected namespace/name", configMap)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	verbosity := flag.Lookup("v").Value
+	defaults.LogLevel, err = strconv.Atoi(verbosity.String())
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeConfig := nodes.NewRuntimeConfig(client, parts[0], parts[1], defaults, ctrl.Log.WithName("runtime-config"))
+	runtimeConfig.SetLogLevel = func(level int) error {
+		return verbosity.Set(strconv.Itoa(level))
+	}
+	return runtimeConfig, nil
+}
+
+// newNodeLeaseLock returns the lock of the Lease of the node, identified by the pod name,
+// since all the agents of a node share its hostname
+func newNodeLeaseLock(config *rest.Config, namespace, nodeName string) (*resourcelock.LeaseLock, error) {
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		identity = string(uuid.NewUUID())
+	}
+
+	return &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "scaleway-k8s-vpc-node-" + nodeName,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}, nil
 }
@@ -0,0 +1,52 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// selfCheck verifies, before starting, that the node daemon can manage the links of the node
+// and that its node name matches a Node, so that a missing capability or a wrong node name
+// fail at startup rather than deep in the reconciles
+func selfCheck(config *rest.Config, nodeName string, nicsHandler *nics.NICs) error {
+	err := nicsHandler.CheckAccess()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	err = c.Get(context.Background(), client.ObjectKey{Name: nodeName}, &corev1.Node{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("node %s not found, set the NODE_NAME environment variable to the name of the Node", nodeName)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get node %s: %w", nodeName, err)
+	}
+	return nil
+}
@@ -3,11 +3,51 @@ package controllers
 import (
 	"fmt"
 
+	"github.com/go-logr/logr"
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	"github.com/scaleway/scaleway-sdk-go/scw"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
+// selectsPrivateNetwork returns whether the PrivateNetwork with the given labels is managed by the controller,
+// a nil selector selecting all of them
+func selectsPrivateNetwork(selector labels.Selector, pnLabels map[string]string) bool {
+	return selector == nil || selector.Matches(labels.Set(pnLabels))
+}
+
+// privateNetworkPredicate filters out the events of the PrivateNetworks not selected by the selector
+func privateNetworkPredicate(selector labels.Selector) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return selectsPrivateNetwork(selector, e.Meta.GetLabels())
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return selectsPrivateNetwork(selector, e.MetaNew.GetLabels())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return selectsPrivateNetwork(selector, e.Meta.GetLabels())
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return selectsPrivateNetwork(selector, e.Meta.GetLabels())
+		},
+	}
+}
+
+// requeueOnConflict turns the conflicts on the update of an outdated object into a requeue without backoff,
+// the next reconcile reading the latest version of the object
+func requeueOnConflict(log logr.Logger, res ctrl.Result, err error) (ctrl.Result, error) {
+	if apierrors.IsConflict(err) {
+		log.V(1).Info("object modified concurrently, requeuing", "error", err.Error())
+		return ctrl.Result{RequeueAfter: conflictRequeueDelay}, nil
+	}
+	return res, err
+}
+
 func getServerFromNode(instanceAPI *instance.API, node *corev1.Node) (*instance.Server, error) {
 	instanceID := ""
 	zone := ""
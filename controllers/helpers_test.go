@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Update conflicts", func() {
+	resource := schema.GroupResource{Group: "vpc.scaleway.com", Resource: "networkinterfaces"}
+
+	It("requeues without error on conflict", func() {
+		err := apierrors.NewConflict(resource, "nic", fmt.Errorf("the object has been modified"))
+		res, err := requeueOnConflict(log.NullLogger{}, ctrl.Result{}, err)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(conflictRequeueDelay))
+	})
+
+	It("keeps the other results", func() {
+		res, err := requeueOnConflict(log.NullLogger{}, ctrl.Result{RequeueAfter: time.Second}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(time.Second))
+
+		notFound := apierrors.NewNotFound(resource, "nic")
+		_, err = requeueOnConflict(log.NullLogger{}, ctrl.Result{}, notFound)
+		Expect(err).To(Equal(notFound))
+	})
+})
+
+var _ = Describe("PrivateNetwork selection", func() {
+	It("selects all the private networks without selector", func() {
+		Expect(selectsPrivateNetwork(nil, nil)).To(BeTrue())
+		Expect(selectsPrivateNetwork(nil, map[string]string{"team": "a"})).To(BeTrue())
+	})
+
+	It("filters the private networks events with the selector", func() {
+		selector, err := labels.Parse("team=a")
+		Expect(err).NotTo(HaveOccurred())
+		predicate := privateNetworkPredicate(selector)
+
+		selected := &metav1.ObjectMeta{Labels: map[string]string{"team": "a"}}
+		other := &metav1.ObjectMeta{Labels: map[string]string{"team": "b"}}
+		Expect(predicate.Create(event.CreateEvent{Meta: selected})).To(BeTrue())
+		Expect(predicate.Create(event.CreateEvent{Meta: other})).To(BeFalse())
+		Expect(predicate.Update(event.UpdateEvent{MetaOld: selected, MetaNew: other})).To(BeFalse())
+		Expect(predicate.Delete(event.DeleteEvent{Meta: &metav1.ObjectMeta{}})).To(BeFalse())
+	})
+})
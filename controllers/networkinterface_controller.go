@@ -20,6 +20,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"reflect"
 	"strings"
 	"time"
 
@@ -28,14 +30,22 @@ import (
 	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -50,16 +60,37 @@ type NetworkInterfaceReconciler struct {
 	Scheme      *runtime.Scheme
 	IPAM        goipam.Ipamer
 	InstanceAPI *instance.API
+
+	// PrivateNetworkSelector selects the PrivateNetworks whose NetworkInterfaces are managed by the controller,
+	// nil selects all of them
+	PrivateNetworkSelector labels.Selector
+
+	// APIReader reads the Secrets and ConfigMaps referenced by AddressFrom from the API server,
+	// as the manager only watches their metadata, the Client being used when nil
+	APIReader client.Reader
 }
 
+const (
+	// secretKeyRefIndex indexes the NetworkInterfaces by the namespace/name of the Secret referenced by AddressFrom
+	secretKeyRefIndex = "spec.addressFrom.secretKeyRef"
+	// configMapKeyRefIndex indexes the NetworkInterfaces by the namespace/name of the ConfigMap referenced by AddressFrom
+	configMapKeyRefIndex = "spec.addressFrom.configMapKeyRef"
+)
+
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;update
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;update
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
 
 func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
 	log := r.Log.WithValues("networkinterface", req.NamespacedName)
+	res, err := r.reconcile(log, req)
+	return requeueOnConflict(log, res, err)
+}
+
+func (r *NetworkInterfaceReconciler) reconcile(log logr.Logger, req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
 
 	nic := &vpcv1alpha1.NetworkInterface{}
 
@@ -85,6 +116,11 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		return ctrl.Result{}, err
 	}
 
+	if !selectsPrivateNetwork(r.PrivateNetworkSelector, pn.Labels) {
+		log.V(1).Info("private network not selected, skipping")
+		return ctrl.Result{}, nil
+	}
+
 	if nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
 		if nodeDeleted {
 			err := r.Client.Delete(ctx, nic)
@@ -94,7 +130,21 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 			}
 			return ctrl.Result{RequeueAfter: 1 * time.Second}, nil
 		}
-		if len(nic.Status.Address) == 0 && pn.Spec.IPAM != nil {
+		if nic.Spec.AddressFrom != nil {
+			err := r.syncAddressFrom(ctx, nic, &pn)
+			if err != nil {
+				log.Error(err, "unable to resolve addressFrom")
+				return ctrl.Result{}, err
+			}
+		} else if nic.Status.ResolvedAddress != "" {
+			nic.Status.ResolvedAddress = ""
+			err := r.Client.Status().Update(ctx, nic)
+			if err != nil {
+				log.Error(err, "unable to clear the resolved address")
+				return ctrl.Result{}, err
+			}
+		}
+		if pn.Spec.IPAM != nil {
 			switch pn.Spec.IPAM.Type {
 			case vpcv1alpha1.IPAMTypeDHCP:
 				// this case is handled in the node controller
@@ -102,46 +152,58 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 				if pn.Spec.IPAM.Static == nil {
 					return ctrl.Result{}, fmt.Errorf("Static CIDR can't be empty on static ipam mode")
 				}
-				cidrs := []string{pn.Spec.IPAM.Static.CIDR}
-				if len(pn.Spec.IPAM.Static.AvailableRanges) != 0 {
-					cidrs = pn.Spec.IPAM.Static.AvailableRanges
-				}
+				static := pn.Spec.IPAM.Static
 
-				var ip *goipam.IP
-				var chosenCidr string
+				released := []ipamAddress{}
+				acquired := []ipamAddress{}
 
-				for _, cidr := range cidrs {
-					prefix, err := r.IPAM.NewPrefix(cidr)
-					if err != nil {
-						log.Error(err, "error creating new prefix")
-						continue
+				cidrs := []string{static.CIDR}
+				if len(static.AvailableRanges) != 0 {
+					cidrs = static.AvailableRanges
+				}
+				if addressNeedsAllocation(nic.Status.Address, cidrs) {
+					if nic.Status.Address != "" {
+						released = append(released, ipamAddress{address: nic.Status.Address, cidr: nic.Status.ParentCIDR})
 					}
-					ip, err = r.IPAM.AcquireIP(prefix.Cidr)
+					address, err := r.acquireAddress(log, cidrs, static.CIDR)
 					if err != nil {
-						log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
-						continue
+						log.Error(err, "error while testing all cidrs")
+						return ctrl.Result{RequeueAfter: RequeueDuration}, err
 					}
-					chosenCidr = prefix.Cidr
-					break
+					acquired = append(acquired, address)
+					nic.Status.Address = address.address
+					nic.Status.ParentCIDR = address.cidr
 				}
 
-				if ip == nil {
-					err := fmt.Errorf("could not acquire IP")
-					log.Error(err, "error while testing all cidrs")
-					return ctrl.Result{RequeueAfter: RequeueDuration}, err
+				if static.IPv6CIDR == "" {
+					if nic.Status.IPv6Address != "" {
+						released = append(released, ipamAddress{address: nic.Status.IPv6Address, cidr: nic.Status.IPv6ParentCIDR})
+						nic.Status.IPv6Address = ""
+						nic.Status.IPv6ParentCIDR = ""
+					}
+				} else if addressNeedsAllocation(nic.Status.IPv6Address, []string{static.IPv6CIDR}) {
+					if nic.Status.IPv6Address != "" {
+						released = append(released, ipamAddress{address: nic.Status.IPv6Address, cidr: nic.Status.IPv6ParentCIDR})
+					}
+					address, err := r.acquireAddress(log, []string{static.IPv6CIDR}, static.IPv6CIDR)
+					if err != nil {
+						r.releaseAddresses(log, acquired)
+						log.Error(err, "error while acquiring IPv6 address")
+						return ctrl.Result{RequeueAfter: RequeueDuration}, err
+					}
+					acquired = append(acquired, address)
+					nic.Status.IPv6Address = address.address
+					nic.Status.IPv6ParentCIDR = address.cidr
 				}
 
-				// TODO have a better idea :D
-				nic.Status.Address = ip.IP.String() + "/" + strings.Split(pn.Spec.IPAM.Static.CIDR, "/")[1]
-				nic.Status.ParentCIDR = chosenCidr
-				err = r.Client.Status().Update(ctx, nic)
-				if err != nil {
-					ipamErr := r.IPAM.ReleaseIPFromPrefix(chosenCidr, strings.Split(nic.Status.Address, "/")[0])
-					if ipamErr != nil {
-						log.Error(ipamErr, fmt.Sprintf("failed to release IP %s", nic.Status.Address))
+				if len(acquired) != 0 || len(released) != 0 {
+					err = r.Client.Status().Update(ctx, nic)
+					if err != nil {
+						r.releaseAddresses(log, acquired)
+						log.Error(err, fmt.Sprintf("failed to update networkInterface %s", nic.Name))
+						return ctrl.Result{}, err
 					}
-					log.Error(err, fmt.Sprintf("failed to update networkInterface %s", nic.Name))
-					return ctrl.Result{}, err
+					r.releaseAddresses(log, released)
 				}
 			default:
 				return ctrl.Result{}, fmt.Errorf("IPAM type %s is not supported", pn.Spec.IPAM.Type)
@@ -180,6 +242,15 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 					return ctrl.Result{}, err
 				}
 			}
+			if nic.Status.IPv6Address != "" {
+				err := r.IPAM.ReleaseIPFromPrefix(nic.Status.IPv6ParentCIDR, strings.Split(nic.Status.IPv6Address, "/")[0])
+				if err != nil {
+					if !errors.As(err, &goipam.NotFoundError{}) {
+						log.Error(err, fmt.Sprintf("could not delete IP %s from prefix %s", nic.Status.IPv6Address, nic.Status.IPv6ParentCIDR))
+						return ctrl.Result{}, err
+					}
+				}
+			}
 		}
 		node := corev1.Node{}
 		err := r.Client.Get(ctx, types.NamespacedName{Name: nic.Spec.NodeName}, &node)
@@ -224,9 +295,266 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	return ctrl.Result{}, nil
 }
 
+// ipamAddress is an address acquired from a cidr of the IPAM
+type ipamAddress struct {
+	address string
+	cidr    string
+}
+
+// addressNeedsAllocation returns whether an address needs to be acquired from the cidrs,
+// because there is none yet or because the current one is not part of the cidrs anymore
+func addressNeedsAllocation(address string, cidrs []string) bool {
+	if address == "" {
+		return true
+	}
+	ip, _, err := net.ParseCIDR(address)
+	if err != nil {
+		return true
+	}
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if ipnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// acquireAddress acquires an address in the first of the cidrs with an available one,
+// with the prefix length of the network cidr
+func (r *NetworkInterfaceReconciler) acquireAddress(log logr.Logger, cidrs []string, networkCIDR string) (ipamAddress, error) {
+	for _, cidr := range cidrs {
+		prefix, err := r.IPAM.NewPrefix(cidr)
+		if err != nil {
+			log.Error(err, "error creating new prefix")
+			continue
+		}
+		ip, err := r.IPAM.AcquireIP(prefix.Cidr)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
+			continue
+		}
+
+		// TODO have a better idea :D
+		return ipamAddress{
+			address: ip.IP.String() + "/" + strings.Split(networkCIDR, "/")[1],
+			cidr:    prefix.Cidr,
+		}, nil
+	}
+	return ipamAddress{}, fmt.Errorf("could not acquire IP")
+}
+
+// releaseAddresses releases the addresses from the IPAM, only logging the errors
+func (r *NetworkInterfaceReconciler) releaseAddresses(log logr.Logger, addresses []ipamAddress) {
+	for _, address := range addresses {
+		err := r.IPAM.ReleaseIPFromPrefix(address.cidr, strings.Split(address.address, "/")[0])
+		if err != nil && !errors.As(err, &goipam.NotFoundError{}) {
+			log.Error(err, fmt.Sprintf("failed to release IP %s", address.address))
+		}
+	}
+}
+
+// networkInterfacePredicate filters out the events of the NetworkInterfaces owned by PrivateNetworks
+// not selected by the selector
+func (r *NetworkInterfaceReconciler) networkInterfacePredicate() predicate.Funcs {
+	selects := func(meta metav1.Object) bool {
+		owner := metav1.GetControllerOf(meta)
+		if r.PrivateNetworkSelector == nil || owner == nil {
+			return true
+		}
+		pn := &vpcv1alpha1.PrivateNetwork{}
+		err := r.Client.Get(context.Background(), types.NamespacedName{Name: owner.Name}, pn)
+		if err != nil {
+			// the reconcile reports the missing PrivateNetworks
+			return true
+		}
+		return selectsPrivateNetwork(r.PrivateNetworkSelector, pn.Labels)
+	}
+
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return selects(e.Meta)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return selects(e.MetaNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return selects(e.Meta)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return selects(e.Meta)
+		},
+	}
+}
+
+// errInvalidAddressFrom is returned when the address referenced by AddressFrom can't be resolved
+var errInvalidAddressFrom = errors.New("invalid addressFrom")
+
+// resolveAddressFrom returns the address held by the key referenced by an AddressFrom, as a CIDR.
+// The errors never hold the value of the key, which may be a secret
+func (r *NetworkInterfaceReconciler) resolveAddressFrom(ctx context.Context, from *vpcv1alpha1.AddressSource) (string, error) {
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	var ref *vpcv1alpha1.ObjectKeySelector
+	data := make(map[string]string)
+	switch {
+	case from.SecretKeyRef != nil && from.ConfigMapKeyRef != nil:
+		return "", fmt.Errorf("%w: both a secret and a configmap are referenced", errInvalidAddressFrom)
+	case from.SecretKeyRef != nil:
+		ref = from.SecretKeyRef
+		secret := corev1.Secret{}
+		err := reader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &secret)
+		if err != nil {
+			return "", err
+		}
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+	case from.ConfigMapKeyRef != nil:
+		ref = from.ConfigMapKeyRef
+		configMap := corev1.ConfigMap{}
+		err := reader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &configMap)
+		if err != nil {
+			return "", err
+		}
+		data = configMap.Data
+	default:
+		return "", fmt.Errorf("%w: neither a secret nor a configmap is referenced", errInvalidAddressFrom)
+	}
+
+	address, ok := data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("%w: key %s not found in %s/%s", errInvalidAddressFrom, ref.Key, ref.Namespace, ref.Name)
+	}
+	address = strings.TrimSpace(address)
+	if _, _, err := net.ParseCIDR(address); err != nil {
+		return "", fmt.Errorf("%w: key %s of %s/%s is not an address in CIDR notation", errInvalidAddressFrom, ref.Key, ref.Namespace, ref.Name)
+	}
+	return address, nil
+}
+
+// syncAddressFrom sets the address referenced by the AddressFrom of a NetworkInterface in the ResolvedAddress
+// of its status, reporting in its AddressResolved condition why it could not be resolved
+func (r *NetworkInterfaceReconciler) syncAddressFrom(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, pn *vpcv1alpha1.PrivateNetwork) error {
+	address, err := "", fmt.Errorf("%w: private networks with ipam are not supported", errInvalidAddressFrom)
+	if pn.Spec.IPAM == nil {
+		address, err = r.resolveAddressFrom(ctx, nic.Spec.AddressFrom)
+	}
+	if err != nil {
+		reason := "Invalid"
+		switch {
+		case apierrors.IsNotFound(err):
+			reason = "NotFound"
+		case !errors.Is(err, errInvalidAddressFrom):
+			return err
+		}
+		// the referenced object is watched, the address is resolved again when it is fixed
+		if nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceAddressResolved, corev1.ConditionFalse, reason, err.Error()) {
+			return r.Client.Status().Update(ctx, nic)
+		}
+		return nil
+	}
+
+	changed := nic.Status.ResolvedAddress != address
+	nic.Status.ResolvedAddress = address
+	if nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceAddressResolved, corev1.ConditionTrue, "Resolved", "") || changed {
+		return r.Client.Status().Update(ctx, nic)
+	}
+	return nil
+}
+
+// addressFromIndexer indexes the NetworkInterfaces by the namespace/name of the object
+// selected from their AddressSource with ref
+func addressFromIndexer(ref func(*vpcv1alpha1.AddressSource) *vpcv1alpha1.ObjectKeySelector) client.IndexerFunc {
+	return func(o runtime.Object) []string {
+		nic, ok := o.(*vpcv1alpha1.NetworkInterface)
+		if !ok || nic.Spec.AddressFrom == nil {
+			return nil
+		}
+		selector := ref(nic.Spec.AddressFrom)
+		if selector == nil {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s/%s", selector.Namespace, selector.Name)}
+	}
+}
+
+// addressFromHandler enqueues the NetworkInterfaces whose AddressFrom references the object, looked up in index
+func (r *NetworkInterfaceReconciler) addressFromHandler(index string) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+			err := r.Client.List(context.Background(), nicsList,
+				client.MatchingFields{
+					index: fmt.Sprintf("%s/%s", o.Meta.GetNamespace(), o.Meta.GetName()),
+				},
+			)
+			if err != nil {
+				r.Log.Error(err, fmt.Sprintf("unable to sync nics referencing %s/%s", o.Meta.GetNamespace(), o.Meta.GetName()))
+				return nil
+			}
+			requests := []reconcile.Request{}
+			for _, nic := range nicsList.Items {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Name: nic.Name,
+					},
+				})
+			}
+			return requests
+		}),
+	}
+}
+
+// metadataSource returns a source watching the metadata of the objects of a resource, started with the manager,
+// so that their content isn't cached
+func metadataSource(mgr ctrl.Manager, resource schema.GroupVersionResource) (source.Source, error) {
+	metadataClient, err := metadata.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	factory := metadatainformer.NewSharedInformerFactory(metadataClient, 0)
+	informer := factory.ForResource(resource).Informer()
+	err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		factory.Start(stop)
+		<-stop
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return &source.Informer{Informer: informer}, nil
+}
+
 func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(&vpcv1alpha1.NetworkInterface{}, secretKeyRefIndex, addressFromIndexer(func(from *vpcv1alpha1.AddressSource) *vpcv1alpha1.ObjectKeySelector {
+		return from.SecretKeyRef
+	}))
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(&vpcv1alpha1.NetworkInterface{}, configMapKeyRefIndex, addressFromIndexer(func(from *vpcv1alpha1.AddressSource) *vpcv1alpha1.ObjectKeySelector {
+		return from.ConfigMapKeyRef
+	}))
+	if err != nil {
+		return err
+	}
+	secrets, err := metadataSource(mgr, corev1.SchemeGroupVersion.WithResource("secrets"))
+	if err != nil {
+		return err
+	}
+	configMaps, err := metadataSource(mgr, corev1.SchemeGroupVersion.WithResource("configmaps"))
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&vpcv1alpha1.NetworkInterface{}).
+		For(&vpcv1alpha1.NetworkInterface{}, builder.WithPredicates(r.networkInterfacePredicate())).
 		Watches(&source.Kind{
 			Type: &corev1.Node{},
 		}, &handler.Funcs{
@@ -250,5 +578,41 @@ func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				}
 			},
 		}).
+		Watches(&source.Kind{
+			Type: &vpcv1alpha1.PrivateNetwork{},
+		}, &handler.Funcs{
+			UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+				oldPN, ok := e.ObjectOld.(*vpcv1alpha1.PrivateNetwork)
+				if !ok {
+					return
+				}
+				newPN, ok := e.ObjectNew.(*vpcv1alpha1.PrivateNetwork)
+				if !ok {
+					return
+				}
+				if reflect.DeepEqual(oldPN.Spec.IPAM, newPN.Spec.IPAM) || !selectsPrivateNetwork(r.PrivateNetworkSelector, newPN.Labels) {
+					return
+				}
+				nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+				err := r.Client.List(context.Background(), nicsList,
+					client.MatchingLabels{
+						constants.PrivateNetworkLabel: e.MetaNew.GetName(),
+					},
+				)
+				if err != nil {
+					r.Log.Error(err, "unable to sync nics on privateNetwork ipam update")
+					return
+				}
+				for _, nic := range nicsList.Items {
+					q.Add(reconcile.Request{
+						NamespacedName: types.NamespacedName{
+							Name: nic.Name,
+						},
+					})
+				}
+			},
+		}).
+		Watches(secrets, r.addressFromHandler(secretKeyRefIndex)).
+		Watches(configMaps, r.addressFromHandler(configMapKeyRefIndex)).
 		Complete(r)
 }
@@ -0,0 +1,156 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	goipam "github.com/metal-stack/go-ipam"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/resources"
+)
+
+var _ = Describe("NetworkInterface address allocation", func() {
+	It("allocates an address when there is none", func() {
+		Expect(addressNeedsAllocation("", []string{"192.168.0.0/24"})).To(BeTrue())
+	})
+
+	It("keeps an address part of the cidrs", func() {
+		Expect(addressNeedsAllocation("192.168.0.12/24", []string{"192.168.0.0/24"})).To(BeFalse())
+		Expect(addressNeedsAllocation("192.168.0.12/24", []string{"10.0.0.0/24", "192.168.0.0/28"})).To(BeFalse())
+	})
+
+	It("reallocates an address when the cidr changes", func() {
+		Expect(addressNeedsAllocation("192.168.0.12/24", []string{"10.0.0.0/24"})).To(BeTrue())
+	})
+
+	It("only allocates an IPv6 address on a v4 to dual-stack transition", func() {
+		pn, err := resources.NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", resources.WithStaticIPAM("192.168.0.0/24", ""))
+		Expect(err).NotTo(HaveOccurred())
+		nic, err := resources.NewNetworkInterface(pn, "node-1", "22222222-2222-2222-2222-222222222222", scheme.Scheme)
+		Expect(err).NotTo(HaveOccurred())
+		nic.Name = "pn-node-1"
+		r := &NetworkInterfaceReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+				pn,
+				nic,
+			),
+			Log:  logf.Log,
+			IPAM: goipam.New(),
+		}
+		key := types.NamespacedName{Name: nic.Name}
+		reconcile := func() *vpcv1alpha1.NetworkInterface {
+			_, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			reconciled := &vpcv1alpha1.NetworkInterface{}
+			Expect(r.Client.Get(context.Background(), key, reconciled)).To(Succeed())
+			return reconciled
+		}
+
+		// v4 only
+		reconciled := reconcile()
+		address := reconciled.Status.Address
+		Expect(address).To(HavePrefix("192.168.0."))
+		Expect(reconciled.Status.IPv6Address).To(BeEmpty())
+
+		// dual-stack
+		Expect(r.Client.Get(context.Background(), types.NamespacedName{Name: pn.Name}, pn)).To(Succeed())
+		pn.Spec.IPAM.Static.IPv6CIDR = "fd00::/64"
+		Expect(r.Client.Update(context.Background(), pn)).To(Succeed())
+		reconciled = reconcile()
+		Expect(reconciled.Status.Address).To(Equal(address))
+		Expect(reconciled.Status.IPv6Address).To(HavePrefix("fd00::"))
+		Expect(reconciled.Status.IPv6ParentCIDR).To(Equal("fd00::/64"))
+	})
+})
+
+var _ = Describe("NetworkInterface address reference", func() {
+	var r *NetworkInterfaceReconciler
+
+	BeforeEach(func() {
+		r = &NetworkInterfaceReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "addresses"},
+					Data:       map[string][]byte{"node-1": []byte("192.168.0.10/24\n"), "node-2": []byte("192.168.0.11")},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "addresses"},
+					Data:       map[string]string{"node-1": "fd00::10/64"},
+				},
+			),
+		}
+	})
+
+	ref := func(name, key string) *vpcv1alpha1.ObjectKeySelector {
+		return &vpcv1alpha1.ObjectKeySelector{Namespace: "default", Name: name, Key: key}
+	}
+
+	It("resolves the address from a secret or a configmap", func() {
+		address, err := r.resolveAddressFrom(context.Background(), &vpcv1alpha1.AddressSource{SecretKeyRef: ref("addresses", "node-1")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(address).To(Equal("192.168.0.10/24"))
+
+		address, err = r.resolveAddressFrom(context.Background(), &vpcv1alpha1.AddressSource{ConfigMapKeyRef: ref("addresses", "node-1")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(address).To(Equal("fd00::10/64"))
+	})
+
+	It("rejects missing keys and invalid addresses", func() {
+		_, err := r.resolveAddressFrom(context.Background(), &vpcv1alpha1.AddressSource{SecretKeyRef: ref("addresses", "node-3")})
+		Expect(errors.Is(err, errInvalidAddressFrom)).To(BeTrue())
+
+		_, err = r.resolveAddressFrom(context.Background(), &vpcv1alpha1.AddressSource{SecretKeyRef: ref("addresses", "node-2")})
+		Expect(errors.Is(err, errInvalidAddressFrom)).To(BeTrue())
+		Expect(err.Error()).NotTo(ContainSubstring("192.168.0.11"))
+	})
+
+	It("sets the resolved address in the status", func() {
+		pn, err := resources.NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111")
+		Expect(err).NotTo(HaveOccurred())
+		nic, err := resources.NewNetworkInterface(pn, "node-1", "22222222-2222-2222-2222-222222222222", scheme.Scheme)
+		Expect(err).NotTo(HaveOccurred())
+		nic.Name = "pn-node-1"
+		nic.Spec.AddressFrom = &vpcv1alpha1.AddressSource{SecretKeyRef: ref("addresses", "node-1")}
+		Expect(r.Client.Create(context.Background(), nic)).To(Succeed())
+
+		Expect(r.syncAddressFrom(context.Background(), nic, pn)).To(Succeed())
+		synced := &vpcv1alpha1.NetworkInterface{}
+		Expect(r.Client.Get(context.Background(), types.NamespacedName{Name: nic.Name}, synced)).To(Succeed())
+		Expect(synced.Spec.Address).To(BeEmpty())
+		Expect(synced.Status.ResolvedAddress).To(Equal("192.168.0.10/24"))
+		Expect(synced.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceAddressResolved)).To(BeTrue())
+	})
+
+	It("reports missing objects as not found", func() {
+		_, err := r.resolveAddressFrom(context.Background(), &vpcv1alpha1.AddressSource{SecretKeyRef: ref("missing", "node-1")})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -20,7 +20,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -30,11 +32,13 @@ import (
 	vpc "github.com/scaleway/scaleway-sdk-go/api/vpc/v1"
 	"github.com/scaleway/scaleway-sdk-go/scw"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -44,12 +48,17 @@ import (
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/resources"
 )
 
 const (
 	regexpProduct      = "product"
 	regexpLocalization = "localization"
 	regexpUUID         = "uuid"
+
+	// conflictRequeueDelay is the delay after which an object modified during its reconcile is reconciled again,
+	// letting the cache catch up with the latest version
+	conflictRequeueDelay = time.Millisecond * 100
 )
 
 var (
@@ -67,6 +76,11 @@ type PrivateNetworkReconciler struct {
 	IPAM        goipam.Ipamer
 	InstanceAPI *instance.API
 	VpcAPI      *vpc.API
+
+	// PrivateNetworkSelector selects the PrivateNetworks managed by the controller, nil selects all of them
+	PrivateNetworkSelector labels.Selector
+
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch;create;update;patch;delete
@@ -75,10 +89,16 @@ type PrivateNetworkReconciler struct {
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;update
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
 	log := r.Log.WithValues("privatenetwork", req.NamespacedName)
+	res, err := r.reconcile(log, req)
+	return requeueOnConflict(log, res, err)
+}
+
+func (r *PrivateNetworkReconciler) reconcile(log logr.Logger, req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
 
 	pn := &vpcv1alpha1.PrivateNetwork{}
 
@@ -88,6 +108,19 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	if !selectsPrivateNetwork(r.PrivateNetworkSelector, pn.Labels) {
+		log.V(1).Info("private network not selected, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	if pn.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		err = r.updateMembersStatus(ctx, pn)
+		if err != nil {
+			log.Error(err, "could not update privateNetwork members status")
+			return ctrl.Result{}, err
+		}
+	}
+
 	if pn.Spec.CIDR != "" {
 		res, err := r.ReconcileDeprecated(req)
 		return res, err
@@ -131,6 +164,7 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 				}
 				return ctrl.Result{}, nil
 			}
+			r.recordBlockedDeletion(pn, nicsList.Items)
 			return ctrl.Result{RequeueAfter: RequeueDuration}, nil
 		}
 		return ctrl.Result{}, nil
@@ -144,7 +178,7 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		}
 	}
 
-	_, err = r.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
+	scwPN, err := r.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
 		Zone:             scw.Zone(pn.Spec.Zone),
 		PrivateNetworkID: pn.Spec.ID,
 	})
@@ -204,14 +238,25 @@ func (r *PrivateNetworkReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 			return ctrl.Result{RequeueAfter: RequeueDuration}, err
 		}
 
+		if len(nicsList.Items) == 1 {
+			nic := &nicsList.Items[0]
+			if resources.SetNetworkMetadata(nic, scwPN.ID, scwPN.Name) {
+				err = r.Client.Update(ctx, nic)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("could not update networkInterface %s private network metadata", nic.Name))
+					return ctrl.Result{RequeueAfter: RequeueDuration}, err
+				}
+			}
+		}
+
 		if len(nicsList.Items) == 0 {
-			nic, err := r.constructNetworkInterfaceForPrivateNetwork(pn, node.Name)
+			nic, err := resources.NewNetworkInterface(pn, node.Name, privateNIC.ID, r.Scheme)
 			if err != nil {
 				log.Error(err, "unable to construct networkInterface from privateNetwork")
 				return ctrl.Result{RequeueAfter: RequeueDuration}, err
 			}
+			resources.SetNetworkMetadata(nic, scwPN.ID, scwPN.Name)
 
-			nic.Spec.ID = privateNIC.ID
 			err = r.Client.Create(ctx, nic)
 			if err != nil {
 				log.Error(err, "could not create networkInterface")
@@ -286,6 +331,7 @@ func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.R
 				}
 				return ctrl.Result{}, nil
 			}
+			r.recordBlockedDeletion(pn, nicsList.Items)
 			return ctrl.Result{RequeueAfter: RequeueDuration}, nil
 		}
 		return ctrl.Result{}, nil
@@ -299,7 +345,7 @@ func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.R
 		}
 	}
 
-	_, err = r.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
+	scwPN, err := r.VpcAPI.GetPrivateNetwork(&vpc.GetPrivateNetworkRequest{
 		Zone:             scw.Zone(pn.Spec.Zone),
 		PrivateNetworkID: pn.Spec.ID,
 	})
@@ -359,12 +405,24 @@ func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.R
 			return ctrl.Result{RequeueAfter: RequeueDuration}, err
 		}
 
+		if len(nicsList.Items) == 1 {
+			nic := &nicsList.Items[0]
+			if resources.SetNetworkMetadata(nic, scwPN.ID, scwPN.Name) {
+				err = r.Client.Update(ctx, nic)
+				if err != nil {
+					log.Error(err, fmt.Sprintf("could not update networkInterface %s private network metadata", nic.Name))
+					return ctrl.Result{RequeueAfter: RequeueDuration}, err
+				}
+			}
+		}
+
 		if len(nicsList.Items) == 0 {
-			nic, err := r.constructNetworkInterfaceForPrivateNetwork(pn, node.Name)
+			nic, err := resources.NewNetworkInterface(pn, node.Name, privateNIC.ID, r.Scheme)
 			if err != nil {
 				log.Error(err, "unable to construct networkInterface from privateNetwork")
 				return ctrl.Result{RequeueAfter: RequeueDuration}, err
 			}
+			resources.SetNetworkMetadata(nic, scwPN.ID, scwPN.Name)
 			ip, err := r.IPAM.AcquireIP(prefix.Cidr)
 			if err != nil {
 				log.Error(err, fmt.Sprintf("error acquiring ip for cidr %s", prefix.Cidr))
@@ -373,7 +431,6 @@ func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.R
 
 			// TODO have a better idea :D
 			nic.Spec.Address = ip.IP.String() + "/" + strings.Split(prefix.Cidr, "/")[1]
-			nic.Spec.ID = privateNIC.ID
 			err = r.Client.Create(ctx, nic)
 			if err != nil {
 				log.Error(err, "could not create networkInterface")
@@ -392,37 +449,71 @@ func (r *PrivateNetworkReconciler) ReconcileDeprecated(req ctrl.Request) (ctrl.R
 	return ctrl.Result{}, nil
 }
 
-func (r *PrivateNetworkReconciler) constructNetworkInterfaceForPrivateNetwork(pn *vpcv1alpha1.PrivateNetwork, nodeName string) (*vpcv1alpha1.NetworkInterface, error) {
-	nic := &vpcv1alpha1.NetworkInterface{
-		ObjectMeta: metav1.ObjectMeta{
-			Labels:       make(map[string]string),
-			Annotations:  make(map[string]string),
-			GenerateName: pn.Name + "-",
-		},
-		Spec: vpcv1alpha1.NetworkInterfaceSpec{
-			NodeName: nodeName,
+// updateMembersStatus updates the status of the PrivateNetwork with the health of its NetworkInterfaces
+func (r *PrivateNetworkReconciler) updateMembersStatus(ctx context.Context, pn *vpcv1alpha1.PrivateNetwork) error {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.PrivateNetworkLabel: pn.Name,
 		},
+	)
+	if err != nil {
+		return err
 	}
-	for k, v := range pn.Annotations {
-		nic.Annotations[k] = v
+
+	status := membersStatus(nicsList.Items)
+	if reflect.DeepEqual(status, pn.Status) {
+		return nil
+	}
+	pn.Status = status
+	return r.Client.Status().Update(ctx, pn)
+}
+
+// membersStatus computes the status of a PrivateNetwork from its NetworkInterfaces
+func membersStatus(nics []vpcv1alpha1.NetworkInterface) vpcv1alpha1.PrivateNetworkStatus {
+	status := vpcv1alpha1.PrivateNetworkStatus{
+		Members: len(nics),
 	}
-	for k, v := range pn.Labels {
-		nic.Labels[k] = v
+	for _, nic := range nics {
+		addresses := []string{}
+		for _, address := range []string{nic.Status.Address, nic.Status.IPv6Address} {
+			if address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+		if len(addresses) != 0 {
+			if status.MemberAddresses == nil {
+				status.MemberAddresses = make(map[string][]string)
+			}
+			status.MemberAddresses[nic.Spec.NodeName] = append(status.MemberAddresses[nic.Spec.NodeName], addresses...)
+		}
+
+		if nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceReady) {
+			status.ReadyMembers++
+			continue
+		}
+		status.UnhealthyNodes = append(status.UnhealthyNodes, nic.Spec.NodeName)
 	}
-	nic.Labels[constants.PrivateNetworkLabel] = pn.Name
-	nic.Labels[constants.NodeLabel] = nodeName
-	if err := ctrl.SetControllerReference(pn, nic, r.Scheme); err != nil {
-		return nil, err
+	sort.Strings(status.UnhealthyNodes)
+	for _, addresses := range status.MemberAddresses {
+		sort.Strings(addresses)
 	}
-	controllerutil.AddFinalizer(nic, constants.FinalizerName)
-	controllerutil.AddFinalizer(nic, constants.IPFinalizerName)
+	return status
+}
 
-	return nic, nil
+// recordBlockedDeletion emits an event listing the NetworkInterfaces the deletion of the PrivateNetwork waits for
+func (r *PrivateNetworkReconciler) recordBlockedDeletion(pn *vpcv1alpha1.PrivateNetwork, nics []vpcv1alpha1.NetworkInterface) {
+	names := make([]string, 0, len(nics))
+	for _, nic := range nics {
+		names = append(names, nic.Name)
+	}
+	sort.Strings(names)
+	r.Recorder.Eventf(pn, corev1.EventTypeNormal, "DeletionBlocked", "waiting for network interfaces %s to be torn down", strings.Join(names, ", "))
 }
 
 func (r *PrivateNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&vpcv1alpha1.PrivateNetwork{}).
+		For(&vpcv1alpha1.PrivateNetwork{}, builder.WithPredicates(privateNetworkPredicate(r.PrivateNetworkSelector))).
 		Owns(&vpcv1alpha1.NetworkInterface{}).
 		Watches(&source.Kind{
 			Type: &corev1.Node{},
@@ -435,6 +526,9 @@ func (r *PrivateNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
 					return
 				}
 				for _, pn := range pnsList.Items {
+					if !selectsPrivateNetwork(r.PrivateNetworkSelector, pn.Labels) {
+						continue
+					}
 					q.Add(reconcile.Request{
 						NamespacedName: types.NamespacedName{
 							Name: pn.Name,
@@ -0,0 +1,69 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var _ = Describe("PrivateNetwork members status", func() {
+	newNIC := func(nodeName string, ready bool) vpcv1alpha1.NetworkInterface {
+		nic := vpcv1alpha1.NetworkInterface{
+			Spec: vpcv1alpha1.NetworkInterfaceSpec{
+				NodeName: nodeName,
+			},
+		}
+		if ready {
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionTrue, "Configured", "")
+		}
+		return nic
+	}
+
+	It("is empty without members", func() {
+		Expect(membersStatus(nil)).To(Equal(vpcv1alpha1.PrivateNetworkStatus{}))
+	})
+
+	It("counts ready members and lists unhealthy nodes", func() {
+		status := membersStatus([]vpcv1alpha1.NetworkInterface{
+			newNIC("node-3", false),
+			newNIC("node-1", true),
+			newNIC("node-2", false),
+		})
+		Expect(status.Members).To(Equal(3))
+		Expect(status.ReadyMembers).To(Equal(1))
+		Expect(status.UnhealthyNodes).To(Equal([]string{"node-2", "node-3"}))
+		Expect(status.MemberAddresses).To(BeNil())
+	})
+
+	It("maps the member nodes to their addresses", func() {
+		nic1 := newNIC("node-1", true)
+		nic1.Status.Address = "192.168.0.1/24"
+		nic1.Status.IPv6Address = "fd00::1/64"
+		nic2 := newNIC("node-2", false)
+		nic2.Status.Address = "192.168.0.2/24"
+
+		status := membersStatus([]vpcv1alpha1.NetworkInterface{nic1, nic2, newNIC("node-3", false)})
+		Expect(status.MemberAddresses).To(Equal(map[string][]string{
+			"node-1": {"192.168.0.1/24", "fd00::1/64"},
+			"node-2": {"192.168.0.2/24"},
+		}))
+	})
+})
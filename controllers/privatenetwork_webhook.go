@@ -0,0 +1,145 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/resources"
+)
+
+// PrivateNetworkValidatorPath is the path of the PrivateNetwork validating webhook
+const PrivateNetworkValidatorPath = "/validate-vpc-scaleway-com-v1alpha1-privatenetwork"
+
+// +kubebuilder:webhook:path=/validate-vpc-scaleway-com-v1alpha1-privatenetwork,mutating=false,failurePolicy=fail,sideEffects=None,groups=vpc.scaleway.com,resources=privatenetworks,verbs=create;update;delete,versions=v1alpha1,name=vprivatenetwork.kb.io,admissionReviewVersions={v1beta1}
+
+// PrivateNetworkValidator denies the PrivateNetworks with an invalid spec, and the deletion of a PrivateNetwork
+// still attached to NetworkInterfaces
+type PrivateNetworkValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+// Handle handles the admission requests of PrivateNetworks
+func (v *PrivateNetworkValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	switch req.Operation {
+	case admissionv1beta1.Create, admissionv1beta1.Update:
+		return v.handleSpec(ctx, req)
+	case admissionv1beta1.Delete:
+		return v.handleDelete(ctx, req)
+	default:
+		return admission.Allowed("")
+	}
+}
+
+// handleSpec validates the spec of a created or updated PrivateNetwork, with the validation
+// used by the controllers building PrivateNetworks, and denies a route table used by another PrivateNetwork
+func (v *PrivateNetworkValidator) handleSpec(ctx context.Context, req admission.Request) admission.Response {
+	pn := &vpcv1alpha1.PrivateNetwork{}
+	err := v.decoder.DecodeRaw(req.Object, pn)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// the existing PrivateNetworks with an invalid spec can still get their metadata updated
+	if req.Operation == admissionv1beta1.Update {
+		oldPN := &vpcv1alpha1.PrivateNetwork{}
+		err := v.decoder.DecodeRaw(req.OldObject, oldPN)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(pn.Spec, oldPN.Spec) {
+			return admission.Allowed("")
+		}
+	}
+
+	err = resources.ValidatePrivateNetwork(pn)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	pnets := &vpcv1alpha1.PrivateNetworkList{}
+	err = v.Client.List(ctx, pnets)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	conflicts := resources.RouteTableConflicts(pn, pnets.Items)
+	if len(conflicts) != 0 {
+		return admission.Denied(fmt.Sprintf("route table %d is already used by private networks %s, set the %s annotation to \"true\" on all of them to share it",
+			pn.Spec.RouteTable, strings.Join(conflicts, ", "), constants.AllowSharedRouteTableAnnotation))
+	}
+	return admission.Allowed("")
+}
+
+// handleDelete denies the deletion of a PrivateNetwork still attached to NetworkInterfaces
+func (v *PrivateNetworkValidator) handleDelete(ctx context.Context, req admission.Request) admission.Response {
+	pn := &vpcv1alpha1.PrivateNetwork{}
+	err := v.decoder.DecodeRaw(req.OldObject, pn)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pn.Annotations[constants.ForceDeleteAnnotation] == "true" {
+		return admission.Allowed(fmt.Sprintf("%s annotation is set", constants.ForceDeleteAnnotation))
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = v.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.PrivateNetworkLabel: pn.Name,
+		},
+	)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	blocking := blockingNetworkInterfaces(nicsList.Items)
+	if len(blocking) == 0 {
+		return admission.Allowed("")
+	}
+	return admission.Denied(fmt.Sprintf("private network %s is still attached to network interfaces %s, set the %s annotation to \"true\" to delete it anyway",
+		pn.Name, strings.Join(blocking, ", "), constants.ForceDeleteAnnotation))
+}
+
+// InjectDecoder injects the decoder
+func (v *PrivateNetworkValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// blockingNetworkInterfaces returns the names of the NetworkInterfaces not being deleted
+func blockingNetworkInterfaces(nics []vpcv1alpha1.NetworkInterface) []string {
+	names := []string{}
+	for _, nic := range nics {
+		if nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			names = append(names, nic.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,41 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var _ = Describe("PrivateNetwork deletion validation", func() {
+	It("is not blocked without network interfaces", func() {
+		Expect(blockingNetworkInterfaces(nil)).To(BeEmpty())
+	})
+
+	It("is blocked by the network interfaces not being deleted", func() {
+		now := metav1.Now()
+		blocking := blockingNetworkInterfaces([]vpcv1alpha1.NetworkInterface{
+			{ObjectMeta: metav1.ObjectMeta{Name: "pn-node-2"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pn-node-3", DeletionTimestamp: &now}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pn-node-1"}},
+		})
+		Expect(blocking).To(Equal([]string{"pn-node-1", "pn-node-2"}))
+	})
+})
@@ -8,8 +8,12 @@ require (
 	github.com/metal-stack/go-ipam v1.8.1
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
+	github.com/prometheus/client_golang v1.0.0
 	github.com/scaleway/scaleway-sdk-go v1.0.0-beta.7.0.20210223165440-c65ae3540d44
 	github.com/vishvananda/netlink v1.1.0
+	github.com/vishvananda/netns v0.0.0-20191106174202-0a2b9b5464df
+	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
+	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68
 	google.golang.org/appengine v1.6.6 // indirect
 	k8s.io/api v0.18.6
 	k8s.io/apimachinery v0.18.6
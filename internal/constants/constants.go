@@ -12,4 +12,40 @@ const (
 
 	// NodeLabel is the node label
 	NodeLabel = "node"
+
+	// NetworkLabel is set by the controller on a NetworkInterface to the ID of its Scaleway private network
+	NetworkLabel = "vpc.scaleway.com/network"
+
+	// NetworkNameAnnotation is set by the controller on a NetworkInterface to the name of its Scaleway private network
+	NetworkNameAnnotation = "vpc.scaleway.com/network-name"
+
+	// ForceTeardownAnnotation allows to tear down a NetworkInterface holding the node default route
+	// when no previous default route can be restored
+	ForceTeardownAnnotation = "vpc.scaleway.com/force-teardown"
+
+	// ForceDeleteAnnotation allows to delete a PrivateNetwork still attached to NetworkInterfaces
+	// when the validating webhook is enabled
+	ForceDeleteAnnotation = "vpc.scaleway.com/force-delete"
+
+	// PauseReconcileAnnotation stops the node from configuring a NetworkInterface while set to "true"
+	PauseReconcileAnnotation = "vpc.scaleway.com/pause-reconcile"
+
+	// AllowPrimaryLinkAnnotation allows the node to configure a NetworkInterface whose link holds
+	// an address of the primary interface of the node
+	AllowPrimaryLinkAnnotation = "vpc.scaleway.com/allow-primary-link"
+
+	// AllowSharedRouteTableAnnotation allows PrivateNetworks to use the same route table
+	// when set to "true" on all of them
+	AllowSharedRouteTableAnnotation = "vpc.scaleway.com/allow-shared-route-table"
+
+	// HoldMTUChangeAnnotation stops the node from changing the MTU of the link of a NetworkInterface
+	// while set to "true", so that the disruptive change is applied in a maintenance window
+	HoldMTUChangeAnnotation = "vpc.scaleway.com/hold-mtu-change"
+
+	// LastErrorAnnotation is set by the node to the last reconcile error of a NetworkInterface,
+	// and removed on the next successful reconcile
+	LastErrorAnnotation = "vpc.scaleway.com/last-error"
+
+	// LastErrorTimeAnnotation is the time the LastErrorAnnotation was set at
+	LastErrorTimeAnnotation = "vpc.scaleway.com/last-error-time"
 )
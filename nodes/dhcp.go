@@ -0,0 +1,223 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// dhcpLeaseStatus returns the status of a DHCP lease
+func dhcpLeaseStatus(lease *nics.DHCPLease) *vpcv1alpha1.DHCPLease {
+	status := &vpcv1alpha1.DHCPLease{
+		Address: lease.Address,
+		MTU:     lease.MTU,
+	}
+	if lease.Gateway != nil {
+		status.Gateway = lease.Gateway.String()
+	}
+	for _, server := range lease.DNS {
+		status.DNS = append(status.DNS, server.String())
+	}
+	for _, route := range lease.Routes {
+		status.Routes = append(status.Routes, route.To.String()+" via "+route.Via.String())
+	}
+	return status
+}
+
+// dhcpOptions returns the options of the DHCP leases applied on the interfaces of a PrivateNetwork
+func dhcpOptions(pnet *vpcv1alpha1.PrivateNetwork) vpcv1alpha1.PrivateNetworkIPAMDHCP {
+	if pnet.Spec.IPAM == nil || pnet.Spec.IPAM.Type != vpcv1alpha1.IPAMTypeDHCP || pnet.Spec.IPAM.DHCP == nil {
+		return vpcv1alpha1.PrivateNetworkIPAMDHCP{}
+	}
+	return *pnet.Spec.IPAM.DHCP
+}
+
+// dhcpGatewayRoute returns the default route via the gateway of a DHCP lease, nil when it is not applied
+func dhcpGatewayRoute(pnet *vpcv1alpha1.PrivateNetwork, lease *vpcv1alpha1.DHCPLease) (*nics.Route, error) {
+	if !dhcpOptions(pnet).ApplyGateway || lease == nil || lease.Gateway == "" {
+		return nil, nil
+	}
+	route, err := parsePrivateNetworkRoute(pnet, vpcv1alpha1.PrivateNetworkRoute{
+		To:  "0.0.0.0/0",
+		Via: lease.Gateway,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
+
+// syncDHCPMTU sets the MTU of the DHCP lease of a NetworkInterface on its link, saving the previous one,
+// or restores the saved MTU when the lease MTU is no longer applied
+func (r *NetworkInterfaceReconciler) syncDHCPMTU(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) error {
+	if dhcpOptions(pnet).ApplyMTU && nic.Status.DHCPLease != nil && nic.Status.DHCPLease.MTU != 0 {
+		// the MTU is patched in the status before it is changed, not to lose it if the node daemon stops
+		if nic.Status.SavedMTU == 0 {
+			mtu, err := r.NICs.GetLinkMTU(nic.Status.MacAddress)
+			if err != nil {
+				return err
+			}
+			status := nic.Status.DeepCopy()
+			nic.Status.SavedMTU = mtu
+			err = r.patchStatus(ctx, nic, status)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := r.setLinkMTU(nic, nic.Status.DHCPLease.MTU)
+		return err
+	}
+	return r.restoreMTU(nic)
+}
+
+// appliedMTU returns the MTU of the DHCP lease the node applied on the link of a NetworkInterface,
+// 0 when it left the MTU of the link untouched
+func appliedMTU(nic *vpcv1alpha1.NetworkInterface) int {
+	if nic.Status.SavedMTU == 0 || nic.Status.DHCPLease == nil {
+		return 0
+	}
+	return nic.Status.DHCPLease.MTU
+}
+
+// startupMTU returns the MTU the startup pass sets back on the link of a NetworkInterface of the node,
+// 0 when it is skipped
+func startupMTU(nic *vpcv1alpha1.NetworkInterface, nodeName string) int {
+	if nic.Spec.NodeName != nodeName || nic.Status.MacAddress == "" || !nic.DeletionTimestamp.IsZero() ||
+		nic.Annotations[constants.HoldMTUChangeAnnotation] == "true" {
+		return 0
+	}
+	return appliedMTU(nic)
+}
+
+// CorrectMTUs sets back the MTU applied on the links of the NetworkInterfaces of the node when it was reset,
+// as after a reboot, without waiting for their reconciles. The links that are missing or whose MTU changes are held
+// are skipped, and a failure is only logged, the reconciles correcting the MTU too
+func CorrectMTUs(ctx context.Context, c client.Reader, nodeName string, nicsHandler *nics.NICs, log logr.Logger) error {
+	nicList := vpcv1alpha1.NetworkInterfaceList{}
+	err := c.List(ctx, &nicList, client.MatchingLabels{
+		constants.NodeLabel: nodeName,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range nicList.Items {
+		nic := &nicList.Items[i]
+		mtu := startupMTU(nic, nodeName)
+		if mtu == 0 {
+			continue
+		}
+		current, err := nicsHandler.GetLinkMTU(nic.Status.MacAddress)
+		if err != nil {
+			if !nics.IsNotFound(err) {
+				log.Error(err, "unable to get link mtu", "networkinterface", nic.Name)
+			}
+			continue
+		}
+		if current == mtu {
+			continue
+		}
+		err = nicsHandler.SetLinkMTU(nic.Status.MacAddress, mtu)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to set back the MTU of link %s", nic.Status.LinkName), "networkinterface", nic.Name)
+			continue
+		}
+		log.Info(fmt.Sprintf("set back the MTU of link %s from %d to %d", nic.Status.LinkName, current, mtu), "networkinterface", nic.Name)
+	}
+	return nil
+}
+
+// restoreMTU sets back the MTU of the link of a NetworkInterface saved before the MTU of its DHCP lease was applied
+func (r *NetworkInterfaceReconciler) restoreMTU(nic *vpcv1alpha1.NetworkInterface) error {
+	if nic.Status.SavedMTU == 0 {
+		return nil
+	}
+	changed, err := r.setLinkMTU(nic, nic.Status.SavedMTU)
+	if err != nil || !changed {
+		return err
+	}
+	nic.Status.SavedMTU = 0
+	return nil
+}
+
+// setLinkMTU sets the MTU of the link of a NetworkInterface, returning false when the change is held by
+// the HoldMTUChangeAnnotation. As changing the MTU can disrupt the flows of the link, the change is
+// logged and recorded in an event
+func (r *NetworkInterfaceReconciler) setLinkMTU(nic *vpcv1alpha1.NetworkInterface, mtu int) (bool, error) {
+	current, err := r.NICs.GetLinkMTU(nic.Status.MacAddress)
+	if err != nil {
+		return false, err
+	}
+	if current == mtu {
+		if nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceMTUChangeHeld) != nil {
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceMTUChangeHeld, corev1.ConditionFalse, "NoChange", "")
+		}
+		return true, nil
+	}
+
+	log := r.Log.WithValues("networkinterface", nic.Name)
+	if nic.Annotations[constants.HoldMTUChangeAnnotation] == "true" {
+		held := fmt.Sprintf("MTU change of link %s from %d to %d held by the %s annotation", nic.Status.LinkName, current, mtu, constants.HoldMTUChangeAnnotation)
+		if nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceMTUChangeHeld, corev1.ConditionTrue, "HoldAnnotation", held) {
+			log.Info(held)
+		}
+		return false, nil
+	}
+
+	message := fmt.Sprintf("changing the MTU of link %s from %d to %d", nic.Status.LinkName, current, mtu)
+	log.Info(message)
+	r.Recorder.Event(nic, corev1.EventTypeWarning, "MTUChange", message+", which can disrupt the flows of the link")
+	err = r.NICs.SetLinkMTU(nic.Status.MacAddress, mtu)
+	if err != nil {
+		return false, err
+	}
+	log.Info(fmt.Sprintf("changed the MTU of link %s to %d", nic.Status.LinkName, mtu))
+	if nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceMTUChangeHeld) != nil {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceMTUChangeHeld, corev1.ConditionFalse, "Applied", "")
+	}
+	return true, nil
+}
+
+// removeDHCPLease removes the default route and sets back the MTU applied from the lost DHCP lease
+// of a NetworkInterface, and clears the lease from its status
+func (r *NetworkInterfaceReconciler) removeDHCPLease(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) error {
+	gatewayRoute, err := dhcpGatewayRoute(pnet, nic.Status.DHCPLease)
+	if err != nil {
+		return err
+	}
+	if gatewayRoute != nil {
+		err = r.NICs.DeleteRoute(nic.Status.MacAddress, int(pnet.Spec.RouteTable), *gatewayRoute)
+		if err != nil {
+			return err
+		}
+	}
+	err = r.restoreMTU(nic)
+	if err != nil {
+		return err
+	}
+	nic.Status.DHCPLease = nil
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+var _ = Describe("DHCP MTU", func() {
+	var nic *vpcv1alpha1.NetworkInterface
+
+	BeforeEach(func() {
+		nic = &vpcv1alpha1.NetworkInterface{
+			Spec: vpcv1alpha1.NetworkInterfaceSpec{NodeName: "node-1"},
+			Status: vpcv1alpha1.NetworkInterfaceStatus{
+				MacAddress: "02:00:00:00:00:01",
+				DHCPLease:  &vpcv1alpha1.DHCPLease{Address: "172.16.0.10/24", MTU: 1400},
+				SavedMTU:   1500,
+			},
+		}
+	})
+
+	It("reports the MTU applied from the lease", func() {
+		Expect(appliedMTU(nic)).To(Equal(1400))
+
+		nic.Status.SavedMTU = 0
+		Expect(appliedMTU(nic)).To(Equal(0))
+
+		nic.Status.SavedMTU = 1500
+		nic.Status.DHCPLease = nil
+		Expect(appliedMTU(nic)).To(Equal(0))
+	})
+
+	It("sets back the applied MTU at startup", func() {
+		Expect(startupMTU(nic, "node-1")).To(Equal(1400))
+	})
+
+	It("skips the interfaces of other nodes, held, unresolved or deleted", func() {
+		Expect(startupMTU(nic, "node-2")).To(Equal(0))
+
+		held := nic.DeepCopy()
+		held.Annotations = map[string]string{constants.HoldMTUChangeAnnotation: "true"}
+		Expect(startupMTU(held, "node-1")).To(Equal(0))
+
+		unresolved := nic.DeepCopy()
+		unresolved.Status.MacAddress = ""
+		Expect(startupMTU(unresolved, "node-1")).To(Equal(0))
+
+		deleted := nic.DeepCopy()
+		now := metav1.Now()
+		deleted.DeletionTimestamp = &now
+		Expect(startupMTU(deleted, "node-1")).To(Equal(0))
+	})
+})
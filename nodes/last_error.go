@@ -0,0 +1,71 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+// maxLastErrorLength is the maximum length of the error message stamped on a NetworkInterface
+const maxLastErrorLength = 512
+
+// lastErrorAnnotations returns the annotations of a NetworkInterface with the last reconcile error stamped,
+// or removed when err is nil, and whether they changed. The time is only updated when the message changes
+func lastErrorAnnotations(annotations map[string]string, err error, now time.Time) (map[string]string, bool) {
+	if err == nil {
+		_, hasError := annotations[constants.LastErrorAnnotation]
+		_, hasTime := annotations[constants.LastErrorTimeAnnotation]
+		if !hasError && !hasTime {
+			return annotations, false
+		}
+		delete(annotations, constants.LastErrorAnnotation)
+		delete(annotations, constants.LastErrorTimeAnnotation)
+		return annotations, true
+	}
+
+	message := []rune(err.Error())
+	if len(message) > maxLastErrorLength {
+		message = append(message[:maxLastErrorLength-3], []rune("...")...)
+	}
+	if annotations[constants.LastErrorAnnotation] == string(message) {
+		return annotations, false
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[constants.LastErrorAnnotation] = string(message)
+	annotations[constants.LastErrorTimeAnnotation] = now.UTC().Format(time.RFC3339)
+	return annotations, true
+}
+
+// updateLastError stamps the last reconcile error on the annotations of a NetworkInterface,
+// only patching it when they change so that the resulting update event doesn't loop
+func (r *NetworkInterfaceReconciler) updateLastError(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, err error) error {
+	patch := client.MergeFrom(nic.DeepCopy())
+	annotations, changed := lastErrorAnnotations(nic.Annotations, err, time.Now())
+	if !changed {
+		return nil
+	}
+	nic.Annotations = annotations
+	return client.IgnoreNotFound(r.Client.Patch(ctx, nic, patch))
+}
@@ -0,0 +1,65 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+var _ = Describe("Last error annotation", func() {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	It("stamps the error and its time", func() {
+		annotations, changed := lastErrorAnnotations(nil, fmt.Errorf("nic not found on node"), now)
+		Expect(changed).To(BeTrue())
+		Expect(annotations).To(Equal(map[string]string{
+			constants.LastErrorAnnotation:     "nic not found on node",
+			constants.LastErrorTimeAnnotation: "2021-03-01T10:00:00Z",
+		}))
+	})
+
+	It("keeps the time while the error is unchanged", func() {
+		annotations, _ := lastErrorAnnotations(nil, fmt.Errorf("nic not found on node"), now)
+		annotations, changed := lastErrorAnnotations(annotations, fmt.Errorf("nic not found on node"), now.Add(time.Minute))
+		Expect(changed).To(BeFalse())
+		Expect(annotations[constants.LastErrorTimeAnnotation]).To(Equal("2021-03-01T10:00:00Z"))
+	})
+
+	It("truncates long errors", func() {
+		annotations, _ := lastErrorAnnotations(nil, errors.New(strings.Repeat("a", 1000)), now)
+		Expect(annotations[constants.LastErrorAnnotation]).To(HaveLen(maxLastErrorLength))
+		Expect(annotations[constants.LastErrorAnnotation]).To(HaveSuffix("..."))
+	})
+
+	It("clears the error on success", func() {
+		annotations, _ := lastErrorAnnotations(map[string]string{"other": "value"}, fmt.Errorf("nic not found on node"), now)
+		annotations, changed := lastErrorAnnotations(annotations, nil, now)
+		Expect(changed).To(BeTrue())
+		Expect(annotations).To(Equal(map[string]string{"other": "value"}))
+
+		_, changed = lastErrorAnnotations(annotations, nil, now)
+		Expect(changed).To(BeFalse())
+	})
+})
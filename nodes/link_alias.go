@@ -0,0 +1,109 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"text/template"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+// maxLinkAliasLength is the maximum length of a link alias accepted by the kernel
+const maxLinkAliasLength = 255
+
+// LinkAliasData are the fields of a NetworkInterface available to the link alias template
+type LinkAliasData struct {
+	// PrivateNetwork is the name of the PrivateNetwork of the NetworkInterface
+	PrivateNetwork string
+	// NodeName is the name of the node
+	NodeName string
+	// Index is the position of the PrivateNetwork among the ones attached to the node, sorted by name
+	Index int
+	// Address is the IPv4 address of the interface without its prefix length, empty until it is known
+	Address string
+	// MacAddress is the mac address of the interface assigned by Scaleway
+	MacAddress string
+}
+
+// ParseLinkAliasTemplate parses a link alias template, such as pn-{{.Index}}
+func ParseLinkAliasTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("link-alias").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	err = tmpl.Execute(ioutil.Discard, LinkAliasData{})
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderLinkAlias renders the link alias template with the given data
+func renderLinkAlias(tmpl *template.Template, data LinkAliasData) (string, error) {
+	var alias bytes.Buffer
+	err := tmpl.Execute(&alias, data)
+	if err != nil {
+		return "", err
+	}
+	if alias.Len() > maxLinkAliasLength {
+		return "", fmt.Errorf("link alias %q is longer than %d characters", alias.String(), maxLinkAliasLength)
+	}
+	return alias.String(), nil
+}
+
+// linkAlias renders the link alias of a NetworkInterface
+func (r *NetworkInterfaceReconciler) linkAlias(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) (string, error) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: r.NodeName,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	privateNetworks := []string{}
+	for _, other := range nicsList.Items {
+		if other.Spec.NodeName == r.NodeName && other.Labels[constants.PrivateNetworkLabel] != "" {
+			privateNetworks = append(privateNetworks, other.Labels[constants.PrivateNetworkLabel])
+		}
+	}
+	sort.Strings(privateNetworks)
+
+	address := nic.Status.Address
+	if pnet.Spec.IPAM == nil {
+		address = specAddress(nic)
+	}
+	data := LinkAliasData{
+		PrivateNetwork: pnet.Name,
+		NodeName:       r.NodeName,
+		Index:          sort.SearchStrings(privateNetworks, pnet.Name),
+		MacAddress:     nic.Status.MacAddress,
+	}
+	if ip := parseAddress(address); ip != nil {
+		data.Address = ip.String()
+	}
+	return renderLinkAlias(r.LinkAliasTemplate, data)
+}
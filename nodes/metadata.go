@@ -0,0 +1,129 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+const (
+	// DefaultMetadataURL is the URL of the metadata of the instance, in JSON
+	DefaultMetadataURL = "http://169.254.42.42/conf?format=json"
+
+	// NICStateAvailable is the state of a private NIC fully attached to the instance
+	NICStateAvailable = "available"
+)
+
+// MetadataGetter fetches the metadata of the instance
+type MetadataGetter interface {
+	GetMetadata() (*instance.Metadata, error)
+}
+
+// SingleFlightMetadata fetches the metadata of the instance, sharing a single in-flight request
+// between concurrent callers
+// The returned metadata may be shared, and must not be modified
+type SingleFlightMetadata struct {
+	getter MetadataGetter
+	group  singleflight.Group
+}
+
+// NewSingleFlightMetadata returns a SingleFlightMetadata fetching the metadata with the given getter
+func NewSingleFlightMetadata(getter MetadataGetter) *SingleFlightMetadata {
+	return &SingleFlightMetadata{
+		getter: getter,
+	}
+}
+
+// GetMetadata returns the metadata of the instance, waiting for the in-flight request if any
+// Each successful fetch is recorded in the metadata_last_success_timestamp_seconds metric
+func (s *SingleFlightMetadata) GetMetadata() (*instance.Metadata, error) {
+	md, err, _ := s.group.Do("metadata", func() (interface{}, error) {
+		md, err := s.getter.GetMetadata()
+		if err == nil {
+			metadataLastSuccessTimestamp.SetToCurrentTime()
+		}
+		return md, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return md.(*instance.Metadata), nil
+}
+
+// NICStateGetter fetches the state of the private NICs of the instance
+type NICStateGetter interface {
+	// GetNICStates returns the states of the private NICs by normalized mac address, the NICs without a state being absent
+	GetNICStates() (map[string]string, error)
+}
+
+// MetadataNICStates fetches the state of the private NICs from the metadata of the instance,
+// as the metadata of the SDK does not decode it
+type MetadataNICStates struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewMetadataNICStates returns a MetadataNICStates fetching the default metadata URL
+func NewMetadataNICStates() *MetadataNICStates {
+	return &MetadataNICStates{
+		URL:    DefaultMetadataURL,
+		Client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// GetNICStates returns the states of the private NICs by mac address
+func (m *MetadataNICStates) GetNICStates() (map[string]string, error) {
+	resp, err := m.Client.Get(m.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected metadata response status %s", resp.Status)
+	}
+	return decodeNICStates(resp.Body)
+}
+
+// decodeNICStates returns the states of the private NICs of the metadata, which may not expose them
+func decodeNICStates(r io.Reader) (map[string]string, error) {
+	var md struct {
+		PrivateNICs []struct {
+			MacAddress string `json:"mac_address"`
+			State      string `json:"state"`
+		} `json:"private_nics"`
+	}
+	err := json.NewDecoder(r).Decode(&md)
+	if err != nil {
+		return nil, err
+	}
+	states := map[string]string{}
+	for _, nic := range md.PrivateNICs {
+		if nic.State != "" {
+			states[nics.NormalizeMAC(nic.MacAddress)] = nic.State
+		}
+	}
+	return states, nil
+}
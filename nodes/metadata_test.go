@@ -0,0 +1,149 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
+)
+
+// blockingMetadata returns its metadata once released, counting the calls
+type blockingMetadata struct {
+	calls   int32
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (b *blockingMetadata) GetMetadata() (*instance.Metadata, error) {
+	if atomic.AddInt32(&b.calls, 1) == 1 {
+		close(b.started)
+	}
+	<-b.release
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &instance.Metadata{Hostname: "node"}, nil
+}
+
+var _ = Describe("Single flight metadata", func() {
+	var getter *blockingMetadata
+
+	BeforeEach(func() {
+		getter = &blockingMetadata{
+			started: make(chan struct{}),
+			release: make(chan struct{}),
+		}
+	})
+
+	run := func(md *SingleFlightMetadata, callers int) ([]*instance.Metadata, []error) {
+		results := make([]*instance.Metadata, callers)
+		errs := make([]error, callers)
+		var wg sync.WaitGroup
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = md.GetMetadata()
+			}(i)
+		}
+		<-getter.started
+		// let the other callers join the in-flight request
+		Consistently(func() int32 { return atomic.LoadInt32(&getter.calls) }, "100ms").Should(Equal(int32(1)))
+		close(getter.release)
+		wg.Wait()
+		return results, errs
+	}
+
+	It("shares the in-flight request between concurrent callers", func() {
+		md := NewSingleFlightMetadata(getter)
+		results, errs := run(md, 10)
+		Expect(atomic.LoadInt32(&getter.calls)).To(BeNumerically("<", 10))
+		for i := range results {
+			Expect(errs[i]).NotTo(HaveOccurred())
+			Expect(results[i].Hostname).To(Equal("node"))
+		}
+
+		_, err := md.GetMetadata()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("shares the error of the in-flight request", func() {
+		getter.err = fmt.Errorf("metadata unavailable")
+		_, errs := run(NewSingleFlightMetadata(getter), 5)
+		for _, err := range errs {
+			Expect(err).To(MatchError("metadata unavailable"))
+		}
+	})
+
+	It("records the time of the last successful fetch only", func() {
+		metadataLastSuccessTimestamp.Set(0)
+		getter.err = fmt.Errorf("metadata unavailable")
+		run(NewSingleFlightMetadata(getter), 1)
+		Expect(testutil.ToFloat64(metadataLastSuccessTimestamp)).To(BeZero())
+
+		getter = &blockingMetadata{
+			started: make(chan struct{}),
+			release: make(chan struct{}),
+		}
+		before := time.Now().Unix()
+		run(NewSingleFlightMetadata(getter), 1)
+		Expect(testutil.ToFloat64(metadataLastSuccessTimestamp)).To(BeNumerically(">=", before))
+	})
+})
+
+var _ = Describe("Private NIC states", func() {
+	It("decodes the states of the private NICs", func() {
+		states, err := decodeNICStates(strings.NewReader(`{"hostname": "node", "private_nics": [
+			{"id": "nic-1", "mac_address": "02:00:00:00:00:01", "state": "available"},
+			{"id": "nic-2", "mac_address": "02:00:00:00:00:0A", "state": "syncing"}
+		]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states).To(Equal(map[string]string{
+			"02:00:00:00:00:01": NICStateAvailable,
+			"02:00:00:00:00:0a": "syncing",
+		}))
+	})
+
+	It("leaves out the private NICs without a state", func() {
+		states, err := decodeNICStates(strings.NewReader(`{"private_nics": [{"id": "nic-1", "mac_address": "02:00:00:00:00:01"}]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states).To(BeEmpty())
+	})
+
+	It("fetches the states from the metadata", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"private_nics": [{"mac_address": "02:00:00:00:00:01", "state": "available"}]}`)
+		}))
+		defer server.Close()
+
+		getter := &MetadataNICStates{URL: server.URL, Client: server.Client()}
+		states, err := getter.GetNICStates()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states).To(HaveKeyWithValue("02:00:00:00:00:01", NICStateAvailable))
+	})
+})
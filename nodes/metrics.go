@@ -0,0 +1,179 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+const (
+	metricsNamespace = "scaleway_vpc"
+	metricsSubsystem = "node"
+
+	privateNetworkLabel = "private_network"
+	operationLabel      = "operation"
+	sourceLabel         = "source"
+	resultLabel         = "result"
+
+	resultSuccess = "success"
+	resultError   = "error"
+
+	linkOperationConfigure = "configure"
+	linkOperationTearDown  = "teardown"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "reconcile_total",
+		Help:      "Total number of NetworkInterface reconciles",
+	}, []string{privateNetworkLabel, resultLabel})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the NetworkInterface reconciles",
+	}, []string{privateNetworkLabel})
+
+	linkOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "link_operations_total",
+		Help:      "Total number of operations on the links of the NetworkInterfaces",
+	}, []string{privateNetworkLabel, operationLabel, resultLabel})
+
+	routeSyncTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "route_sync_total",
+		Help:      "Total number of route syncs of the NetworkInterfaces",
+	}, []string{privateNetworkLabel, resultLabel})
+
+	skippedRoutesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "skipped_routes_total",
+		Help:      "Total number of routes skipped because of an unreachable gateway",
+	}, []string{privateNetworkLabel})
+
+	routeConflictsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "route_conflicts_total",
+		Help:      "Total number of routes discarded because another route has the same destination",
+	}, []string{privateNetworkLabel, sourceLabel})
+
+	reconcileStallsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "reconcile_stalls_total",
+		Help:      "Total number of reconciles running for longer than the watchdog threshold",
+	})
+
+	statusUpdateConflictsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "status_update_conflicts_total",
+		Help:      "Total number of NetworkInterface status updates conflicting with a concurrent update",
+	})
+
+	penalizedRetriesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "penalized_retries_total",
+		Help:      "Total number of retries of NetworkInterfaces failing repeatedly delayed by the penalty box",
+	})
+
+	slowReconcileTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "slow_reconcile_total",
+		Help:      "Total number of NetworkInterface reconciles longer than the slow reconcile threshold",
+	})
+
+	runtimeConfigReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "runtime_config_reloads_total",
+		Help:      "Total number of loads of the runtime config ConfigMap",
+	}, []string{resultLabel})
+
+	metadataLastSuccessTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "metadata_last_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful fetch of the instance metadata",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileTotal,
+		reconcileDuration,
+		linkOperationsTotal,
+		routeSyncTotal,
+		skippedRoutesTotal,
+		routeConflictsTotal,
+		reconcileStallsTotal,
+		statusUpdateConflictsTotal,
+		penalizedRetriesTotal,
+		slowReconcileTotal,
+		runtimeConfigReloadsTotal,
+		metadataLastSuccessTimestamp,
+	)
+}
+
+// metricsPrivateNetwork returns the private network label value of the metrics of a NetworkInterface
+func (r *NetworkInterfaceReconciler) metricsPrivateNetwork(nic *vpcv1alpha1.NetworkInterface) string {
+	if r.DisablePrivateNetworkMetricsLabel || len(nic.OwnerReferences) == 0 {
+		return ""
+	}
+	return nic.OwnerReferences[0].Name
+}
+
+// deleteMetrics removes the metrics of a private network once its NetworkInterface is torn down,
+// so that the series of deleted private networks do not pile up
+func (r *NetworkInterfaceReconciler) deleteMetrics(nic *vpcv1alpha1.NetworkInterface) {
+	if r.DisablePrivateNetworkMetricsLabel {
+		return
+	}
+	privateNetwork := r.metricsPrivateNetwork(nic)
+	for _, result := range []string{resultSuccess, resultError} {
+		reconcileTotal.DeleteLabelValues(privateNetwork, result)
+		routeSyncTotal.DeleteLabelValues(privateNetwork, result)
+		for _, operation := range []string{linkOperationConfigure, linkOperationTearDown} {
+			linkOperationsTotal.DeleteLabelValues(privateNetwork, operation, result)
+		}
+	}
+	reconcileDuration.DeleteLabelValues(privateNetwork)
+	skippedRoutesTotal.DeleteLabelValues(privateNetwork)
+	for _, source := range []string{routeSourceSpec, routeSourceBGP} {
+		routeConflictsTotal.DeleteLabelValues(privateNetwork, source)
+	}
+}
+
+func metricsResult(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultSuccess
+}
@@ -0,0 +1,133 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"strconv"
+
+	"github.com/coreos/go-iptables/iptables"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+const (
+	mssTable       = "mangle"
+	mssParentChain = "FORWARD"
+	mssChainPrefix = "SCW-VPC-MSS-"
+)
+
+// mssChain returns the chain holding the MSS clamping rule of a link
+func mssChain(linkName string) string {
+	return mssChainPrefix + linkName
+}
+
+// mssRule returns the MSS clamping rule of the chain of a link
+func mssRule(clamp *vpcv1alpha1.MSSClamping) []string {
+	rule := []string{"-p", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-j", "TCPMSS"}
+	if clamp.MSS != 0 {
+		return append(rule, "--set-mss", strconv.Itoa(int(clamp.MSS)))
+	}
+	return append(rule, "--clamp-mss-to-pmtu")
+}
+
+// mssJumpRules returns the rules sending the TCP SYNs forwarded through a link to its chain
+func mssJumpRules(linkName string) [][]string {
+	return [][]string{
+		{"-o", linkName, "-p", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-j", mssChain(linkName)},
+		{"-i", linkName, "-p", "tcp", "--tcp-flags", "SYN,RST", "SYN", "-j", mssChain(linkName)},
+	}
+}
+
+// syncMSSClamping installs the MSS clamping of the TCP connections forwarded through a link in a dedicated chain,
+// for IPv4 and IPv6, or removes it when clamp is nil
+func syncMSSClamping(linkName string, clamp *vpcv1alpha1.MSSClamping) error {
+	for _, proto := range []iptables.Protocol{iptables.ProtocolIPv4, iptables.ProtocolIPv6} {
+		ipt, err := iptables.NewWithProtocol(proto)
+		if err != nil {
+			return err
+		}
+
+		if clamp == nil {
+			err = removeMSSClamping(ipt, linkName)
+		} else {
+			err = addMSSClamping(ipt, linkName, clamp)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addMSSClamping(ipt *iptables.IPTables, linkName string, clamp *vpcv1alpha1.MSSClamping) error {
+	chain := mssChain(linkName)
+	exists, err := ipt.ChainExists(mssTable, chain)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = ipt.NewChain(mssTable, chain)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the chain only holds the clamping rule, rebuilt when the MSS changes
+	rule := mssRule(clamp)
+	exists, err = ipt.Exists(mssTable, chain, rule...)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = ipt.ClearChain(mssTable, chain)
+		if err != nil {
+			return err
+		}
+		err = ipt.Append(mssTable, chain, rule...)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, jumpRule := range mssJumpRules(linkName) {
+		err = ipt.AppendUnique(mssTable, mssParentChain, jumpRule...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeMSSClamping(ipt *iptables.IPTables, linkName string) error {
+	for _, jumpRule := range mssJumpRules(linkName) {
+		err := ipt.DeleteIfExists(mssTable, mssParentChain, jumpRule...)
+		if err != nil {
+			return err
+		}
+	}
+
+	chain := mssChain(linkName)
+	exists, err := ipt.ChainExists(mssTable, chain)
+	if err != nil || !exists {
+		return err
+	}
+	err = ipt.ClearChain(mssTable, chain)
+	if err != nil {
+		return err
+	}
+	return ipt.DeleteChain(mssTable, chain)
+}
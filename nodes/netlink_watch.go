@@ -0,0 +1,185 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/vishvananda/netlink"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// DefaultNetlinkWatchRetryInterval is the default time waited before subscribing again to the netlink
+// notifications after a subscription failed
+const DefaultNetlinkWatchRetryInterval = time.Second * 5
+
+// NetlinkWatch triggers a reconcile of the NetworkInterfaces whose link, addresses or routes installed by
+// the node daemon are changed, as notified by the kernel
+type NetlinkWatch struct {
+	Log logr.Logger
+	// RetryInterval is the time waited before subscribing again after a subscription failed
+	RetryInterval time.Duration
+
+	events chan event.GenericEvent
+}
+
+// NewNetlinkWatch returns a NetlinkWatch
+func NewNetlinkWatch(log logr.Logger) *NetlinkWatch {
+	return &NetlinkWatch{
+		Log:           log,
+		RetryInterval: DefaultNetlinkWatchRetryInterval,
+		events:        make(chan event.GenericEvent),
+	}
+}
+
+// Events returns a channel receiving an event named after the link for each notification
+func (w *NetlinkWatch) Events() <-chan event.GenericEvent {
+	return w.events
+}
+
+// Start watches the netlink notifications until stop is closed, subscribing again when a subscription fails
+func (w *NetlinkWatch) Start(stop <-chan struct{}) error {
+	for {
+		err := w.watch(stop)
+		if err == nil {
+			return nil
+		}
+		w.Log.Error(err, fmt.Sprintf("netlink subscription failed, subscribing again in %s", w.RetryInterval))
+		select {
+		case <-time.After(w.RetryInterval):
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// watch forwards the link, address and route notifications until stop is closed, returning nil,
+// or a subscription fails
+func (w *NetlinkWatch) watch(stop <-chan struct{}) error {
+	done := make(chan struct{})
+	errs := make(chan error, 3)
+	onError := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	// the channels are left nil until subscribed, so that only the subscribed ones are drained
+	var links chan netlink.LinkUpdate
+	var addrs chan netlink.AddrUpdate
+	var routes chan netlink.RouteUpdate
+	defer func() {
+		close(done)
+		// the subscriptions close their channel once they noticed done is closed
+		go drain(links, addrs, routes)
+	}()
+
+	links = make(chan netlink.LinkUpdate)
+	err := netlink.LinkSubscribeWithOptions(links, done, netlink.LinkSubscribeOptions{ErrorCallback: onError})
+	if err != nil {
+		links = nil
+		return fmt.Errorf("unable to subscribe to link notifications: %w", err)
+	}
+	addrs = make(chan netlink.AddrUpdate)
+	err = netlink.AddrSubscribeWithOptions(addrs, done, netlink.AddrSubscribeOptions{ErrorCallback: onError})
+	if err != nil {
+		addrs = nil
+		return fmt.Errorf("unable to subscribe to address notifications: %w", err)
+	}
+	routes = make(chan netlink.RouteUpdate)
+	err = netlink.RouteSubscribeWithOptions(routes, done, netlink.RouteSubscribeOptions{ErrorCallback: onError})
+	if err != nil {
+		routes = nil
+		return fmt.Errorf("unable to subscribe to route notifications: %w", err)
+	}
+
+	for {
+		var linkName string
+		select {
+		case update, ok := <-links:
+			if !ok {
+				return fmt.Errorf("link subscription closed")
+			}
+			linkName = update.Attrs().Name
+		case update, ok := <-addrs:
+			if !ok {
+				return fmt.Errorf("address subscription closed")
+			}
+			linkName = linkNameByIndex(update.LinkIndex)
+		case update, ok := <-routes:
+			if !ok {
+				return fmt.Errorf("route subscription closed")
+			}
+			// the routes of the kernel and of the router advertisements are not synced
+			if !nics.IsOwned(update.Route) {
+				continue
+			}
+			linkName = linkNameByIndex(update.LinkIndex)
+		case err := <-errs:
+			return err
+		case <-stop:
+			return nil
+		}
+		if linkName == "" {
+			continue
+		}
+
+		select {
+		case w.events <- event.GenericEvent{
+			Meta:   &metav1.ObjectMeta{Name: linkName},
+			Object: &metav1.PartialObjectMetadata{},
+		}:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// linkNameByIndex returns the name of the link with the given index, empty if it is gone
+func linkNameByIndex(index int) string {
+	link, err := netlink.LinkByIndex(index)
+	if err != nil {
+		return ""
+	}
+	return link.Attrs().Name
+}
+
+// drain empties the channels of the subscriptions until they are closed
+func drain(links chan netlink.LinkUpdate, addrs chan netlink.AddrUpdate, routes chan netlink.RouteUpdate) {
+	for links != nil || addrs != nil || routes != nil {
+		select {
+		case _, ok := <-links:
+			if !ok {
+				links = nil
+			}
+		case _, ok := <-addrs:
+			if !ok {
+				addrs = nil
+			}
+		case _, ok := <-routes:
+			if !ok {
+				routes = nil
+			}
+		}
+	}
+}
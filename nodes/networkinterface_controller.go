@@ -17,20 +17,31 @@ limitations under the License.
 package nodes
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/go-logr/logr"
-	instance "github.com/scaleway/scaleway-sdk-go/api/instance/v1"
 	"github.com/vishvananda/netlink"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -39,26 +50,169 @@ import (
 
 	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
 	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/bgp"
 	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/resources"
 )
 
+const (
+	// routeSourceSpec and routeSourceBGP are the sources of the routes of a NetworkInterface
+	routeSourceSpec = "spec"
+	routeSourceBGP  = "bgp"
+
+	// minBurst is the minimum burst of a rate limit, to let full sized packets through
+	minBurst = 1500
+
+	// gatewayProbeInterval is the interval after which unreachable gateways are probed again
+	gatewayProbeInterval = time.Second * 30
+
+	// missingLinkInterval is the interval after which a missing link is looked up again
+	missingLinkInterval = time.Second * 30
+
+	// nicAvailableInterval is the interval after which a private NIC not available is checked again
+	nicAvailableInterval = time.Second * 5
+
+	// linkUpInterval is the interval after which a link not up is checked again
+	linkUpInterval = time.Second * 5
+
+	// backupRoutesInterval is the interval after which the links backed up by routes are checked again
+	backupRoutesInterval = time.Second * 5
+
+	// defaultRouteMetricOffset is how much higher than the metric of the node default route the default routes
+	// of the PrivateNetworks are installed in the main table by default
+	defaultRouteMetricOffset = 100
+
+	// conflictRequeueDelay is the delay after which an object modified during its reconcile is reconciled again,
+	// letting the cache catch up with the latest version
+	conflictRequeueDelay = time.Millisecond * 100
+)
+
+// routeSourcePriority is the priority of the sources of the routes to the same destination
+// and with the same metric, the lowest wins
+var routeSourcePriority = map[string]int{
+	routeSourceSpec: 0,
+	routeSourceBGP:  1,
+}
+
 // NetworkInterfaceReconciler reconciles a NetworkInterface object (part running on all nodes)
 type NetworkInterfaceReconciler struct {
 	client.Client
 	Log         logr.Logger
 	Scheme      *runtime.Scheme
-	MetadataAPI *instance.MetadataAPI
+	MetadataAPI MetadataGetter
 	NodeName    string
 	NICs        *nics.NICs
+
+	// APIReader reads the Node from the API server, as the manager doesn't cache the Nodes,
+	// the Client being used when nil
+	APIReader client.Reader
+
+	// NICStates provides the state of the private NICs, the NICs are configured once available, nil disables it
+	NICStates NICStateGetter
+
+	// RequeueInterval is the interval after which a successfully reconciled NetworkInterface
+	// is reconciled again to correct any drift of its link, 0 disables it
+	RequeueInterval time.Duration
+
+	// DisablePrivateNetworkMetricsLabel leaves the private_network label of the metrics empty,
+	// for clusters with too many private networks
+	DisablePrivateNetworkMetricsLabel bool
+
+	// GoBGP provides the routes learned by a local gobgp speaker, nil disables it
+	GoBGP *bgp.GoBGP
+
+	// Resync triggers a reconcile of all the NetworkInterfaces of the node, nil disables it
+	Resync *SignalResync
+
+	// LinkAliasTemplate renders the alias set on the links and reported in the LinkAlias of the NetworkInterfaces,
+	// nil disables it
+	LinkAliasTemplate *template.Template
+
+	// NetlinkWatch triggers a reconcile of the NetworkInterfaces whose link is changed on the node, nil disables it
+	NetlinkWatch *NetlinkWatch
+
+	// PrimaryIPs are the addresses of the primary interface of the node reported by the metadata,
+	// a NetworkInterface resolving to a link holding one of them is not managed
+	PrimaryIPs []net.IP
+
+	Recorder record.EventRecorder
+
+	// DADProbeCount is the number of ARP duplicate address detection probes sent before adding
+	// a static IPv4 address, 0 disables the detection
+	DADProbeCount int
+	// DADTimeout is the time waited for a reply to the duplicate address detection probes
+	DADTimeout time.Duration
+
+	// LinkUpTimeout is the time waited for a configured link to be operationally up before installing
+	// its routes, 0 disables the check
+	LinkUpTimeout time.Duration
+
+	// StrictSysctls fails the reconcile of a NetworkInterface whose sysctls can't be applied,
+	// instead of configuring it without them
+	StrictSysctls bool
+
+	// Watchdog detects the stalled reconciles, nil disables it
+	Watchdog *Watchdog
+
+	// StatusStream streams the material changes of the status of the NetworkInterfaces, nil disables it
+	StatusStream *StatusStream
+
+	// SlowReconcileThreshold is the duration above which a reconcile is logged with the duration of its steps,
+	// 0 disables it
+	SlowReconcileThreshold time.Duration
+
+	// RuntimeConfig overrides RequeueInterval, LinkUpTimeout, SlowReconcileThreshold and DADProbeCount
+	// with the settings reloaded from a ConfigMap, nil disables it
+	RuntimeConfig *RuntimeConfig
+
+	// PenaltyBox delays the retries of the NetworkInterfaces failing repeatedly, nil keeps the default rate limiter
+	PenaltyBox *PenaltyBox
+
+	cleanupOnce sync.Once
+
+	// nodeCondition is the last PrivateNetworkReady condition set on the node
+	nodeCondition *nodeCondition
 }
 
-// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;update
-// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;update
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=vpc.scaleway.com,resources=networkinterfaces/status,verbs=get;patch
 // +kubebuilder:rbac:groups=vpc.scaleway.com,resources=privatenetworks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get
+// +kubebuilder:rbac:groups="",resources=nodes/status,verbs=patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 
 func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
 	log := r.Log.WithValues("networkinterface", req.NamespacedName)
+	ctx := context.Background()
+	slowReconcileThreshold := r.settings().SlowReconcileThreshold
+	var steps *reconcileSteps
+	if slowReconcileThreshold != 0 {
+		steps = newReconcileSteps(time.Now())
+		ctx = withReconcileSteps(ctx, steps)
+	}
+	res, err := r.reconcile(ctx, log, req)
+	if steps != nil {
+		duration, breakdown := steps.breakdown(time.Now())
+		if duration > slowReconcileThreshold {
+			log.Info(fmt.Sprintf("slow reconcile, took %s", duration.Round(time.Millisecond)), "steps", breakdown)
+			slowReconcileTotal.Inc()
+		}
+	}
+	return requeueOnConflict(log, res, err)
+}
+
+func (r *NetworkInterfaceReconciler) reconcile(ctx context.Context, log logr.Logger, req ctrl.Request) (ctrl.Result, error) {
+	if r.Watchdog != nil {
+		defer r.Watchdog.Begin(req.Name)()
+	}
+
+	// the reconciles are not concurrent, so no rule is being added during the cleanup
+	r.cleanupOnce.Do(func() {
+		r.cleanupOrphanRules(ctx)
+	})
+	defer r.updateNodeCondition(ctx, log)
 
 	nic := &vpcv1alpha1.NetworkInterface{}
 
@@ -68,12 +222,116 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// the status is only changed in memory by the reconcile, then patched once at its end
+	status := nic.Status.DeepCopy()
+	finalizing := !nic.ObjectMeta.GetDeletionTimestamp().IsZero() && controllerutil.ContainsFinalizer(nic, constants.FinalizerName)
+
+	err = r.checkTarget(ctx, log, nic)
+	if err != nil {
+		log.Error(err, "unable to check networkInterface target")
+		return ctrl.Result{}, err
+	}
+
 	if nic.Spec.NodeName != r.NodeName {
 		return ctrl.Result{}, nil
 	}
 
+	if nic.Annotations[constants.PauseReconcileAnnotation] == "true" {
+		log.Info("reconciliation paused, skipping")
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfacePaused, corev1.ConditionTrue, "PauseAnnotation", fmt.Sprintf("%s annotation is set", constants.PauseReconcileAnnotation))
+		err = r.patchStatus(ctx, nic, status)
+		if err != nil {
+			log.Error(err, "unable to update status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfacePaused) {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfacePaused, corev1.ConditionFalse, "Resumed", "")
+	}
+
+	start := time.Now()
+	res, err := r.reconcileNetworkInterface(ctx, log, nic)
+	reconcileDuration.WithLabelValues(r.metricsPrivateNetwork(nic)).Observe(time.Since(start).Seconds())
+	reconcileTotal.WithLabelValues(r.metricsPrivateNetwork(nic), metricsResult(err)).Inc()
+	if err == nil && !nic.ObjectMeta.GetDeletionTimestamp().IsZero() && !controllerutil.ContainsFinalizer(nic, constants.FinalizerName) {
+		r.deleteMetrics(nic)
+	}
+	if err != nil && !apierrors.IsConflict(err) && nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, "ReconcileError", err.Error())
+	}
+	stepsFrom(ctx).step("status")
+	statusErr := r.patchStatus(ctx, nic, status)
+	if statusErr != nil {
+		log.Error(statusErr, "unable to update status")
+		if err == nil {
+			return res, statusErr
+		}
+	}
+	r.publishStatus(nic, status, finalizing && !controllerutil.ContainsFinalizer(nic, constants.FinalizerName))
+	if !apierrors.IsConflict(err) && nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		annotationErr := r.updateLastError(ctx, nic, err)
+		if annotationErr != nil {
+			log.Error(annotationErr, "unable to update last error annotation")
+		}
+	}
+	return res, err
+}
+
+// requeueOnConflict turns the conflicts on the update of an outdated object into a requeue without backoff,
+// the next reconcile reading the latest version of the object
+func requeueOnConflict(log logr.Logger, res ctrl.Result, err error) (ctrl.Result, error) {
+	if apierrors.IsConflict(err) {
+		log.V(1).Info("object modified concurrently, requeuing", "error", err.Error())
+		return ctrl.Result{RequeueAfter: conflictRequeueDelay}, nil
+	}
+	return res, err
+}
+
+// checkTarget logs the NetworkInterfaces targeting nodes inconsistently with their mac address or labels,
+// which happens when NetworkInterfaces are created with duplicated names or mac addresses
+func (r *NetworkInterfaceReconciler) checkTarget(ctx context.Context, log logr.Logger, nic *vpcv1alpha1.NetworkInterface) error {
+	if nic.Spec.NodeName != r.NodeName {
+		if _, ok := r.NICs.Links[nic.Status.MacAddress]; ok && nic.Status.MacAddress != "" {
+			log.Error(fmt.Errorf("networkInterface targets node %s but its mac address %s belongs to node %s", nic.Spec.NodeName, nic.Status.MacAddress, r.NodeName),
+				"inconsistent networkInterface target, check for duplicated networkInterfaces")
+		}
+		return nil
+	}
+
+	if nodeLabel, ok := nic.Labels[constants.NodeLabel]; ok && nodeLabel != r.NodeName {
+		log.Error(fmt.Errorf("networkInterface targets node %s but its %s label is %s", r.NodeName, constants.NodeLabel, nodeLabel),
+			"inconsistent networkInterface target, check for duplicated networkInterfaces")
+	}
+
+	if nic.Status.MacAddress == "" {
+		return nil
+	}
+
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: r.NodeName,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	for _, other := range nicsList.Items {
+		if other.Name != nic.Name && nics.MACEqual(other.Status.MacAddress, nic.Status.MacAddress) {
+			log.Error(fmt.Errorf("networkInterfaces %s and %s both target mac address %s on node %s", nic.Name, other.Name, nic.Status.MacAddress, r.NodeName),
+				"inconsistent networkInterface target, check for duplicated networkInterfaces")
+		}
+	}
+	return nil
+}
+
+// reconcileNetworkInterface configures the link of a NetworkInterface of the node, or tears it down on deletion
+func (r *NetworkInterfaceReconciler) reconcileNetworkInterface(ctx context.Context, log logr.Logger, nic *vpcv1alpha1.NetworkInterface) (ctrl.Result, error) {
+	steps := stepsFrom(ctx)
+	steps.step("private-network")
 	pnet := vpcv1alpha1.PrivateNetwork{}
-	err = r.Client.Get(ctx, types.NamespacedName{Name: nic.OwnerReferences[0].Name}, &pnet)
+	err := r.Client.Get(ctx, types.NamespacedName{Name: nic.OwnerReferences[0].Name}, &pnet)
 	if err != nil {
 		log.Error(err, "unable to get private network")
 		return ctrl.Result{}, err
@@ -83,31 +341,149 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		return ctrl.Result{RequeueAfter: time.Second * 1}, nil
 	}
 
+	// a link resolved by a previous reconcile is known to belong to a private NIC of the instance,
+	// the metadata is only fetched again when it can't be found
+	steps.step("link")
+	// the mac address set on the link is only kept in memory, the status holds it across restarts
+	r.NICs.TrackLinkMAC(nic.Status.MacAddress, nic.Status.LinkMAC)
+	cached := r.NICs.IsLinkCached(nic.Status.MacAddress)
+
+	primary, err := r.isPrimaryLink(nic)
+	if err != nil {
+		log.Error(err, "unable to check whether the link is the primary interface")
+		return ctrl.Result{}, err
+	}
+	if primary {
+		msg := fmt.Sprintf("link with mac address %s holds an address of the primary interface of the node, refusing to manage it, set the %s annotation to \"true\" to override",
+			nic.Status.MacAddress, constants.AllowPrimaryLinkAnnotation)
+		log.Error(fmt.Errorf("link is the primary interface"), msg)
+		r.Recorder.Event(nic, corev1.EventTypeWarning, "PrimaryLink", msg)
+	}
+
 	if !nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		// the primary interface was never configured, it is left untouched
+		if primary && controllerutil.ContainsFinalizer(nic, constants.FinalizerName) {
+			controllerutil.RemoveFinalizer(nic, constants.FinalizerName)
+			err = r.Client.Update(ctx, nic)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("failed to patch networkInterface %s", nic.Name))
+				return ctrl.Result{}, err
+			}
+		}
 		if controllerutil.ContainsFinalizer(nic, constants.FinalizerName) {
+			remaining := teardownGraceRemaining(nic, time.Now())
+			if remaining > 0 {
+				log.Info(fmt.Sprintf("keeping link for %s before tearing it down", remaining.Round(time.Second)))
+				deadline := nic.DeletionTimestamp.Add(nic.Spec.TeardownGracePeriod.Duration)
+				nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, "Terminating", fmt.Sprintf("link torn down at %s", deadline.UTC().Format(time.RFC3339)))
+				return ctrl.Result{RequeueAfter: remaining}, nil
+			}
+
+			hasDefaultRoute, err := r.NICs.HasDefaultRoute(nic.Status.MacAddress)
+			if err != nil {
+				log.Error(err, "unable to check default route")
+				return ctrl.Result{}, err
+			}
+			if hasDefaultRoute && len(nic.Status.SavedDefaultRoutes) == 0 && nic.Annotations[constants.ForceTeardownAnnotation] != "true" {
+				err := fmt.Errorf("link holds the node default route and no previous default route was saved")
+				log.Error(err, fmt.Sprintf("refusing to tear down link, set the %s annotation to \"true\" to force it", constants.ForceTeardownAnnotation))
+				return ctrl.Result{}, err
+			}
+
+			if nic.Status.IngressRateLimit != nil || nic.Status.IngressQdiscAdded {
+				err = r.NICs.RemoveIngressRateLimit(nic.Status.MacAddress, nic.Status.IngressQdiscAdded)
+				if err != nil {
+					log.Error(err, "unable to remove ingress rate limit")
+					return ctrl.Result{}, err
+				}
+			}
+
+			err = r.NICs.RestoreLinkMAC(nic.Status.MacAddress)
+			if err != nil {
+				log.Error(err, "unable to restore link mac address")
+				return ctrl.Result{}, err
+			}
+
+			if nic.Status.LinkName != "" {
+				err = syncMSSClamping(nic.Status.LinkName, nil)
+				if err != nil {
+					log.Error(err, "unable to remove mss clamping iptables rules")
+					return ctrl.Result{}, err
+				}
+			}
+
+			if nic.Status.LinkName != "" && nic.Status.SNATBackend != "" {
+				_, err = syncSNAT(nic.Status.LinkName, nil, nic.Status.SNATBackend)
+				if err != nil {
+					log.Error(err, "unable to remove snat rule")
+					return ctrl.Result{}, err
+				}
+				nic.Status.SNATBackend = ""
+			}
+
+			if nic.Status.AcceptRA != "" {
+				err = r.NICs.RestoreAcceptRA(nic.Status.MacAddress)
+				if err != nil {
+					log.Error(err, "unable to restore ipv6 router advertisements handling")
+					return ctrl.Result{}, err
+				}
+			}
+
+			if nic.Status.AcceptLocal || nic.Status.RouteLocalnet {
+				err = r.NICs.SetLocalRouting(nic.Status.MacAddress, false, false)
+				if err != nil {
+					log.Error(err, "unable to restore local routing sysctls")
+					return ctrl.Result{}, err
+				}
+			}
 
-			if pnet.Spec.IPAM == nil {
-				err := r.NICs.TearDownStaticLink(nic.Status.MacAddress, nic.Spec.Address)
+			if nic.Status.Neighbor != nil {
+				err = r.NICs.RestoreNeighborSettings(nic.Status.MacAddress)
 				if err != nil {
-					log.Error(err, "unable to configure link")
+					log.Error(err, "unable to restore neighbor table parameters")
 					return ctrl.Result{}, err
 				}
-			} else {
-				switch pnet.Spec.IPAM.Type {
-				case vpcv1alpha1.IPAMTypeStatic:
-					err := r.NICs.TearDownStaticLink(nic.Status.MacAddress, nic.Status.Address)
-					if err != nil {
-						log.Error(err, "unable to configure link")
-						return ctrl.Result{}, err
-					}
-				case vpcv1alpha1.IPAMTypeDHCP:
-					err := r.NICs.TearDownDHCPLink(nic.Status.MacAddress)
-					if err != nil {
-						log.Error(err, "unable to configure link")
-						return ctrl.Result{}, err
-					}
-				default:
-					return ctrl.Result{}, fmt.Errorf("IPAM type %s not supported", pnet.Spec.IPAM.Type)
+			}
+
+			if nic.Status.Promiscuous {
+				err = r.NICs.SetPromiscuous(nic.Status.MacAddress, false)
+				if err != nil {
+					log.Error(err, "unable to clear promiscuous mode")
+					return ctrl.Result{}, err
+				}
+			}
+
+			err = r.syncNodeInternalIP(ctx, nic, "")
+			if err != nil {
+				log.Error(err, "unable to remove node internal ip")
+				return ctrl.Result{}, err
+			}
+
+			if nic.Status.LinkAlias != "" {
+				err = r.NICs.SetLinkAlias(nic.Status.MacAddress, "")
+				if err != nil {
+					log.Error(err, "unable to remove link alias")
+					return ctrl.Result{}, err
+				}
+			}
+
+			err = r.tearDownLink(ctx, nic, &pnet)
+			linkOperationsTotal.WithLabelValues(r.metricsPrivateNetwork(nic), linkOperationTearDown, metricsResult(err)).Inc()
+			if err != nil {
+				log.Error(err, "unable to tear down link")
+				return ctrl.Result{}, err
+			}
+
+			err = r.tearDownVRF(nic)
+			if err != nil {
+				log.Error(err, "unable to tear down VRF")
+				return ctrl.Result{}, err
+			}
+
+			if hasDefaultRoute {
+				err = r.restoreDefaultRoutes(log, nic)
+				if err != nil {
+					return ctrl.Result{}, err
 				}
 			}
 
@@ -118,25 +494,110 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 				return ctrl.Result{}, err
 			}
 		}
+		return ctrl.Result{}, nil
+	}
+
+	if primary {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, "PrimaryLink", "link holds an address of the primary interface of the node")
+		return ctrl.Result{RequeueAfter: r.settings().RequeueInterval}, nil
+	}
+
+	// the link is brought up below, record whether it was down before so that its teardown
+	// can bring it back down. A link suspended in LinkDown mode was brought down by the node daemon
+	if !nic.Status.LinkBroughtUp && nic.Status.SuspendMode != vpcv1alpha1.SuspendModeLinkDown {
+		up, err := r.NICs.IsLinkAdminUp(nic.Status.MacAddress)
+		if err != nil && !nics.IsNotFound(err) {
+			log.Error(err, "unable to get link state")
+			return ctrl.Result{}, err
+		}
+		if err == nil && !up {
+			nic.Status.LinkBroughtUp = true
+		}
+	}
+
+	if nic.Spec.LinkMAC != "" {
+		err = r.NICs.SetLinkMAC(nic.Status.MacAddress, nic.Spec.LinkMAC)
+		if err != nil && !nics.IsNotFound(err) {
+			log.Error(err, fmt.Sprintf("unable to set mac address %s on link", nic.Spec.LinkMAC))
+			return ctrl.Result{}, err
+		}
+	} else {
+		err = r.NICs.RestoreLinkMAC(nic.Status.MacAddress)
+		if err != nil {
+			log.Error(err, "unable to restore link mac address")
+			return ctrl.Result{}, err
+		}
 	}
 
-	md, err := r.MetadataAPI.GetMetadata()
+	// the link is looked up on the node rather than in the metadata, so that the status
+	// is not cleared on a metadata API failure
+	resolvedLinkName, err := r.NICs.GetLinkName(nic.Status.MacAddress)
 	if err != nil {
-		log.Error(err, "unable to get metadata")
-		return ctrl.Result{}, err
+		if nics.IsDuplicateMAC(err) {
+			log.Error(err, "ambiguous link")
+			r.Recorder.Event(nic, corev1.EventTypeWarning, "DuplicateMAC", err.Error())
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, "DuplicateMAC", err.Error())
+			return ctrl.Result{}, err
+		}
+		if !nics.IsNotFound(err) {
+			log.Error(err, "unable to get link")
+			return ctrl.Result{}, err
+		}
+		log.Info(fmt.Sprintf("link with mac address %s not found on node", nic.Status.MacAddress))
+		nic.Status.LinkName = ""
+		nic.Status.LinkMAC = ""
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceLinkPresent, corev1.ConditionFalse, "LinkNotFound", fmt.Sprintf("no link with mac address %s on node", nic.Status.MacAddress))
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, "LinkNotFound", "")
+		return ctrl.Result{RequeueAfter: missingLinkInterval}, nil
+	}
+	if nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceLinkPresent) != nil {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceLinkPresent, corev1.ConditionTrue, "LinkFound", "")
 	}
+	if duplicates := r.NICs.DuplicateLinks(nic.Status.MacAddress); !cached && len(duplicates) != 0 {
+		message := fmt.Sprintf("links %s have mac address %s, using %s", strings.Join(duplicates, ", "), nic.Status.MacAddress, resolvedLinkName)
+		log.Info(message)
+		r.Recorder.Event(nic, corev1.EventTypeWarning, "DuplicateMAC", message)
+	}
+
+	steps.step("metadata")
+	if !cached {
+		md, err := r.MetadataAPI.GetMetadata()
+		if err != nil {
+			log.Error(err, "unable to get metadata")
+			return ctrl.Result{}, err
+		}
 
-	found := false
-	for _, n := range md.PrivateNICs {
-		if n.MacAddress == nic.Status.MacAddress {
-			found = true
-			break
+		found := false
+		for _, n := range md.PrivateNICs {
+			if nics.MACEqual(n.MacAddress, nic.Status.MacAddress) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			err := fmt.Errorf("nic not found on node")
+			log.Error(err, "unable to find nic")
+			return ctrl.Result{}, err
 		}
 	}
-	if !found {
-		err := fmt.Errorf("nic not found on node")
-		log.Error(err, "unable to find nic")
-		return ctrl.Result{}, err
+
+	if r.NICStates != nil && !nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceNICAvailable) {
+		states, err := r.NICStates.GetNICStates()
+		if err != nil {
+			log.Error(err, "unable to get private nic states")
+			return ctrl.Result{}, err
+		}
+		// the metadata may not expose the state of the NICs, which are then configured right away
+		if state, ok := states[nics.NormalizeMAC(nic.Status.MacAddress)]; ok {
+			if state != NICStateAvailable {
+				message := fmt.Sprintf("private nic with mac address %s is %s", nic.Status.MacAddress, state)
+				log.Info(message)
+				nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceNICAvailable, corev1.ConditionFalse, "NICNotAvailable", message)
+				nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, "NICNotAvailable", "")
+				return ctrl.Result{RequeueAfter: nicAvailableInterval}, nil
+			}
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceNICAvailable, corev1.ConditionTrue, "NICAvailable", "")
+		}
 	}
 
 	linkName, err := r.NICs.GetLinkName(nic.Status.MacAddress)
@@ -145,98 +606,1413 @@ func (r *NetworkInterfaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 		return ctrl.Result{}, err
 	}
 
-	nic.Status.LinkName = linkName
-	err = r.Client.Status().Update(ctx, nic)
+	linkMAC, err := r.NICs.GetLinkMAC(nic.Status.MacAddress)
 	if err != nil {
-		log.Error(err, "unable to update status")
+		log.Error(err, "unable to get link mac address")
 		return ctrl.Result{}, err
 	}
+	if linkMAC == nic.Status.MacAddress {
+		linkMAC = ""
+	}
 
-	if pnet.Spec.IPAM == nil {
-		err := r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Spec.Address)
+	if nic.Status.LinkName != linkName || nic.Status.LinkMAC != linkMAC {
+		nic.Status.LinkName = linkName
+		nic.Status.LinkMAC = linkMAC
+	}
+
+	if nic.Spec.Suspend {
+		return r.suspend(ctx, log, nic)
+	}
+	if nic.Status.SuspendMode != "" {
+		err = r.resume(log, nic)
 		if err != nil {
-			log.Error(err, "unable to configure link")
 			return ctrl.Result{}, err
 		}
-	} else {
-		switch pnet.Spec.IPAM.Type {
-		case vpcv1alpha1.IPAMTypeStatic:
-			err := r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Status.Address)
-			if err != nil {
-				log.Error(err, "unable to configure link")
-				return ctrl.Result{}, err
-			}
-		case vpcv1alpha1.IPAMTypeDHCP:
-			ip, err := r.NICs.ConfigureDHCPLink(nic.Status.MacAddress)
-			if err != nil {
-				log.Error(err, "unable to configure link")
-				return ctrl.Result{}, err
-			}
-			nic.Status.Address = ip
-			err = r.Client.Status().Update(ctx, nic)
-			if err != nil {
-				log.Error(err, "unable to update status")
-				return ctrl.Result{}, err
-			}
-		default:
-			return ctrl.Result{}, fmt.Errorf("IPAM type %s not supported", pnet.Spec.IPAM.Type)
-		}
 	}
 
-	ip, err := iptables.New()
+	steps.step("vrf")
+	err = r.syncVRF(nic, &pnet)
 	if err != nil {
-		log.Error(err, "unable to create iptables helper")
+		log.Error(err, "unable to sync VRF")
 		return ctrl.Result{}, err
 	}
 
-	isMasquerade, err := ip.Exists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+	// the MTU reset with the link, as after a reboot, is set back first, as configuring the link
+	// can fail until the DHCP client is running again
+	if mtu := appliedMTU(nic); mtu != 0 && dhcpOptions(&pnet).ApplyMTU {
+		steps.step("mtu")
+		_, err = r.setLinkMTU(nic, mtu)
+		if err != nil {
+			log.Error(err, "unable to set back link mtu")
+			return ctrl.Result{}, err
+		}
+	}
+
+	steps.step("configure-link")
+	err = r.configureLink(ctx, nic, &pnet)
+	linkOperationsTotal.WithLabelValues(r.metricsPrivateNetwork(nic), linkOperationConfigure, metricsResult(err)).Inc()
 	if err != nil {
-		log.Error(err, "unable to check masquerade iptables rules")
+		log.Error(err, "unable to configure link")
 		return ctrl.Result{}, err
 	}
 
-	if pnet.Spec.Masquerade && !isMasquerade {
-		err := ip.AppendUnique("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+	if linkUpTimeout := r.settings().LinkUpTimeout; linkUpTimeout != 0 {
+		steps.step("link-up")
+		up, carrier, err := r.NICs.WaitLinkUp(nic.Status.MacAddress, linkUpTimeout)
 		if err != nil {
-			log.Error(err, "unable to append masquerade iptables rule")
+			log.Error(err, "unable to get link state")
 			return ctrl.Result{}, err
 		}
+		if !up {
+			reason, message := "LinkNotUp", fmt.Sprintf("link %s not operationally up after %s", nic.Status.LinkName, linkUpTimeout)
+			if !carrier {
+				reason, message = "NoCarrier", fmt.Sprintf("link %s has no carrier after %s", nic.Status.LinkName, linkUpTimeout)
+			}
+			log.Info(message)
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceLinkUp, corev1.ConditionFalse, reason, message)
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, reason, "")
+			return ctrl.Result{RequeueAfter: linkUpInterval}, nil
+		}
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceLinkUp, corev1.ConditionTrue, "LinkUp", "")
 	}
 
-	if !pnet.Spec.Masquerade && isMasquerade {
-		err := ip.DeleteIfExists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+	steps.step("link-settings")
+	if nic.Spec.IngressRateLimit != nil {
+		ingressRateLimit, err := parseRateLimit(nic.Spec.IngressRateLimit)
 		if err != nil {
-			log.Error(err, "unable to delete masquerade iptables rule")
+			log.Error(err, "invalid ingress rate limit")
+			return ctrl.Result{}, err
+		}
+		added, err := r.NICs.SetIngressRateLimit(nic.Status.MacAddress, *ingressRateLimit)
+		if added {
+			nic.Status.IngressQdiscAdded = true
+		}
+		if err != nil {
+			log.Error(err, "unable to set ingress rate limit")
 			return ctrl.Result{}, err
 		}
+	} else if nic.Status.IngressRateLimit != nil || nic.Status.IngressQdiscAdded {
+		err = r.NICs.RemoveIngressRateLimit(nic.Status.MacAddress, nic.Status.IngressQdiscAdded)
+		if err != nil {
+			log.Error(err, "unable to remove ingress rate limit")
+			return ctrl.Result{}, err
+		}
+		nic.Status.IngressQdiscAdded = false
+	}
+	if !equality.Semantic.DeepEqual(nic.Status.IngressRateLimit, nic.Spec.IngressRateLimit) {
+		nic.Status.IngressRateLimit = nic.Spec.IngressRateLimit.DeepCopy()
 	}
 
-	routes := []nics.Route{}
-	for _, route := range pnet.Spec.Routes {
-		via := net.ParseIP(route.Via)
-		to, err := netlink.ParseIPNet(route.To)
-		if err != nil {
-			log.Error(err, fmt.Sprintf("unable to parse to route %s", route.To))
+	sysctls := sysctlFailures{strict: r.StrictSysctls}
+	err = nil
+	if nic.Spec.AcceptRA != "" {
+		err = r.NICs.SetAcceptRA(nic.Status.MacAddress, acceptRA(nic.Spec.AcceptRA))
+	} else if nic.Status.AcceptRA != "" {
+		err = r.NICs.RestoreAcceptRA(nic.Status.MacAddress)
+	}
+	if err != nil {
+		// the status keeps the applied mode, so that it is tried again
+		if err := sysctls.add(log, err, "unable to set ipv6 router advertisements handling"); err != nil {
 			return ctrl.Result{}, err
 		}
-		routes = append(routes, nics.Route{
-			To:  to,
-			Via: via,
-		})
+	} else if nic.Status.AcceptRA != nic.Spec.AcceptRA {
+		nic.Status.AcceptRA = nic.Spec.AcceptRA
 	}
 
-	err = r.NICs.SyncRoutes(nic.Status.MacAddress, routes)
+	if nic.Spec.AcceptLocal || nic.Spec.RouteLocalnet {
+		ip := parseAddress(nic.Status.Address)
+		if ip == nil || ip.To4() == nil {
+			err := fmt.Errorf("acceptLocal and routeLocalnet need an IPv4 address on the interface")
+			log.Error(err, "invalid local routing")
+			return ctrl.Result{}, err
+		}
+	}
+	err = nil
+	if nic.Spec.AcceptLocal || nic.Spec.RouteLocalnet || nic.Status.AcceptLocal || nic.Status.RouteLocalnet {
+		err = r.NICs.SetLocalRouting(nic.Status.MacAddress, nic.Spec.AcceptLocal, nic.Spec.RouteLocalnet)
+	}
 	if err != nil {
-		log.Error(err, "unable to sync routes")
-		return ctrl.Result{}, err
+		if err := sysctls.add(log, err, "unable to set local routing sysctls"); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if nic.Status.AcceptLocal != nic.Spec.AcceptLocal || nic.Status.RouteLocalnet != nic.Spec.RouteLocalnet {
+		nic.Status.AcceptLocal = nic.Spec.AcceptLocal
+		nic.Status.RouteLocalnet = nic.Spec.RouteLocalnet
+	}
+	if nic.Spec.Neighbor != nil || nic.Status.Neighbor != nil {
+		var neighbor *vpcv1alpha1.NeighborSettings
+		if nic.Spec.Neighbor != nil {
+			var effective nics.NeighborSettings
+			effective, err = r.NICs.SetNeighborSettings(nic.Status.MacAddress, neighborSettings(nic.Spec.Neighbor))
+			neighbor = neighborStatus(effective)
+		} else {
+			err = r.NICs.RestoreNeighborSettings(nic.Status.MacAddress)
+		}
+		if err != nil {
+			if err := sysctls.add(log, err, "unable to set neighbor table parameters"); err != nil {
+				return ctrl.Result{}, err
+			}
+		} else if !equality.Semantic.DeepEqual(nic.Status.Neighbor, neighbor) {
+			nic.Status.Neighbor = neighbor
+		}
+	}
+	if sysctls.setCondition(nic) {
+		r.Recorder.Event(nic, corev1.EventTypeWarning, "SysctlFailed",
+			fmt.Sprintf("sysctls of link %s not applied, the interface is configured without them: %s", nic.Status.LinkName, strings.Join(sysctls.messages, "; ")))
 	}
 
-	return ctrl.Result{}, nil
+	if nic.Spec.Promiscuous || nic.Status.Promiscuous {
+		err = r.NICs.SetPromiscuous(nic.Status.MacAddress, nic.Spec.Promiscuous)
+		if err != nil {
+			log.Error(err, "unable to set promiscuous mode")
+			return ctrl.Result{}, err
+		}
+	}
+	if nic.Spec.Promiscuous && !nic.Status.Promiscuous {
+		r.Recorder.Event(nic, corev1.EventTypeWarning, "Promiscuous",
+			fmt.Sprintf("link %s set in promiscuous mode, the node processes all the frames received on it", nic.Status.LinkName))
+	}
+	if nic.Status.Promiscuous != nic.Spec.Promiscuous {
+		nic.Status.Promiscuous = nic.Spec.Promiscuous
+	}
+
+	linkAlias := ""
+	if r.LinkAliasTemplate != nil {
+		linkAlias, err = r.linkAlias(ctx, nic, &pnet)
+		if err != nil {
+			log.Error(err, "unable to render link alias")
+			return ctrl.Result{}, err
+		}
+	}
+	if linkAlias != "" || nic.Status.LinkAlias != "" {
+		err = r.NICs.SetLinkAlias(nic.Status.MacAddress, linkAlias)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to set link alias %q", linkAlias))
+			return ctrl.Result{}, err
+		}
+	}
+	if nic.Status.LinkAlias != linkAlias {
+		nic.Status.LinkAlias = linkAlias
+	}
+
+	steps.step("masquerade")
+	ip, err := iptables.New()
+	if err != nil {
+		log.Error(err, "unable to create iptables helper")
+		return ctrl.Result{}, err
+	}
+
+	isMasquerade, err := ip.Exists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+	if err != nil {
+		log.Error(err, "unable to check masquerade iptables rules")
+		return ctrl.Result{}, err
+	}
+
+	if pnet.Spec.Masquerade && !isMasquerade {
+		err := ip.AppendUnique("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+		if err != nil {
+			log.Error(err, "unable to append masquerade iptables rule")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !pnet.Spec.Masquerade && isMasquerade {
+		err := ip.DeleteIfExists("nat", "POSTROUTING", "-o", linkName, "-j", "MASQUERADE")
+		if err != nil {
+			log.Error(err, "unable to delete masquerade iptables rule")
+			return ctrl.Result{}, err
+		}
+	}
+
+	err = syncMSSClamping(linkName, nic.Spec.ClampMSS)
+	if err != nil {
+		log.Error(err, "unable to sync mss clamping iptables rules")
+		return ctrl.Result{}, err
+	}
+
+	snatInstalled, err := syncSNAT(linkName, nic.Spec.SNAT, nic.Status.SNATBackend)
+	if nic.Status.SNATBackend != snatInstalled {
+		nic.Status.SNATBackend = snatInstalled
+	}
+	if err != nil {
+		log.Error(err, "unable to sync snat rule")
+		return ctrl.Result{}, err
+	}
+
+	steps.step("routes")
+	specRoutes := []nics.Route{}
+	skippedRoutes := []string{}
+	backupRoutes := []string{}
+	hasBackupRoutes := false
+	for _, route := range pnet.Spec.Routes {
+		parsedRoute, err := parsePrivateNetworkRoute(&pnet, route)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to parse route to %s via %s", route.To, route.Via))
+			return ctrl.Result{}, err
+		}
+		if parsedRoute.IsDefault() && route.Metric == 0 {
+			parsedRoute, err = r.defaultRouteWithMetric(nic, &pnet, parsedRoute)
+			if err != nil {
+				log.Error(err, "unable to get the node default route")
+				return ctrl.Result{}, err
+			}
+		}
+		if route.BackupFor != "" {
+			hasBackupRoutes = true
+			primaryUp, err := r.isPrivateNetworkLinkUp(ctx, route.BackupFor)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("unable to check the link of private network %s", route.BackupFor))
+				return ctrl.Result{}, err
+			}
+			if primaryUp {
+				continue
+			}
+			backupRoutes = append(backupRoutes, fmt.Sprintf("%s via %s", route.To, route.Via))
+		}
+		if pnet.Spec.ProbeGateways || route.ProbeGateway {
+			reachable, err := r.NICs.ProbeGateway(nic.Status.MacAddress, parsedRoute.Via)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("unable to probe gateway %s", route.Via))
+				return ctrl.Result{}, err
+			}
+			if !reachable {
+				log.Info(fmt.Sprintf("gateway %s unreachable, skipping route to %s", route.Via, route.To))
+				skippedRoutes = append(skippedRoutes, fmt.Sprintf("%s via %s", route.To, route.Via))
+				skippedRoutesTotal.WithLabelValues(r.metricsPrivateNetwork(nic)).Inc()
+				continue
+			}
+		}
+		specRoutes = append(specRoutes, parsedRoute)
+	}
+	gatewayRoute, err := dhcpGatewayRoute(&pnet, nic.Status.DHCPLease)
+	if err != nil {
+		log.Error(err, "invalid DHCP lease gateway")
+		return ctrl.Result{}, err
+	}
+	if gatewayRoute != nil {
+		route, err := r.defaultRouteWithMetric(nic, &pnet, *gatewayRoute)
+		if err != nil {
+			log.Error(err, "unable to get the node default route")
+			return ctrl.Result{}, err
+		}
+		specRoutes = append(specRoutes, route)
+	}
+
+	sourcedRoutes := make([]sourcedRoute, 0, len(specRoutes))
+	for _, route := range specRoutes {
+		sourcedRoutes = append(sourcedRoutes, sourcedRoute{Route: route, source: routeSourceSpec})
+	}
+	if r.GoBGP != nil {
+		for _, route := range r.bgpRoutes(log, nic) {
+			sourcedRoutes = append(sourcedRoutes, sourcedRoute{Route: route, source: routeSourceBGP})
+		}
+	}
+	routes, discarded := dedupRoutes(sourcedRoutes)
+	discardedRoutes := make([]string, 0, len(discarded))
+	for _, route := range discarded {
+		log.Info(fmt.Sprintf("route discarded: %s", route.reason))
+		discardedRoutes = append(discardedRoutes, route.reason)
+		routeConflictsTotal.WithLabelValues(r.metricsPrivateNetwork(nic), route.source).Inc()
+	}
+
+	otherRoutes, err := r.otherPrivateNetworksRoutes(ctx, nic)
+	if err != nil {
+		log.Error(err, "unable to get the routes of the other private networks of the node")
+		return ctrl.Result{}, err
+	}
+	routes, conflicts := resolveRouteConflicts(pnet.Name, routes, otherRoutes)
+	for _, conflict := range conflicts {
+		log.Info(fmt.Sprintf("route conflict: %s", conflict))
+	}
+
+	if nic.Spec.AddressFamilyPreference != "" {
+		addresses := []string{specAddress(nic)}
+		if pnet.Spec.IPAM != nil {
+			addresses = []string{nic.Status.Address, nic.Status.IPv6Address}
+		}
+		var src net.IP
+		for _, address := range addresses {
+			ip := parseAddress(address)
+			if ip != nil && addressFamily(ip) == nic.Spec.AddressFamilyPreference {
+				src = ip
+				break
+			}
+		}
+		if src != nil {
+			setDefaultRoutesSource(routes, src)
+		}
+	}
+	if nic.Spec.Gateway != nil && nic.Spec.Gateway.PreferredSource != "" {
+		src := net.ParseIP(nic.Spec.Gateway.PreferredSource)
+		if src == nil {
+			err := fmt.Errorf("invalid preferred source %s", nic.Spec.Gateway.PreferredSource)
+			log.Error(err, "invalid gateway")
+			return ctrl.Result{}, err
+		}
+		configured, err := r.NICs.HasAnyAddress(nic.Status.MacAddress, []net.IP{src})
+		if err != nil {
+			log.Error(err, "unable to list the addresses of the interface")
+			return ctrl.Result{}, err
+		}
+		if !configured {
+			err := fmt.Errorf("preferred source %s is not an address of the interface", src)
+			log.Error(err, "invalid gateway")
+			return ctrl.Result{}, err
+		}
+		setDefaultRoutesSource(routes, src)
+	}
+
+	table := int(pnet.Spec.RouteTable)
+	rules, err := resources.ParseRules(&pnet)
+	if err != nil {
+		log.Error(err, "invalid rules")
+		return ctrl.Result{}, err
+	}
+	if table != 0 {
+		sharers, err := r.routeTableSharers(ctx, nic, table)
+		if err != nil {
+			log.Error(err, "unable to get the private networks sharing the route table")
+			return ctrl.Result{}, err
+		}
+		conflicts := resources.RouteTableConflicts(&pnet, sharers)
+		if len(conflicts) != 0 {
+			err := fmt.Errorf("route table %d is also used on the node by private networks %s, set the %s annotation to \"true\" on all of them to share it",
+				table, strings.Join(conflicts, ", "), constants.AllowSharedRouteTableAnnotation)
+			log.Error(err, "route table conflict")
+			r.Recorder.Event(nic, corev1.EventTypeWarning, "RouteTableConflict", err.Error())
+			return ctrl.Result{}, err
+		}
+		// the rules of a shared table are synced together, so that they don't remove each other
+		rules = append(rules, sharedRules(sharers)...)
+	}
+
+	// default routes in another table don't shadow the node default routes
+	if table == 0 {
+		err = r.saveDefaultRoutes(ctx, nic, routes)
+		if err != nil {
+			log.Error(err, "unable to save node default routes")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if previousTable, moved := previousRouteTable(nic, table); moved {
+		err = r.leaveRouteTable(ctx, log, nic, previousTable)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to tear down previous route table %d", previousTable))
+			return ctrl.Result{}, err
+		}
+	}
+
+	if pnet.Spec.ConnectedRoutesInTable && table != 0 {
+		connectedRoutes, err := r.NICs.ConnectedRoutes(nic.Status.MacAddress)
+		if err != nil {
+			log.Error(err, "unable to get connected routes")
+			return ctrl.Result{}, err
+		}
+		// first, so that the gateways of the other routes are reachable in the table
+		routes = append(connectedRoutes, routes...)
+	}
+
+	routes, checkRoutes, err := routesToCheck(nic, &pnet, routes)
+	if err != nil {
+		log.Error(err, "unable to parse known good routes")
+		return ctrl.Result{}, err
+	}
+
+	steps.step("apply-routes")
+	results, err := r.NICs.ApplyRoutes(nic.Status.MacAddress, table, routes)
+	routeSyncTotal.WithLabelValues(r.metricsPrivateNetwork(nic), metricsResult(err)).Inc()
+	setAppliedRoutes(nic, results, table)
+	if err != nil {
+		log.Error(err, "unable to sync routes")
+		return ctrl.Result{}, err
+	}
+
+	if table != 0 {
+		err = r.NICs.SyncRules(table, rules)
+		if err != nil {
+			log.Error(err, "unable to sync rules")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if checkRoutes {
+		steps.step("route-health-check")
+		results, err := r.checkRouteHealth(nic, &pnet, table, routes)
+		if results != nil {
+			setAppliedRoutes(nic, results, table)
+		}
+		if err != nil {
+			log.Error(err, "unable to check route health")
+			return ctrl.Result{}, err
+		}
+	}
+	if int(nic.Status.RouteTable) != table {
+		nic.Status.RouteTable = int32(table)
+	}
+
+	nodeInternalIP := ""
+	if nic.Spec.UpdateNodeInternalIP {
+		if ip := parseAddress(nic.Status.Address); ip != nil && ip.To4() != nil {
+			nodeInternalIP = ip.String()
+		}
+	}
+	steps.step("node-address")
+	err = r.syncNodeInternalIP(ctx, nic, nodeInternalIP)
+	if err != nil {
+		log.Error(err, "unable to update node internal ip")
+		return ctrl.Result{}, err
+	}
+
+	nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionTrue, "Configured", "")
+	if len(skippedRoutes) != 0 {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceGatewaysReachable, corev1.ConditionFalse, "GatewayUnreachable", fmt.Sprintf("skipped routes: %s", strings.Join(skippedRoutes, ", ")))
+	} else if nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceGatewaysReachable) != nil {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceGatewaysReachable, corev1.ConditionTrue, "GatewaysReachable", "")
+	}
+	if len(conflicts) != 0 {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesConflicting, corev1.ConditionTrue, "SameMetric", strings.Join(conflicts, ", "))
+	} else if nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceRoutesConflicting) != nil {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesConflicting, corev1.ConditionFalse, "NoConflict", "")
+	}
+	if !reflect.DeepEqual(nic.Status.SkippedRoutes, skippedRoutes) && (len(nic.Status.SkippedRoutes) != 0 || len(skippedRoutes) != 0) {
+		nic.Status.SkippedRoutes = skippedRoutes
+	}
+	if !reflect.DeepEqual(nic.Status.DiscardedRoutes, discardedRoutes) && (len(nic.Status.DiscardedRoutes) != 0 || len(discardedRoutes) != 0) {
+		nic.Status.DiscardedRoutes = discardedRoutes
+	}
+	if !reflect.DeepEqual(nic.Status.BackupRoutes, backupRoutes) && (len(nic.Status.BackupRoutes) != 0 || len(backupRoutes) != 0) {
+		log.Info(fmt.Sprintf("backup routes changed: %s", strings.Join(backupRoutes, ", ")))
+		nic.Status.BackupRoutes = backupRoutes
+	}
+
+	requeueInterval := r.settings().RequeueInterval
+	if hasBackupRoutes && (requeueInterval == 0 || requeueInterval > backupRoutesInterval) {
+		return ctrl.Result{RequeueAfter: backupRoutesInterval}, nil
+	}
+	unhealthy := len(skippedRoutes) != 0 || nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesUnhealthy)
+	if unhealthy && (requeueInterval == 0 || requeueInterval > gatewayProbeInterval) {
+		return ctrl.Result{RequeueAfter: gatewayProbeInterval}, nil
+	}
+	return ctrl.Result{RequeueAfter: requeueInterval}, nil
+}
+
+// setAppliedRoutes reports the routes programmed in the given table in the status
+func setAppliedRoutes(nic *vpcv1alpha1.NetworkInterface, results []nics.RouteResult, table int) {
+	appliedRoutes, omittedRoutes := appliedRoutes(results, table, nic.Status.AppliedRoutes, time.Now())
+	if !reflect.DeepEqual(nic.Status.AppliedRoutes, appliedRoutes) || nic.Status.OmittedAppliedRoutes != omittedRoutes {
+		nic.Status.AppliedRoutes = appliedRoutes
+		nic.Status.OmittedAppliedRoutes = omittedRoutes
+	}
+}
+
+// appliedRoutes returns the routes programmed in the given table as reported in the status, nil if
+// there is none, and the number of routes left out of it. The routes installed now are stamped with
+// the given time, the others keep the time they were last installed at in the previous status
+func appliedRoutes(results []nics.RouteResult, table int, previous []vpcv1alpha1.AppliedRoute, now time.Time) ([]vpcv1alpha1.AppliedRoute, int32) {
+	if len(results) == 0 {
+		return nil, 0
+	}
+	omitted := 0
+	if len(results) > vpcv1alpha1.MaxAppliedRoutes {
+		omitted = len(results) - vpcv1alpha1.MaxAppliedRoutes
+		results = results[:vpcv1alpha1.MaxAppliedRoutes]
+	}
+	applied := make([]vpcv1alpha1.AppliedRoute, 0, len(results))
+	for _, result := range results {
+		route := vpcv1alpha1.AppliedRoute{
+			To:       result.Route.To.String(),
+			Via:      viaString(result.Route.Via),
+			Priority: int32(result.Priority),
+			Table:    int32(table),
+			Result:   vpcv1alpha1.AppliedRouteResultApplied,
+		}
+		if result.Err != nil {
+			route.Result = result.Err.Error()
+		}
+		if result.Skipped {
+			route.Result = vpcv1alpha1.AppliedRouteResultSkipped
+		}
+		if result.Applied {
+			appliedTime := metav1.NewTime(now)
+			route.LastAppliedTime = &appliedTime
+		} else {
+			for _, previousRoute := range previous {
+				if previousRoute.To == route.To && previousRoute.Via == route.Via && previousRoute.Table == route.Table {
+					route.LastAppliedTime = previousRoute.LastAppliedTime
+					break
+				}
+			}
+		}
+		applied = append(applied, route)
+	}
+	return applied, int32(omitted)
+}
+
+// viaString returns the gateway of a route as reported in the status, empty for the connected routes
+func viaString(via net.IP) string {
+	if via == nil {
+		return ""
+	}
+	return via.String()
+}
+
+// isPrivateNetworkLinkUp returns whether the link of the NetworkInterface of the given PrivateNetwork
+// on the node is up, a missing, suspended or deleted NetworkInterface being down
+func (r *NetworkInterfaceReconciler) isPrivateNetworkLinkUp(ctx context.Context, name string) (bool, error) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.PrivateNetworkLabel: name,
+			constants.NodeLabel:           r.NodeName,
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	for _, other := range nicsList.Items {
+		if other.Status.MacAddress == "" || other.Spec.Suspend || !other.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		return r.NICs.IsLinkUp(other.Status.MacAddress)
+	}
+	return false, nil
+}
+
+// isPrimaryLink returns whether the link of a NetworkInterface holds an address of the primary interface
+// of the node, which would be cut off if it was configured, unless the NetworkInterface allows it
+func (r *NetworkInterfaceReconciler) isPrimaryLink(nic *vpcv1alpha1.NetworkInterface) (bool, error) {
+	if len(r.PrimaryIPs) == 0 || nic.Annotations[constants.AllowPrimaryLinkAnnotation] == "true" {
+		return false, nil
+	}
+	primary, err := r.NICs.HasAnyAddress(nic.Status.MacAddress, r.PrimaryIPs)
+	if nics.IsNotFound(err) {
+		return false, nil
+	}
+	return primary, err
+}
+
+// suspend cuts the traffic of a NetworkInterface according to its suspend mode: its routes are removed,
+// the node default routes it shadowed are restored and, in LinkDown mode, its link is brought down
+func (r *NetworkInterfaceReconciler) suspend(ctx context.Context, log logr.Logger, nic *vpcv1alpha1.NetworkInterface) (ctrl.Result, error) {
+	mode := nic.Spec.SuspendMode
+	if mode == "" {
+		mode = vpcv1alpha1.SuspendModeRouteFlush
+	}
+
+	hasDefaultRoute, err := r.NICs.HasDefaultRoute(nic.Status.MacAddress)
+	if err != nil {
+		log.Error(err, "unable to check default route")
+		return ctrl.Result{}, err
+	}
+
+	err = r.NICs.FlushRoutes(nic.Status.MacAddress, int(nic.Status.RouteTable))
+	if err != nil {
+		log.Error(err, "unable to flush routes")
+		return ctrl.Result{}, err
+	}
+
+	if hasDefaultRoute {
+		err = r.restoreDefaultRoutes(log, nic)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	switch {
+	case mode == vpcv1alpha1.SuspendModeLinkDown:
+		err = r.NICs.SetLinkDown(nic.Status.MacAddress)
+		if err != nil {
+			log.Error(err, "unable to bring link down")
+			return ctrl.Result{}, err
+		}
+	case nic.Status.SuspendMode == vpcv1alpha1.SuspendModeLinkDown:
+		err = r.NICs.SetLinkUp(nic.Status.MacAddress)
+		if err != nil {
+			log.Error(err, "unable to bring link up")
+			return ctrl.Result{}, err
+		}
+	}
+
+	updated := nic.Status.SuspendMode != mode || len(nic.Status.AppliedRoutes) != 0 || nic.Status.OmittedAppliedRoutes != 0
+	nic.Status.SuspendMode = mode
+	nic.Status.AppliedRoutes = nil
+	nic.Status.OmittedAppliedRoutes = 0
+	updated = nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceSuspended, corev1.ConditionTrue, string(mode), "") || updated
+	updated = nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, "Suspended", "") || updated
+	if updated {
+		log.Info(fmt.Sprintf("suspended link in %s mode", mode))
+	}
+	return ctrl.Result{RequeueAfter: r.settings().RequeueInterval}, nil
+}
+
+// resume brings back up the link of a NetworkInterface suspended in LinkDown mode,
+// its routes being installed again by the rest of the reconcile
+func (r *NetworkInterfaceReconciler) resume(log logr.Logger, nic *vpcv1alpha1.NetworkInterface) error {
+	if nic.Status.SuspendMode == vpcv1alpha1.SuspendModeLinkDown {
+		err := r.NICs.SetLinkUp(nic.Status.MacAddress)
+		if err != nil {
+			log.Error(err, "unable to bring link up")
+			return err
+		}
+	}
+
+	log.Info("resumed link")
+	nic.Status.SuspendMode = ""
+	nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceSuspended, corev1.ConditionFalse, "Resumed", "")
+	return nil
+}
+
+// restoreDefaultRoutes restores the node default routes saved before the NetworkInterface shadowed them
+func (r *NetworkInterfaceReconciler) restoreDefaultRoutes(log logr.Logger, nic *vpcv1alpha1.NetworkInterface) error {
+	for _, route := range nic.Status.SavedDefaultRoutes {
+		err := r.NICs.RestoreDefaultRoute(nics.DefaultRoute{
+			Family:   defaultRouteFamily(route),
+			Via:      net.ParseIP(route.Via),
+			LinkName: route.LinkName,
+		})
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to restore default route via %s on %s", route.Via, route.LinkName))
+			return err
+		}
+	}
+	return nil
+}
+
+// configureLink configures the addresses of the link of a NetworkInterface according to the IPAM of its PrivateNetwork
+func (r *NetworkInterfaceReconciler) configureLink(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) error {
+	if pnet.Spec.IPAM == nil {
+		return r.configureStaticLink(nic, pnet, specAddress(nic))
+	}
+
+	switch pnet.Spec.IPAM.Type {
+	case vpcv1alpha1.IPAMTypeStatic:
+		return r.configureStaticLink(nic, pnet, staticAddresses(nic)...)
+	case vpcv1alpha1.IPAMTypeDHCP:
+		ip, err := r.NICs.ConfigureDHCPLink(nic.Status.MacAddress)
+		if err == nil {
+			lease, leaseErr := r.NICs.GetDHCPLease(nic.Status.MacAddress)
+			if leaseErr != nil {
+				return leaseErr
+			}
+			if lease != nil {
+				nic.Status.Address = ip
+				nic.Status.DHCPLease = dhcpLeaseStatus(lease)
+				return r.syncDHCPMTU(ctx, nic, pnet)
+			}
+			err = fmt.Errorf("no DHCP lease on the link")
+		}
+		// the lease is lost, what was applied from it is removed until a new one is obtained
+		if nic.Status.DHCPLease != nil {
+			removeErr := r.removeDHCPLease(nic, pnet)
+			if removeErr != nil {
+				return removeErr
+			}
+		}
+		return err
+	default:
+		return fmt.Errorf("IPAM type %s not supported", pnet.Spec.IPAM.Type)
+	}
+}
+
+// configureStaticLink configures the static addresses of the link of a NetworkInterface,
+// after checking that no other host already answers for its new IPv4 addresses
+// The addresses it configured before and that are replaced are removed, and the configured ones recorded
+// With NoPrefixRoute, the addresses are added without their connected routes in the main table
+func (r *NetworkInterfaceReconciler) configureStaticLink(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork, addresses ...string) error {
+	if r.settings().DADProbeCount != 0 {
+		err := r.detectDuplicateAddresses(nic, addresses)
+		if err != nil {
+			return err
+		}
+	}
+	var err error
+	if pnet.Spec.NoPrefixRoute {
+		err = r.NICs.ConfigureStaticLinkNoPrefixRoute(nic.Status.MacAddress, nic.Status.ConfiguredAddresses, addresses...)
+	} else {
+		err = r.NICs.ConfigureStaticLink(nic.Status.MacAddress, nic.Status.ConfiguredAddresses, addresses...)
+	}
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(nic.Status.ConfiguredAddresses, addresses) {
+		nic.Status.ConfiguredAddresses = addresses
+	}
+	return nil
+}
+
+// detectDuplicateAddresses probes the IPv4 addresses not yet on the link of a NetworkInterface,
+// and returns an error and sets the DuplicateAddress condition if another host answers for one of them
+// IPv6 addresses rely on the kernel duplicate address detection
+func (r *NetworkInterfaceReconciler) detectDuplicateAddresses(nic *vpcv1alpha1.NetworkInterface, addresses []string) error {
+	duplicates := []string{}
+	for _, address := range addresses {
+		ip := parseAddress(address)
+		if ip == nil || ip.To4() == nil {
+			continue
+		}
+
+		assigned, err := r.NICs.HasAnyAddress(nic.Status.MacAddress, []net.IP{ip})
+		if err != nil {
+			return err
+		}
+		if assigned {
+			continue
+		}
+
+		duplicate, err := r.NICs.ProbeDuplicateAddress(nic.Status.MacAddress, ip, r.settings().DADProbeCount, r.DADTimeout)
+		if err != nil {
+			return fmt.Errorf("unable to probe address %s: %w", ip, err)
+		}
+		if duplicate {
+			duplicates = append(duplicates, ip.String())
+		}
+	}
+
+	if len(duplicates) != 0 {
+		msg := fmt.Sprintf("another host answers for %s", strings.Join(duplicates, ", "))
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceDuplicateAddress, corev1.ConditionTrue, "ARPReply", msg)
+		return fmt.Errorf("refusing to add duplicate addresses: %s", msg)
+	}
+
+	if nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceDuplicateAddress) != nil {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceDuplicateAddress, corev1.ConditionFalse, "NoDuplicate", "")
+	}
+	return nil
+}
+
+// tearDownLink tears down the link of a NetworkInterface according to the IPAM of its PrivateNetwork:
+// the routes and rules of its route table first, then its routes in the main table, then its addresses,
+// and it is brought down last according to its TeardownLinkDown policy
+func (r *NetworkInterfaceReconciler) tearDownLink(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) error {
+	err := r.tearDownRouteTable(ctx, nic)
+	if err != nil {
+		return err
+	}
+
+	if pnet.Spec.IPAM == nil {
+		err = r.NICs.FlushStaticLink(nic.Status.MacAddress, specAddress(nic))
+	} else {
+		switch pnet.Spec.IPAM.Type {
+		case vpcv1alpha1.IPAMTypeStatic:
+			err = r.NICs.FlushStaticLink(nic.Status.MacAddress, staticAddresses(nic)...)
+		case vpcv1alpha1.IPAMTypeDHCP:
+			if nic.Status.SavedMTU != 0 {
+				err = r.NICs.SetLinkMTU(nic.Status.MacAddress, nic.Status.SavedMTU)
+				if err != nil {
+					return err
+				}
+			}
+			err = r.NICs.FlushDHCPLink(nic.Status.MacAddress)
+		default:
+			return fmt.Errorf("IPAM type %s not supported", pnet.Spec.IPAM.Type)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if !teardownLinkDown(nic) {
+		return nil
+	}
+	err = r.NICs.SetLinkDown(nic.Status.MacAddress)
+	if err != nil && !nics.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// teardownLinkDown returns whether the link of a deleted NetworkInterface is brought down,
+// by default only if the node daemon brought it up and the teardown policy is not FlushConfig
+func teardownLinkDown(nic *vpcv1alpha1.NetworkInterface) bool {
+	switch nic.Spec.TeardownLinkDown {
+	case vpcv1alpha1.TeardownLinkDownAlways:
+		return true
+	case vpcv1alpha1.TeardownLinkDownNever:
+		return false
+	case vpcv1alpha1.TeardownLinkDownIfBroughtUp:
+		return nic.Status.LinkBroughtUp
+	}
+	if nic.Spec.TeardownPolicy == vpcv1alpha1.TeardownPolicyFlushConfig {
+		return false
+	}
+	return nic.Status.LinkBroughtUp
+}
+
+// teardownGraceRemaining returns how long the link of a deleted NetworkInterface is still kept before it is torn down
+func teardownGraceRemaining(nic *vpcv1alpha1.NetworkInterface, now time.Time) time.Duration {
+	if nic.Spec.TeardownGracePeriod == nil || nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+		return 0
+	}
+	return nic.DeletionTimestamp.Add(nic.Spec.TeardownGracePeriod.Duration).Sub(now)
+}
+
+// settings returns the runtime settings in effect, the ones of the RuntimeConfig if any
+func (r *NetworkInterfaceReconciler) settings() RuntimeSettings {
+	if r.RuntimeConfig != nil {
+		return r.RuntimeConfig.Settings()
+	}
+	return RuntimeSettings{
+		RequeueInterval:        r.RequeueInterval,
+		LinkUpTimeout:          r.LinkUpTimeout,
+		SlowReconcileThreshold: r.SlowReconcileThreshold,
+		DADProbeCount:          r.DADProbeCount,
+	}
+}
+
+// acceptRA returns how the IPv6 router advertisements are handled in the given mode
+func acceptRA(mode vpcv1alpha1.AcceptRAMode) nics.AcceptRA {
+	return nics.AcceptRA{
+		Accept:       mode != vpcv1alpha1.AcceptRAOff,
+		Autoconf:     mode == vpcv1alpha1.AcceptRAOn || mode == vpcv1alpha1.AcceptRANoDefaultRoute,
+		DefaultRoute: mode == vpcv1alpha1.AcceptRAOn || mode == vpcv1alpha1.AcceptRARouteOnly,
+	}
+}
+
+// neighborSettings returns the neighbor table parameters of the spec of a NetworkInterface
+func neighborSettings(spec *vpcv1alpha1.NeighborSettings) nics.NeighborSettings {
+	return nics.NeighborSettings{
+		BaseReachableTime: time.Duration(spec.BaseReachableTimeMs) * time.Millisecond,
+		GCStaleTime:       time.Duration(spec.GCStaleTimeSeconds) * time.Second,
+		MCastSolicit:      int(spec.MCastSolicit),
+	}
+}
+
+// neighborStatus returns the effective neighbor table parameters reported in the status of a NetworkInterface
+func neighborStatus(settings nics.NeighborSettings) *vpcv1alpha1.NeighborSettings {
+	return &vpcv1alpha1.NeighborSettings{
+		BaseReachableTimeMs: int32(settings.BaseReachableTime / time.Millisecond),
+		GCStaleTimeSeconds:  int32(settings.GCStaleTime / time.Second),
+		MCastSolicit:        int32(settings.MCastSolicit),
+	}
+}
+
+// defaultRouteWithMetric returns a default route of a PrivateNetwork without an explicit metric with the
+// DefaultRouteMetric of the PrivateNetwork or, in the main table, with a metric above the node default route,
+// so that installing it never takes over the node egress
+func (r *NetworkInterfaceReconciler) defaultRouteWithMetric(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork, route nics.Route) (nics.Route, error) {
+	if pnet.Spec.DefaultRouteMetric != nil {
+		route.Metric = int(*pnet.Spec.DefaultRouteMetric)
+		return route, nil
+	}
+	if pnet.Spec.RouteTable != 0 {
+		return route, nil
+	}
+	family := netlink.FAMILY_V4
+	if route.To.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	nodeDefaultRoute, err := r.NICs.GetDefaultRoute(nic.Status.MacAddress, family)
+	if err != nil {
+		return nics.Route{}, err
+	}
+	return route.AboveDefaultRoute(nodeDefaultRoute, defaultRouteMetricOffset), nil
+}
+
+// parsePrivateNetworkRoute parses a route of a PrivateNetwork with its metric and, for an IPv4 route, its realm
+func parsePrivateNetworkRoute(pnet *vpcv1alpha1.PrivateNetwork, route vpcv1alpha1.PrivateNetworkRoute) (nics.Route, error) {
+	parsedRoute, err := nics.ParseRoute(route.To, route.Via)
+	if err != nil {
+		return nics.Route{}, err
+	}
+	parsedRoute.Metric = int(pnet.Spec.RouteMetric)
+	parsedRoute.Pref = strings.ToLower(string(route.Pref))
+	parsedRoute.OnConflict = string(pnet.Spec.RouteConflictPolicy)
+	if route.Metric != 0 {
+		parsedRoute.Metric = int(route.Metric)
+	}
+	if parsedRoute.To.IP.To4() != nil {
+		parsedRoute.Realm = int(pnet.Spec.RouteRealm)
+		if route.Realm != 0 {
+			parsedRoute.Realm = int(route.Realm)
+		}
+	}
+	return parsedRoute, nil
+}
+
+// bgpRoutes returns the routes learned by gobgp with a next hop on the link of the NetworkInterface
+// Errors are only logged, so that the BGP integration can't break the reconcile
+func (r *NetworkInterfaceReconciler) bgpRoutes(log logr.Logger, nic *vpcv1alpha1.NetworkInterface) []nics.Route {
+	bgpRoutes := []nics.Route{}
+	for _, route := range r.GoBGP.Routes() {
+		onLink, err := r.NICs.IsOnLink(nic.Status.MacAddress, route.Via)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to check next hop %s of bgp route to %s", route.Via, route.To))
+			continue
+		}
+		if onLink {
+			bgpRoutes = append(bgpRoutes, route)
+		}
+	}
+	return bgpRoutes
+}
+
+// sourcedRoute is a route of a NetworkInterface with the source it comes from
+type sourcedRoute struct {
+	nics.Route
+	source string
+}
+
+// discardedRoute is a route which lost against another route to the same destination
+type discardedRoute struct {
+	sourcedRoute
+	reason string
+}
+
+// dedupRoutes keeps a single route per destination: the one with the lowest metric, then from the source
+// with the highest priority, then with the lowest gateway, so that the result does not depend on the order
+// of the routes
+// It returns the kept routes, in their original order, and the discarded ones
+func dedupRoutes(routes []sourcedRoute) ([]nics.Route, []discardedRoute) {
+	best := make(map[string]int)
+	for i, route := range routes {
+		j, ok := best[route.To.String()]
+		if !ok || routeLess(route, routes[j]) {
+			best[route.To.String()] = i
+		}
+	}
+
+	kept := []nics.Route{}
+	discarded := []discardedRoute{}
+	for i, route := range routes {
+		j := best[route.To.String()]
+		if i == j {
+			kept = append(kept, route.Route)
+			continue
+		}
+		winner := routes[j]
+		reason := "same route"
+		switch {
+		case winner.Metric != route.Metric:
+			reason = fmt.Sprintf("higher metric %d than %d", route.Metric, winner.Metric)
+		case winner.source != route.source:
+			reason = fmt.Sprintf("lower priority source than %s", winner.source)
+		case !winner.Via.Equal(route.Via):
+			reason = fmt.Sprintf("higher gateway than %s", winner.Via)
+		}
+		discarded = append(discarded, discardedRoute{
+			sourcedRoute: route,
+			reason:       fmt.Sprintf("%s via %s from %s: %s", route.To, route.Via, route.source, reason),
+		})
+	}
+	return kept, discarded
+}
+
+// routeLess returns whether a route wins against another route to the same destination
+func routeLess(a, b sourcedRoute) bool {
+	if a.Metric != b.Metric {
+		return a.Metric < b.Metric
+	}
+	if routeSourcePriority[a.source] != routeSourcePriority[b.source] {
+		return routeSourcePriority[a.source] < routeSourcePriority[b.source]
+	}
+	return bytes.Compare(a.Via.To16(), b.Via.To16()) < 0
+}
+
+// otherPrivateNetworksRoutes returns the routes of the other PrivateNetworks of the node, by PrivateNetwork name
+func (r *NetworkInterfaceReconciler) otherPrivateNetworksRoutes(ctx context.Context, nic *vpcv1alpha1.NetworkInterface) (map[string][]nics.Route, error) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: r.NodeName,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	otherRoutes := make(map[string][]nics.Route)
+	for _, other := range nicsList.Items {
+		if other.Name == nic.Name || other.Spec.NodeName != r.NodeName || len(other.OwnerReferences) == 0 ||
+			!other.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		pnet := vpcv1alpha1.PrivateNetwork{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: other.OwnerReferences[0].Name}, &pnet)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, route := range pnet.Spec.Routes {
+			// the backup routes of the private network of the interface are withdrawn while it is up
+			if route.BackupFor != "" && route.BackupFor == nic.Labels[constants.PrivateNetworkLabel] {
+				continue
+			}
+			// invalid routes are reported by the NetworkInterfaces of their PrivateNetwork
+			parsedRoute, err := parsePrivateNetworkRoute(&pnet, route)
+			if err != nil {
+				continue
+			}
+			otherRoutes[pnet.Name] = append(otherRoutes[pnet.Name], parsedRoute)
+		}
+	}
+	return otherRoutes, nil
+}
+
+// resolveRouteConflicts removes the routes also claimed with the same metric by another PrivateNetwork
+// of the node, the kernel being unable to hold both: the PrivateNetwork with the first name keeps the route
+// It returns the kept routes and a description of the conflicts
+func resolveRouteConflicts(name string, routes []nics.Route, otherRoutes map[string][]nics.Route) ([]nics.Route, []string) {
+	kept := []nics.Route{}
+	conflicts := []string{}
+	for _, route := range routes {
+		claimedBy := []string{}
+		for other, otherNetworkRoutes := range otherRoutes {
+			for _, otherRoute := range otherNetworkRoutes {
+				if otherRoute.To.String() == route.To.String() && otherRoute.Metric == route.Metric {
+					claimedBy = append(claimedBy, other)
+					break
+				}
+			}
+		}
+		if len(claimedBy) == 0 {
+			kept = append(kept, route)
+			continue
+		}
+
+		sort.Strings(claimedBy)
+		if name < claimedBy[0] {
+			kept = append(kept, route)
+			conflicts = append(conflicts, fmt.Sprintf("route to %s with metric %d also claimed by %s, kept", route.To, route.Metric, strings.Join(claimedBy, ", ")))
+		} else {
+			conflicts = append(conflicts, fmt.Sprintf("route to %s with metric %d also claimed by %s, skipped", route.To, route.Metric, strings.Join(claimedBy, ", ")))
+		}
+	}
+	return kept, conflicts
+}
+
+// tearDownRouteTable removes the rules and the routes of the route table of a NetworkInterface, if any,
+// keeping the rules of the other PrivateNetworks sharing the table
+func (r *NetworkInterfaceReconciler) tearDownRouteTable(ctx context.Context, nic *vpcv1alpha1.NetworkInterface) error {
+	if nic.Status.RouteTable == 0 {
+		return nil
+	}
+
+	sharers, err := r.routeTableSharers(ctx, nic, int(nic.Status.RouteTable))
+	if err != nil {
+		return err
+	}
+	if len(sharers) != 0 {
+		err = r.NICs.SyncRules(int(nic.Status.RouteTable), sharedRules(sharers))
+	} else {
+		err = r.NICs.TearDownRules(int(nic.Status.RouteTable))
+	}
+	if err != nil {
+		return err
+	}
+
+	err = r.NICs.SyncRoutes(nic.Status.MacAddress, int(nic.Status.RouteTable), nil)
+	if err != nil && !nics.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// previousRouteTable returns the route table the routes of the NetworkInterface were last applied in, and whether
+// they have to be removed from it before being applied in the given one. The status doesn't tell apart the main
+// table from routes never applied, so leaving the main table is always assumed: it only removes the routes of the
+// node daemon, if any
+func previousRouteTable(nic *vpcv1alpha1.NetworkInterface, table int) (int, bool) {
+	previous := int(nic.Status.RouteTable)
+	return previous, previous != table
+}
+
+// leaveRouteTable removes the routes of a NetworkInterface from the previous route table before they are applied
+// in another one, restoring the node default routes they shadowed in the main table
+func (r *NetworkInterfaceReconciler) leaveRouteTable(ctx context.Context, log logr.Logger, nic *vpcv1alpha1.NetworkInterface, previous int) error {
+	if previous != 0 {
+		return r.tearDownRouteTable(ctx, nic)
+	}
+
+	err := r.NICs.SyncRoutes(nic.Status.MacAddress, 0, nil)
+	if err != nil && !nics.IsNotFound(err) {
+		return err
+	}
+	err = r.restoreDefaultRoutes(log, nic)
+	if err != nil {
+		return err
+	}
+	nic.Status.SavedDefaultRoutes = nil
+	return nil
+}
+
+// routeTableSharers returns the PrivateNetworks of the other NetworkInterfaces of the node using the given route table
+func (r *NetworkInterfaceReconciler) routeTableSharers(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, table int) ([]vpcv1alpha1.PrivateNetwork, error) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: r.NodeName,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sharers := []vpcv1alpha1.PrivateNetwork{}
+	for _, other := range nicsList.Items {
+		if other.Name == nic.Name || other.Spec.NodeName != r.NodeName || len(other.OwnerReferences) == 0 ||
+			int(other.Status.RouteTable) != table || !other.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		pnet := vpcv1alpha1.PrivateNetwork{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: other.OwnerReferences[0].Name}, &pnet)
+		if err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return nil, err
+		}
+		sharers = append(sharers, pnet)
+	}
+	return sharers, nil
+}
+
+// sharedRules returns the rules of the PrivateNetworks sharing a route table
+func sharedRules(pnets []vpcv1alpha1.PrivateNetwork) []nics.Rule {
+	rules := []nics.Rule{}
+	for _, pnet := range pnets {
+		// invalid rules are reported by the NetworkInterfaces of their PrivateNetwork
+		pnetRules, err := resources.ParseRules(&pnet)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, pnetRules...)
+	}
+	return rules
+}
+
+// cleanupOrphanRules removes the policy routing rules looking up the route table of a PrivateNetwork
+// not used by any NetworkInterface of the node, left by a NetworkInterface removed while the node was down
+func (r *NetworkInterfaceReconciler) cleanupOrphanRules(ctx context.Context) {
+	pnets := &vpcv1alpha1.PrivateNetworkList{}
+	err := r.Client.List(ctx, pnets)
+	if err != nil {
+		r.Log.Error(err, "unable to list private networks for rules cleanup")
+		return
+	}
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err = r.Client.List(ctx, nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: r.NodeName,
+		},
+	)
+	if err != nil {
+		r.Log.Error(err, "unable to list networkInterfaces for rules cleanup")
+		return
+	}
+
+	used := make(map[int]bool)
+	for _, nic := range nicsList.Items {
+		used[int(nic.Status.RouteTable)] = true
+	}
+	managed := make(map[int]bool)
+	for _, pnet := range pnets.Items {
+		if pnet.Spec.RouteTable != 0 {
+			managed[int(pnet.Spec.RouteTable)] = true
+		}
+	}
+
+	tables, err := r.NICs.RuleTables()
+	if err != nil {
+		r.Log.Error(err, "unable to list rules for cleanup")
+		return
+	}
+	for _, table := range orphanTables(tables, managed, used) {
+		r.Log.Info(fmt.Sprintf("removing orphan rules of route table %d", table))
+		err := r.NICs.TearDownRules(table)
+		if err != nil {
+			r.Log.Error(err, fmt.Sprintf("unable to remove orphan rules of route table %d", table))
+		}
+	}
+}
+
+// orphanTables returns the tables looked up by rules, managed by a PrivateNetwork but not used on the node
+func orphanTables(tables []int, managed map[int]bool, used map[int]bool) []int {
+	orphans := []int{}
+	for _, table := range tables {
+		if managed[table] && !used[table] {
+			orphans = append(orphans, table)
+		}
+	}
+	return orphans
+}
+
+// saveDefaultRoutes records in the status the node default routes that will be shadowed
+// by the default routes of the interface, so they can be restored on teardown
+func (r *NetworkInterfaceReconciler) saveDefaultRoutes(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, routes []nics.Route) error {
+	status := nic.Status.DeepCopy()
+	updated := false
+	for _, route := range routes {
+		if !route.IsDefault() {
+			continue
+		}
+
+		family := netlink.FAMILY_V4
+		if route.To.IP.To4() == nil {
+			family = netlink.FAMILY_V6
+		}
+
+		saved := false
+		for _, savedRoute := range nic.Status.SavedDefaultRoutes {
+			if defaultRouteFamily(savedRoute) == family {
+				saved = true
+				break
+			}
+		}
+		if saved {
+			continue
+		}
+
+		defaultRoute, err := r.NICs.GetDefaultRoute(nic.Status.MacAddress, family)
+		if err != nil {
+			return err
+		}
+		if defaultRoute == nil {
+			continue
+		}
+
+		savedRoute := vpcv1alpha1.DefaultRoute{
+			Family:   vpcv1alpha1.AddressFamilyIPv4,
+			LinkName: defaultRoute.LinkName,
+		}
+		if family == netlink.FAMILY_V6 {
+			savedRoute.Family = vpcv1alpha1.AddressFamilyIPv6
+		}
+		if defaultRoute.Via != nil {
+			savedRoute.Via = defaultRoute.Via.String()
+		}
+		nic.Status.SavedDefaultRoutes = append(nic.Status.SavedDefaultRoutes, savedRoute)
+		updated = true
+	}
+
+	if !updated {
+		return nil
+	}
+	// the saved routes are patched right away, since they can't be found again once shadowed
+	return r.patchStatus(ctx, nic, status)
+}
+
+// defaultRouteFamily returns the netlink family of a saved default route, inferred from its gateway
+// when it was saved without it
+func defaultRouteFamily(route vpcv1alpha1.DefaultRoute) int {
+	switch route.Family {
+	case vpcv1alpha1.AddressFamilyIPv4:
+		return netlink.FAMILY_V4
+	case vpcv1alpha1.AddressFamilyIPv6:
+		return netlink.FAMILY_V6
+	}
+	via := net.ParseIP(route.Via)
+	if via != nil && via.To4() == nil {
+		return netlink.FAMILY_V6
+	}
+	return netlink.FAMILY_V4
+}
+
+// parseRateLimit validates a rate limit and converts it to bits per second and bytes
+func parseRateLimit(limit *vpcv1alpha1.RateLimit) (*nics.RateLimit, error) {
+	err := resources.ValidateRateLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := limit.Rate.Value()
+	burst := rate / 8 / 100
+	if burst < minBurst {
+		burst = minBurst
+	}
+	if limit.Burst != nil {
+		burst = limit.Burst.Value()
+	}
+
+	return &nics.RateLimit{
+		Rate:  uint64(rate),
+		Burst: uint64(burst),
+	}, nil
+}
+
+// specAddress returns the address of the interface on private networks without IPAM,
+// the one resolved by the controller when it references it with AddressFrom
+func specAddress(nic *vpcv1alpha1.NetworkInterface) string {
+	if nic.Spec.AddressFrom != nil {
+		return nic.Status.ResolvedAddress
+	}
+	return nic.Spec.Address
+}
+
+// staticAddresses returns the addresses allocated to the interface by the static IPAM
+func staticAddresses(nic *vpcv1alpha1.NetworkInterface) []string {
+	addresses := []string{nic.Status.Address}
+	if nic.Status.IPv6Address != "" {
+		addresses = append(addresses, nic.Status.IPv6Address)
+	}
+	return addresses
+}
+
+// setDefaultRoutesSource sets src as the source of the default routes of its family
+func setDefaultRoutesSource(routes []nics.Route, src net.IP) {
+	for i, route := range routes {
+		if route.IsDefault() && addressFamily(route.To.IP) == addressFamily(src) {
+			routes[i].Src = src
+		}
+	}
+}
+
+// parseAddress parses an address with or without its prefix length
+func parseAddress(address string) net.IP {
+	ip, _, err := net.ParseCIDR(address)
+	if err == nil {
+		return ip
+	}
+	return net.ParseIP(address)
+}
+
+func addressFamily(ip net.IP) vpcv1alpha1.AddressFamily {
+	if ip.To4() != nil {
+		return vpcv1alpha1.AddressFamilyIPv4
+	}
+	return vpcv1alpha1.AddressFamilyIPv6
 }
 
 func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&vpcv1alpha1.NetworkInterface{}).
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&vpcv1alpha1.NetworkInterface{})
+	if r.PenaltyBox != nil {
+		builder = builder.WithOptions(controller.Options{RateLimiter: r.PenaltyBox})
+	}
+	if r.GoBGP != nil {
+		builder = builder.Watches(&source.Channel{
+			Source: r.GoBGP.Events(),
+		}, &handler.Funcs{
+			GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+				r.enqueueNodeNetworkInterfaces(q)
+			},
+		})
+	}
+	if r.Resync != nil {
+		builder = builder.Watches(&source.Channel{
+			Source: r.Resync.Events(),
+		}, &handler.Funcs{
+			GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+				r.enqueueNodeNetworkInterfaces(q)
+			},
+		})
+	}
+	if r.NetlinkWatch != nil {
+		builder = builder.Watches(&source.Channel{
+			Source: r.NetlinkWatch.Events(),
+		}, &handler.Funcs{
+			GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+				r.enqueueLinkNetworkInterfaces(q, e.Meta.GetName())
+			},
+		})
+	}
+	return builder.
 		Watches(&source.Kind{
 			Type: &vpcv1alpha1.PrivateNetwork{},
 		}, &handler.Funcs{
@@ -260,7 +2036,62 @@ func (r *NetworkInterfaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 						},
 					})
 				}
+
+				oldPnet, okOld := e.ObjectOld.(*vpcv1alpha1.PrivateNetwork)
+				newPnet, okNew := e.ObjectNew.(*vpcv1alpha1.PrivateNetwork)
+				if okOld && okNew && (!reflect.DeepEqual(oldPnet.Spec.Routes, newPnet.Spec.Routes) || oldPnet.Spec.RouteMetric != newPnet.Spec.RouteMetric) {
+					r.enqueueNodeNetworkInterfaces(q)
+				}
+			},
+			DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+				r.enqueueNodeNetworkInterfaces(q)
 			},
 		}).
 		Complete(r)
 }
+
+// enqueueLinkNetworkInterfaces enqueues the NetworkInterfaces of the node configuring the given link
+func (r *NetworkInterfaceReconciler) enqueueLinkNetworkInterfaces(q workqueue.RateLimitingInterface, linkName string) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(context.Background(), nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: r.NodeName,
+		},
+	)
+	if err != nil {
+		r.Log.Error(err, fmt.Sprintf("unable to sync nics of link %s", linkName))
+		return
+	}
+	for _, nic := range nicsList.Items {
+		if nic.Status.LinkName != linkName {
+			continue
+		}
+		q.Add(reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: nic.Name,
+			},
+		})
+	}
+}
+
+// enqueueNodeNetworkInterfaces enqueues the NetworkInterfaces of the node, so that they sync their routes
+// again after the routes of a PrivateNetwork or the BGP learned routes changed, or on a forced resync
+func (r *NetworkInterfaceReconciler) enqueueNodeNetworkInterfaces(q workqueue.RateLimitingInterface) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(context.Background(), nicsList,
+		client.MatchingLabels{
+			constants.NodeLabel: r.NodeName,
+		},
+	)
+	if err != nil {
+		r.Log.Error(err, "unable to sync node nics")
+		return
+	}
+	for _, nic := range nicsList.Items {
+		q.Add(reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name: nic.Name,
+			},
+		})
+	}
+}
@@ -0,0 +1,368 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+var _ = Describe("Route conflicts between private networks", func() {
+	parseRoute := func(to string, via string, metric int32) nics.Route {
+		route, err := parsePrivateNetworkRoute(&vpcv1alpha1.PrivateNetwork{}, vpcv1alpha1.PrivateNetworkRoute{
+			To:     to,
+			Via:    via,
+			Metric: metric,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return route
+	}
+
+	It("defaults the metric of the routes to the one of the private network", func() {
+		pnet := &vpcv1alpha1.PrivateNetwork{
+			Spec: vpcv1alpha1.PrivateNetworkSpec{
+				RouteMetric: 100,
+			},
+		}
+		route, err := parsePrivateNetworkRoute(pnet, vpcv1alpha1.PrivateNetworkRoute{To: "10.0.0.0/16", Via: "192.168.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.Metric).To(Equal(100))
+
+		route, err = parsePrivateNetworkRoute(pnet, vpcv1alpha1.PrivateNetworkRoute{To: "10.0.0.0/16", Via: "192.168.0.1", Metric: 50})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.Metric).To(Equal(50))
+	})
+
+	It("defaults the realm of the IPv4 routes to the one of the private network", func() {
+		pnet := &vpcv1alpha1.PrivateNetwork{
+			Spec: vpcv1alpha1.PrivateNetworkSpec{
+				RouteRealm: 10,
+			},
+		}
+		route, err := parsePrivateNetworkRoute(pnet, vpcv1alpha1.PrivateNetworkRoute{To: "10.0.0.0/16", Via: "192.168.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.Realm).To(Equal(10))
+
+		route, err = parsePrivateNetworkRoute(pnet, vpcv1alpha1.PrivateNetworkRoute{To: "10.0.0.0/16", Via: "192.168.0.1", Realm: 20})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.Realm).To(Equal(20))
+
+		route, err = parsePrivateNetworkRoute(pnet, vpcv1alpha1.PrivateNetworkRoute{To: "fd01::/64", Via: "fd00::1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.Realm).To(BeZero())
+	})
+
+	It("keeps the routes without conflict", func() {
+		routes := []nics.Route{parseRoute("10.0.0.0/16", "192.168.0.1", 0)}
+		kept, conflicts := resolveRouteConflicts("pn-a", routes, map[string][]nics.Route{
+			"pn-b": {parseRoute("10.1.0.0/16", "192.168.1.1", 0)},
+		})
+		Expect(kept).To(Equal(routes))
+		Expect(conflicts).To(BeEmpty())
+	})
+
+	It("keeps the routes to the same prefix at different metrics on both networks", func() {
+		routesA := []nics.Route{parseRoute("10.0.0.0/16", "192.168.0.1", 100)}
+		routesB := []nics.Route{parseRoute("10.0.0.0/16", "192.168.1.1", 200)}
+
+		kept, conflicts := resolveRouteConflicts("pn-a", routesA, map[string][]nics.Route{"pn-b": routesB})
+		Expect(kept).To(Equal(routesA))
+		Expect(conflicts).To(BeEmpty())
+
+		kept, conflicts = resolveRouteConflicts("pn-b", routesB, map[string][]nics.Route{"pn-a": routesA})
+		Expect(kept).To(Equal(routesB))
+		Expect(conflicts).To(BeEmpty())
+	})
+
+	It("keeps the route to the same prefix at the same metric on the first network only", func() {
+		routesA := []nics.Route{parseRoute("10.0.0.0/16", "192.168.0.1", 100)}
+		routesB := []nics.Route{
+			parseRoute("10.0.0.0/16", "192.168.1.1", 100),
+			parseRoute("10.2.0.0/16", "192.168.1.1", 100),
+		}
+
+		kept, conflicts := resolveRouteConflicts("pn-a", routesA, map[string][]nics.Route{"pn-b": routesB})
+		Expect(kept).To(Equal(routesA))
+		Expect(conflicts).To(ConsistOf(ContainSubstring("kept")))
+
+		kept, conflicts = resolveRouteConflicts("pn-b", routesB, map[string][]nics.Route{"pn-a": routesA})
+		Expect(kept).To(Equal(routesB[1:]))
+		Expect(conflicts).To(ConsistOf(ContainSubstring("skipped")))
+	})
+})
+
+var _ = Describe("Route deduplication", func() {
+	route := func(to string, via string, metric int, source string) sourcedRoute {
+		parsedRoute, err := nics.ParseRoute(to, via)
+		Expect(err).NotTo(HaveOccurred())
+		parsedRoute.Metric = metric
+		return sourcedRoute{Route: parsedRoute, source: source}
+	}
+
+	It("keeps the routes to different destinations", func() {
+		routes := []sourcedRoute{
+			route("10.0.0.0/16", "192.168.0.1", 0, routeSourceSpec),
+			route("10.1.0.0/16", "192.168.0.2", 0, routeSourceBGP),
+		}
+		kept, discarded := dedupRoutes(routes)
+		Expect(kept).To(Equal([]nics.Route{routes[0].Route, routes[1].Route}))
+		Expect(discarded).To(BeEmpty())
+	})
+
+	It("selects by metric, then source, then gateway, whatever the order", func() {
+		routes := []sourcedRoute{
+			route("10.0.0.0/16", "192.168.0.1", 100, routeSourceSpec),
+			route("10.0.0.0/16", "192.168.0.2", 0, routeSourceBGP),
+			route("10.1.0.0/16", "192.168.0.3", 0, routeSourceBGP),
+			route("10.1.0.0/16", "192.168.0.4", 0, routeSourceSpec),
+			route("10.2.0.0/16", "192.168.0.6", 0, routeSourceSpec),
+			route("10.2.0.0/16", "192.168.0.5", 0, routeSourceSpec),
+		}
+		expected := []nics.Route{routes[1].Route, routes[3].Route, routes[5].Route}
+
+		kept, discarded := dedupRoutes(routes)
+		Expect(kept).To(ConsistOf(expected))
+		Expect(discarded).To(HaveLen(3))
+		Expect(discarded[0].reason).To(ContainSubstring("higher metric"))
+		Expect(discarded[1].reason).To(ContainSubstring("lower priority source"))
+		Expect(discarded[2].reason).To(ContainSubstring("higher gateway"))
+
+		reversed := make([]sourcedRoute, 0, len(routes))
+		for i := len(routes) - 1; i >= 0; i-- {
+			reversed = append(reversed, routes[i])
+		}
+		kept, _ = dedupRoutes(reversed)
+		Expect(kept).To(ConsistOf(expected))
+	})
+})
+
+var _ = Describe("Policy routing rules cleanup", func() {
+	It("finds the orphan tables", func() {
+		tables := []int{255, 254, 253, 100, 101, 102}
+		managed := map[int]bool{100: true, 101: true}
+		used := map[int]bool{0: true, 101: true}
+		Expect(orphanTables(tables, managed, used)).To(Equal([]int{100}))
+	})
+})
+
+var _ = Describe("Route table migration", func() {
+	nic := func(table int32) *vpcv1alpha1.NetworkInterface {
+		return &vpcv1alpha1.NetworkInterface{
+			Status: vpcv1alpha1.NetworkInterfaceStatus{
+				RouteTable: table,
+			},
+		}
+	}
+
+	It("leaves the previous table when the table changes", func() {
+		previous, moved := previousRouteTable(nic(100), 101)
+		Expect(moved).To(BeTrue())
+		Expect(previous).To(Equal(100))
+
+		previous, moved = previousRouteTable(nic(100), 0)
+		Expect(moved).To(BeTrue())
+		Expect(previous).To(Equal(100))
+	})
+
+	It("leaves the main table when moving to another table", func() {
+		previous, moved := previousRouteTable(nic(0), 100)
+		Expect(moved).To(BeTrue())
+		Expect(previous).To(Equal(0))
+	})
+
+	It("keeps the table when it doesn't change", func() {
+		_, moved := previousRouteTable(nic(100), 100)
+		Expect(moved).To(BeFalse())
+		_, moved = previousRouteTable(nic(0), 0)
+		Expect(moved).To(BeFalse())
+	})
+})
+
+var _ = Describe("Default routes source", func() {
+	It("sets the source of the default route of its family only", func() {
+		parseRoute := func(to string, via string) nics.Route {
+			route, err := nics.ParseRoute(to, via)
+			Expect(err).NotTo(HaveOccurred())
+			return route
+		}
+		routes := []nics.Route{
+			parseRoute("0.0.0.0/0", "192.168.0.1"),
+			parseRoute("::/0", "fd00::1"),
+			parseRoute("10.0.0.0/16", "192.168.0.2"),
+		}
+
+		src := net.ParseIP("192.168.0.10")
+		setDefaultRoutesSource(routes, src)
+		Expect(routes[0].Src).To(Equal(src))
+		Expect(routes[1].Src).To(BeNil())
+		Expect(routes[2].Src).To(BeNil())
+	})
+})
+
+var _ = Describe("Saved default routes", func() {
+	It("uses the saved family of the routes without gateway", func() {
+		Expect(defaultRouteFamily(vpcv1alpha1.DefaultRoute{Family: vpcv1alpha1.AddressFamilyIPv6, LinkName: "eth0"})).To(Equal(netlink.FAMILY_V6))
+		Expect(defaultRouteFamily(vpcv1alpha1.DefaultRoute{Family: vpcv1alpha1.AddressFamilyIPv4, LinkName: "eth0"})).To(Equal(netlink.FAMILY_V4))
+	})
+
+	It("infers the family of the routes saved without it from their gateway", func() {
+		Expect(defaultRouteFamily(vpcv1alpha1.DefaultRoute{Via: "fe80::1", LinkName: "eth0"})).To(Equal(netlink.FAMILY_V6))
+		Expect(defaultRouteFamily(vpcv1alpha1.DefaultRoute{Via: "10.0.0.1", LinkName: "eth0"})).To(Equal(netlink.FAMILY_V4))
+		Expect(defaultRouteFamily(vpcv1alpha1.DefaultRoute{LinkName: "eth0"})).To(Equal(netlink.FAMILY_V4))
+	})
+})
+
+var _ = Describe("Router advertisements handling", func() {
+	It("maps the modes to the router advertisements settings", func() {
+		Expect(acceptRA(vpcv1alpha1.AcceptRAOn)).To(Equal(nics.AcceptRA{Accept: true, Autoconf: true, DefaultRoute: true}))
+		Expect(acceptRA(vpcv1alpha1.AcceptRARouteOnly)).To(Equal(nics.AcceptRA{Accept: true, DefaultRoute: true}))
+		Expect(acceptRA(vpcv1alpha1.AcceptRANoDefaultRoute)).To(Equal(nics.AcceptRA{Accept: true, Autoconf: true}))
+		Expect(acceptRA(vpcv1alpha1.AcceptRAOff)).To(Equal(nics.AcceptRA{}))
+	})
+})
+
+var _ = Describe("Neighbor table parameters", func() {
+	It("maps the spec to the neighbor settings and back", func() {
+		spec := &vpcv1alpha1.NeighborSettings{BaseReachableTimeMs: 120000, MCastSolicit: 6}
+		settings := neighborSettings(spec)
+		Expect(settings).To(Equal(nics.NeighborSettings{BaseReachableTime: 2 * time.Minute, MCastSolicit: 6}))
+
+		settings.GCStaleTime = time.Minute
+		Expect(neighborStatus(settings)).To(Equal(&vpcv1alpha1.NeighborSettings{
+			BaseReachableTimeMs: 120000,
+			GCStaleTimeSeconds:  60,
+			MCastSolicit:        6,
+		}))
+	})
+})
+
+var _ = Describe("Applied routes status", func() {
+	It("reports the outcome of each route", func() {
+		route, err := nics.ParseRoute("fd01::/64", "fd00::1")
+		Expect(err).NotTo(HaveOccurred())
+		failed, err := nics.ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		skipped, err := nics.ParseRoute("10.1.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+
+		applied, omitted := appliedRoutes([]nics.RouteResult{
+			{Route: route, Priority: 1024},
+			{Route: failed, Priority: 0, Err: fmt.Errorf("network is unreachable")},
+			{Route: skipped, Priority: 0, Skipped: true},
+		}, 100, nil, time.Now())
+		Expect(applied).To(Equal([]vpcv1alpha1.AppliedRoute{
+			{To: "fd01::/64", Via: "fd00::1", Priority: 1024, Table: 100, Result: vpcv1alpha1.AppliedRouteResultApplied},
+			{To: "10.0.0.0/16", Via: "192.168.0.1", Table: 100, Result: "network is unreachable"},
+			{To: "10.1.0.0/16", Via: "192.168.0.1", Table: 100, Result: vpcv1alpha1.AppliedRouteResultSkipped},
+		}))
+		Expect(omitted).To(BeZero())
+		applied, _ = appliedRoutes(nil, 0, nil, time.Now())
+		Expect(applied).To(BeNil())
+	})
+
+	It("keeps the time a route was last applied at until it is applied again", func() {
+		route, err := nics.ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		before := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+		now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+		previous := []vpcv1alpha1.AppliedRoute{
+			{To: "10.0.0.0/16", Via: "192.168.0.1", Result: vpcv1alpha1.AppliedRouteResultApplied, LastAppliedTime: &before},
+		}
+
+		applied, _ := appliedRoutes([]nics.RouteResult{{Route: route}}, 0, previous, now)
+		Expect(applied[0].LastAppliedTime).To(Equal(&before))
+
+		applied, _ = appliedRoutes([]nics.RouteResult{{Route: route, Applied: true}}, 0, previous, now)
+		Expect(applied[0].LastAppliedTime.Time).To(Equal(now))
+	})
+
+	It("bounds the number of reported routes", func() {
+		results := []nics.RouteResult{}
+		for i := 0; i < vpcv1alpha1.MaxAppliedRoutes+3; i++ {
+			route, err := nics.ParseRoute(fmt.Sprintf("10.%d.0.0/16", i), "192.168.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			results = append(results, nics.RouteResult{Route: route})
+		}
+
+		applied, omitted := appliedRoutes(results, 0, nil, time.Now())
+		Expect(applied).To(HaveLen(vpcv1alpha1.MaxAppliedRoutes))
+		Expect(omitted).To(Equal(int32(3)))
+	})
+})
+
+var _ = Describe("Link alias", func() {
+	It("renders the template with the fields of the interface", func() {
+		tmpl, err := ParseLinkAliasTemplate("{{.PrivateNetwork}}-{{.Index}}-{{.Address}}")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(renderLinkAlias(tmpl, LinkAliasData{PrivateNetwork: "pn", Index: 1, Address: "192.168.0.10"})).To(Equal("pn-1-192.168.0.10"))
+	})
+
+	It("rejects invalid templates", func() {
+		_, err := ParseLinkAliasTemplate("{{.Unknown}}")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseLinkAliasTemplate("{{.Index")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Teardown grace period", func() {
+	deleted := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	It("tears down right away without grace period", func() {
+		nic := &vpcv1alpha1.NetworkInterface{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &metav1.Time{Time: deleted}}}
+		Expect(teardownGraceRemaining(nic, deleted)).To(BeZero())
+	})
+
+	It("keeps the link until the end of the grace period", func() {
+		nic := &vpcv1alpha1.NetworkInterface{
+			ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &metav1.Time{Time: deleted}},
+			Spec:       vpcv1alpha1.NetworkInterfaceSpec{TeardownGracePeriod: &metav1.Duration{Duration: time.Minute}},
+		}
+		Expect(teardownGraceRemaining(nic, deleted.Add(20*time.Second))).To(Equal(40 * time.Second))
+		Expect(teardownGraceRemaining(nic, deleted.Add(2*time.Minute))).To(BeNumerically("<", 0))
+	})
+})
+
+var _ = Describe("Teardown link state", func() {
+	nic := func(policy vpcv1alpha1.TeardownPolicy, linkDown vpcv1alpha1.TeardownLinkDown, broughtUp bool) *vpcv1alpha1.NetworkInterface {
+		return &vpcv1alpha1.NetworkInterface{
+			Spec:   vpcv1alpha1.NetworkInterfaceSpec{TeardownPolicy: policy, TeardownLinkDown: linkDown},
+			Status: vpcv1alpha1.NetworkInterfaceStatus{LinkBroughtUp: broughtUp},
+		}
+	}
+
+	It("only brings the link down if the node daemon brought it up by default", func() {
+		Expect(teardownLinkDown(nic("", "", true))).To(BeTrue())
+		Expect(teardownLinkDown(nic("", "", false))).To(BeFalse())
+		Expect(teardownLinkDown(nic(vpcv1alpha1.TeardownPolicyFlushConfig, "", true))).To(BeFalse())
+		Expect(teardownLinkDown(nic(vpcv1alpha1.TeardownPolicyFlushConfig, vpcv1alpha1.TeardownLinkDownIfBroughtUp, true))).To(BeTrue())
+	})
+
+	It("always or never brings the link down as configured", func() {
+		Expect(teardownLinkDown(nic(vpcv1alpha1.TeardownPolicyFlushConfig, vpcv1alpha1.TeardownLinkDownAlways, false))).To(BeTrue())
+		Expect(teardownLinkDown(nic("", vpcv1alpha1.TeardownLinkDownNever, true))).To(BeFalse())
+	})
+})
@@ -0,0 +1,95 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+// withInternalIP returns the addresses of a node with ip as its first InternalIP address
+func withInternalIP(addresses []corev1.NodeAddress, ip string) []corev1.NodeAddress {
+	updated := []corev1.NodeAddress{}
+	inserted := false
+	for _, address := range addresses {
+		if address.Type == corev1.NodeInternalIP && !inserted {
+			updated = append(updated, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip})
+			inserted = true
+		}
+		if address.Type == corev1.NodeInternalIP && address.Address == ip {
+			continue
+		}
+		updated = append(updated, address)
+	}
+	if !inserted {
+		updated = append(updated, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip})
+	}
+	return updated
+}
+
+// withoutInternalIP returns the addresses of a node without the InternalIP address ip
+func withoutInternalIP(addresses []corev1.NodeAddress, ip string) []corev1.NodeAddress {
+	updated := []corev1.NodeAddress{}
+	for _, address := range addresses {
+		if address.Type != corev1.NodeInternalIP || address.Address != ip {
+			updated = append(updated, address)
+		}
+	}
+	return updated
+}
+
+// syncNodeInternalIP sets ip as the first InternalIP address of the node, replacing the one previously added for
+// the NetworkInterface, which is only removed when ip is empty. The addresses are patched as a whole, as the
+// strategic merge of the node addresses keeps a single address per type
+func (r *NetworkInterfaceReconciler) syncNodeInternalIP(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, ip string) error {
+	if ip == "" && nic.Status.NodeInternalIP == "" {
+		return nil
+	}
+
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	node := &corev1.Node{}
+	err := reader.Get(ctx, client.ObjectKey{Name: r.NodeName}, node)
+	if err != nil {
+		return err
+	}
+
+	addresses := node.Status.Addresses
+	if nic.Status.NodeInternalIP != "" && nic.Status.NodeInternalIP != ip {
+		addresses = withoutInternalIP(addresses, nic.Status.NodeInternalIP)
+	}
+	if ip != "" {
+		addresses = withInternalIP(addresses, ip)
+	}
+	if !reflect.DeepEqual(addresses, node.Status.Addresses) {
+		patch := client.MergeFrom(node.DeepCopy())
+		node.Status.Addresses = addresses
+		err = r.Client.Status().Patch(ctx, node, patch)
+		if err != nil {
+			return err
+		}
+	}
+	nic.Status.NodeInternalIP = ip
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Node internal IP", func() {
+	addresses := []corev1.NodeAddress{
+		{Type: corev1.NodeHostName, Address: "node-1"},
+		{Type: corev1.NodeInternalIP, Address: "10.64.0.2"},
+		{Type: corev1.NodeExternalIP, Address: "51.15.0.2"},
+	}
+
+	It("adds the address as the first internal ip", func() {
+		Expect(withInternalIP(addresses, "192.168.0.10")).To(Equal([]corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node-1"},
+			{Type: corev1.NodeInternalIP, Address: "192.168.0.10"},
+			{Type: corev1.NodeInternalIP, Address: "10.64.0.2"},
+			{Type: corev1.NodeExternalIP, Address: "51.15.0.2"},
+		}))
+	})
+
+	It("moves an existing address first", func() {
+		added := withInternalIP(addresses, "192.168.0.10")
+		Expect(withInternalIP(added, "192.168.0.10")).To(Equal(added))
+		Expect(withInternalIP(addresses, "10.64.0.2")).To(Equal(addresses))
+	})
+
+	It("adds the address without internal ip", func() {
+		Expect(withInternalIP(addresses[:1], "192.168.0.10")).To(Equal([]corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node-1"},
+			{Type: corev1.NodeInternalIP, Address: "192.168.0.10"},
+		}))
+	})
+
+	It("removes the added address only", func() {
+		Expect(withoutInternalIP(withInternalIP(addresses, "192.168.0.10"), "192.168.0.10")).To(Equal(addresses))
+		Expect(withoutInternalIP(addresses, "51.15.0.2")).To(Equal(addresses))
+	})
+})
@@ -0,0 +1,119 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+// NodePrivateNetworkReady is the Node condition set when all the NetworkInterfaces of the node are ready
+const NodePrivateNetworkReady corev1.NodeConditionType = "PrivateNetworkReady"
+
+// nodeCondition is the state of the PrivateNetworkReady condition of the node
+type nodeCondition struct {
+	status  corev1.ConditionStatus
+	reason  string
+	message string
+}
+
+// privateNetworkReadyCondition returns the PrivateNetworkReady condition of a node from its NetworkInterfaces
+func privateNetworkReadyCondition(nodeName string, nicsList []vpcv1alpha1.NetworkInterface) nodeCondition {
+	notReady := []string{}
+	for _, nic := range nicsList {
+		if nic.Spec.NodeName != nodeName || !nic.ObjectMeta.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if !nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceReady) {
+			notReady = append(notReady, nic.Name)
+		}
+	}
+
+	if len(notReady) == 0 {
+		return nodeCondition{
+			status: corev1.ConditionTrue,
+			reason: "NetworkInterfacesReady",
+		}
+	}
+	sort.Strings(notReady)
+	return nodeCondition{
+		status:  corev1.ConditionFalse,
+		reason:  "NetworkInterfacesNotReady",
+		message: fmt.Sprintf("networkInterfaces not ready: %s", strings.Join(notReady, ", ")),
+	}
+}
+
+// updateNodeCondition sets the PrivateNetworkReady condition of the node when it changes
+// The condition is patched with a strategic merge, so that the other conditions of the node are kept
+// and the patch does not conflict with the kubelet
+func (r *NetworkInterfaceReconciler) updateNodeCondition(ctx context.Context, log logr.Logger) {
+	nicsList := &vpcv1alpha1.NetworkInterfaceList{}
+	err := r.Client.List(ctx, nicsList, client.MatchingLabels{
+		constants.NodeLabel: r.NodeName,
+	})
+	if err != nil {
+		log.Error(err, "unable to list node networkInterfaces")
+		return
+	}
+
+	condition := privateNetworkReadyCondition(r.NodeName, nicsList.Items)
+	if r.nodeCondition != nil && *r.nodeCondition == condition {
+		return
+	}
+
+	now := metav1.NewTime(time.Now())
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.NodeCondition{
+				{
+					Type:               NodePrivateNetworkReady,
+					Status:             condition.status,
+					Reason:             condition.reason,
+					Message:            condition.message,
+					LastHeartbeatTime:  now,
+					LastTransitionTime: now,
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Error(err, "unable to marshal node condition patch")
+		return
+	}
+
+	node := &corev1.Node{}
+	node.Name = r.NodeName
+	err = r.Client.Status().Patch(ctx, node, client.RawPatch(types.StrategicMergePatchType, patch))
+	if err != nil {
+		log.Error(err, fmt.Sprintf("unable to set %s condition of node", NodePrivateNetworkReady))
+		return
+	}
+	r.nodeCondition = &condition
+}
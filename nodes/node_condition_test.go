@@ -0,0 +1,69 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var _ = Describe("Node PrivateNetworkReady condition", func() {
+	nic := func(name string, nodeName string, ready bool) vpcv1alpha1.NetworkInterface {
+		nic := vpcv1alpha1.NetworkInterface{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       vpcv1alpha1.NetworkInterfaceSpec{NodeName: nodeName},
+		}
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, status, "", "")
+		return nic
+	}
+
+	It("is true when all the networkInterfaces of the node are ready", func() {
+		condition := privateNetworkReadyCondition("node-a", []vpcv1alpha1.NetworkInterface{
+			nic("nic-a", "node-a", true),
+			nic("nic-b", "node-b", false),
+		})
+		Expect(condition.status).To(Equal(corev1.ConditionTrue))
+	})
+
+	It("is true when the node has no networkInterface", func() {
+		condition := privateNetworkReadyCondition("node-a", nil)
+		Expect(condition.status).To(Equal(corev1.ConditionTrue))
+	})
+
+	It("lists the networkInterfaces not ready", func() {
+		deleting := nic("nic-d", "node-a", false)
+		now := metav1.Now()
+		deleting.DeletionTimestamp = &now
+
+		condition := privateNetworkReadyCondition("node-a", []vpcv1alpha1.NetworkInterface{
+			nic("nic-c", "node-a", false),
+			nic("nic-a", "node-a", true),
+			nic("nic-b", "node-a", false),
+			deleting,
+		})
+		Expect(condition.status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.message).To(Equal("networkInterfaces not ready: nic-b, nic-c"))
+	})
+})
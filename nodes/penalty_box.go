@@ -0,0 +1,102 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// PenaltyBox is the rate limiter of the retries of the failed reconciles, keeping a NetworkInterface
+// failing repeatedly from delaying the others. Once a NetworkInterface failed more than Threshold times
+// within Window, its retries are delayed by Penalty instead of going through the default rate limiter,
+// whose overall rate limit is shared by all the NetworkInterfaces of the node
+type PenaltyBox struct {
+	// Threshold is the number of failures within Window above which a NetworkInterface is penalized
+	Threshold int
+	// Window is the period the failures are counted over
+	Window time.Duration
+	// Penalty is the delay of the retries of a penalized NetworkInterface
+	Penalty time.Duration
+
+	base workqueue.RateLimiter
+
+	mu sync.Mutex
+	// failures holds the time of the recent failures, by item
+	failures map[interface{}][]time.Time
+}
+
+// NewPenaltyBox returns a PenaltyBox in front of the default controller rate limiter
+func NewPenaltyBox(threshold int, window time.Duration, penalty time.Duration) *PenaltyBox {
+	return &PenaltyBox{
+		Threshold: threshold,
+		Window:    window,
+		Penalty:   penalty,
+		base:      workqueue.DefaultControllerRateLimiter(),
+		failures:  make(map[interface{}][]time.Time),
+	}
+}
+
+// When returns how long to wait before retrying the item after a failure
+func (p *PenaltyBox) When(item interface{}) time.Duration {
+	if p.penalize(item, time.Now()) {
+		penalizedRetriesTotal.Inc()
+		return p.Penalty
+	}
+	return p.base.When(item)
+}
+
+// penalize records a failure of the item, and returns whether it failed too often within the window
+func (p *PenaltyBox) penalize(item interface{}, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	failures := append(p.recentFailures(item, now), now)
+	p.failures[item] = failures
+	return len(failures) > p.Threshold
+}
+
+// recentFailures returns the failures of the item within the window
+func (p *PenaltyBox) recentFailures(item interface{}, now time.Time) []time.Time {
+	failures := p.failures[item]
+	for len(failures) != 0 && now.Sub(failures[0]) > p.Window {
+		failures = failures[1:]
+	}
+	return failures
+}
+
+// Forget resets the backoff of the item once reconciled, its failures being kept until they are out of the window
+// so that a NetworkInterface flapping between failures and successes stays penalized
+func (p *PenaltyBox) Forget(item interface{}) {
+	p.base.Forget(item)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	failures := p.recentFailures(item, time.Now())
+	if len(failures) == 0 {
+		delete(p.failures, item)
+		return
+	}
+	p.failures[item] = failures
+}
+
+// NumRequeues returns the number of consecutive failures of the item
+func (p *PenaltyBox) NumRequeues(item interface{}) int {
+	return p.base.NumRequeues(item)
+}
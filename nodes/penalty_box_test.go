@@ -0,0 +1,54 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Penalty box", func() {
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	It("penalizes an item failing more than the threshold within the window", func() {
+		box := NewPenaltyBox(2, time.Minute, time.Minute)
+		Expect(box.penalize("bad", start)).To(BeFalse())
+		Expect(box.penalize("bad", start.Add(time.Second))).To(BeFalse())
+		Expect(box.penalize("healthy", start.Add(time.Second))).To(BeFalse())
+		Expect(box.penalize("bad", start.Add(2*time.Second))).To(BeTrue())
+	})
+
+	It("only counts the failures within the window", func() {
+		box := NewPenaltyBox(2, time.Minute, time.Minute)
+		Expect(box.penalize("bad", start)).To(BeFalse())
+		Expect(box.penalize("bad", start.Add(time.Second))).To(BeFalse())
+		Expect(box.penalize("bad", start.Add(2*time.Minute))).To(BeFalse())
+	})
+
+	It("delays the retries of a penalized item by the penalty", func() {
+		box := NewPenaltyBox(1, time.Hour, 30*time.Second)
+		Expect(box.When("bad")).To(BeNumerically("<", 30*time.Second))
+		Expect(box.When("bad")).To(Equal(30 * time.Second))
+		Expect(box.When("healthy")).To(BeNumerically("<", 30*time.Second))
+
+		box.Forget("bad")
+		Expect(box.NumRequeues("bad")).To(BeZero())
+		Expect(box.When("bad")).To(Equal(30 * time.Second))
+	})
+})
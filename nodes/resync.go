@@ -0,0 +1,85 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// SignalResync triggers a reconcile of all the NetworkInterfaces of the node when the process
+// receives one of its signals, and every Interval if not 0
+type SignalResync struct {
+	Signals  []os.Signal
+	Interval time.Duration
+	Log      logr.Logger
+
+	events chan event.GenericEvent
+}
+
+// NewSignalResync returns a SignalResync for the given signals
+func NewSignalResync(log logr.Logger, signals ...os.Signal) *SignalResync {
+	return &SignalResync{
+		Signals: signals,
+		Log:     log,
+		events:  make(chan event.GenericEvent),
+	}
+}
+
+// Events returns a channel receiving an event for each received signal and tick
+func (s *SignalResync) Events() <-chan event.GenericEvent {
+	return s.events
+}
+
+// Start waits for the signals and ticks until stop is closed
+func (s *SignalResync) Start(stop <-chan struct{}) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, s.Signals...)
+	defer signal.Stop(signals)
+
+	var ticks <-chan time.Time
+	if s.Interval != 0 {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case sig := <-signals:
+			s.Log.Info(fmt.Sprintf("received %s, reconciling all networkInterfaces of the node", sig))
+		case <-ticks:
+			s.Log.V(1).Info("periodic resync, reconciling all networkInterfaces of the node")
+		case <-stop:
+			return nil
+		}
+		select {
+		case s.events <- event.GenericEvent{
+			Meta:   &metav1.ObjectMeta{Name: "resync"},
+			Object: &metav1.PartialObjectMetadata{},
+		}:
+		case <-stop:
+			return nil
+		}
+	}
+}
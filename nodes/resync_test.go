@@ -0,0 +1,54 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Resync", func() {
+	It("triggers a resync every interval", func() {
+		resync := NewSignalResync(ctrl.Log, syscall.SIGUSR2)
+		resync.Interval = time.Millisecond * 10
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			Expect(resync.Start(stop)).To(Succeed())
+		}()
+
+		for i := 0; i < 2; i++ {
+			Eventually(resync.Events()).Should(Receive())
+		}
+		close(stop)
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("only triggers a resync on signals without an interval", func() {
+		resync := NewSignalResync(ctrl.Log, syscall.SIGUSR2)
+		stop := make(chan struct{})
+		defer close(stop)
+		go resync.Start(stop)
+
+		Consistently(resync.Events(), time.Millisecond*100).ShouldNot(Receive())
+	})
+})
@@ -0,0 +1,229 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// knownGoodRoutes returns the routes as kept in the status
+func knownGoodRoutes(routes []nics.Route) []vpcv1alpha1.KnownGoodRoute {
+	var known []vpcv1alpha1.KnownGoodRoute
+	for _, route := range routes {
+		knownRoute := vpcv1alpha1.KnownGoodRoute{
+			To:       route.To.String(),
+			Metric:   int32(route.Metric),
+			Protocol: int32(route.Protocol),
+			Pref:     route.Pref,
+			Realm:    int32(route.Realm),
+		}
+		if route.Via != nil {
+			knownRoute.Via = route.Via.String()
+		}
+		if route.Src != nil {
+			knownRoute.Src = route.Src.String()
+		}
+		known = append(known, knownRoute)
+	}
+	return known
+}
+
+// routesFromKnownGood returns the routes kept in the status, installed with the given conflict policy
+func routesFromKnownGood(known []vpcv1alpha1.KnownGoodRoute, onConflict string) ([]nics.Route, error) {
+	var routes []nics.Route
+	for _, knownRoute := range known {
+		var route nics.Route
+		if knownRoute.Via != "" {
+			parsedRoute, err := nics.ParseRoute(knownRoute.To, knownRoute.Via)
+			if err != nil {
+				return nil, err
+			}
+			route = parsedRoute
+		} else {
+			// a connected route
+			_, dst, err := net.ParseCIDR(knownRoute.To)
+			if err != nil {
+				return nil, err
+			}
+			route.To = dst
+		}
+		if src := net.ParseIP(knownRoute.Src); src != nil {
+			if src4 := src.To4(); src4 != nil {
+				src = src4
+			}
+			route.Src = src
+		}
+		route.Metric = int(knownRoute.Metric)
+		route.Protocol = int(knownRoute.Protocol)
+		route.Pref = knownRoute.Pref
+		route.Realm = int(knownRoute.Realm)
+		route.OnConflict = onConflict
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// routeSetKeys returns the sorted descriptions of the routes, to compare route sets
+func routeSetKeys(known []vpcv1alpha1.KnownGoodRoute) []string {
+	var keys []string
+	for _, route := range known {
+		key := route.To
+		if route.Via != "" {
+			key += " via " + route.Via
+		}
+		if route.Src != "" {
+			key += " src " + route.Src
+		}
+		key += fmt.Sprintf(" metric %d", route.Metric)
+		if route.Protocol != 0 {
+			key += fmt.Sprintf(" proto %d", route.Protocol)
+		}
+		if route.Pref != "" {
+			key += " pref " + route.Pref
+		}
+		if route.Realm != 0 {
+			key += fmt.Sprintf(" realm %d", route.Realm)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// routeHealthCheckWindow returns how long the target of the route health check is waited for
+func routeHealthCheckWindow(check *vpcv1alpha1.RouteHealthCheck) time.Duration {
+	if check.Window == nil {
+		return vpcv1alpha1.DefaultRouteHealthCheckWindow
+	}
+	return check.Window.Duration
+}
+
+// routeHealthCheckSource returns the address of the interface the target of the route health check is pinged from,
+// nil when the interface has none of its family
+func routeHealthCheckSource(nic *vpcv1alpha1.NetworkInterface, target net.IP) net.IP {
+	for _, address := range []string{nic.Status.Address, nic.Status.IPv6Address} {
+		ip := parseAddress(address)
+		if ip != nil && (ip.To4() == nil) == (target.To4() == nil) {
+			return ip
+		}
+	}
+	return nil
+}
+
+// routesToCheck returns the routes to apply given the desired ones, and whether they are a change the route
+// health check of the PrivateNetwork needs to run for. The routes rolled back after failing it are held,
+// the known good routes being applied instead until the desired routes change
+func routesToCheck(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork, routes []nics.Route) ([]nics.Route, bool, error) {
+	if pnet.Spec.RouteHealthCheck == nil {
+		nic.Status.KnownGoodRoutes = nil
+		nic.Status.RolledBackRoutes = nil
+		if nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesRolledBack) {
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesRolledBack, corev1.ConditionFalse, "HealthCheckDisabled", "")
+		}
+		if nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesUnhealthy) {
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesUnhealthy, corev1.ConditionFalse, "HealthCheckDisabled", "")
+		}
+		return routes, false, nil
+	}
+
+	keys := routeSetKeys(knownGoodRoutes(routes))
+	rolledBack := nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesRolledBack)
+	switch {
+	case reflect.DeepEqual(keys, routeSetKeys(nic.Status.KnownGoodRoutes)):
+		if rolledBack {
+			nic.Status.RolledBackRoutes = nil
+			nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesRolledBack, corev1.ConditionFalse, "KnownGoodRoutes", "")
+		}
+		return routes, false, nil
+	case rolledBack && reflect.DeepEqual(keys, nic.Status.RolledBackRoutes):
+		knownGood, err := routesFromKnownGood(nic.Status.KnownGoodRoutes, string(pnet.Spec.RouteConflictPolicy))
+		return knownGood, false, err
+	}
+	return routes, true, nil
+}
+
+// recordRouteHealth records the outcome of the route health check of the routes in the status, returning whether
+// they are to be rolled back to the known good routes and whether the condition reporting the failure changed.
+// Failing the first check, no route is known good and the routes are kept, the interface being reported unhealthy
+// and checked again on the next reconcile
+func recordRouteHealth(nic *vpcv1alpha1.NetworkInterface, routes []nics.Route, reachable bool, failure string) (bool, bool) {
+	checked := knownGoodRoutes(routes)
+	if reachable {
+		nic.Status.KnownGoodRoutes = checked
+		nic.Status.RolledBackRoutes = nil
+		for _, conditionType := range []vpcv1alpha1.NetworkInterfaceConditionType{vpcv1alpha1.NetworkInterfaceRoutesRolledBack, vpcv1alpha1.NetworkInterfaceRoutesUnhealthy} {
+			if nic.Status.GetCondition(conditionType) != nil {
+				nic.Status.SetCondition(conditionType, corev1.ConditionFalse, "HealthCheckPassed", "")
+			}
+		}
+		return false, false
+	}
+
+	if nic.Status.KnownGoodRoutes == nil {
+		msg := fmt.Sprintf("%s, no known good routes to roll back to", failure)
+		return false, nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesUnhealthy, corev1.ConditionTrue, "HealthCheckFailed", msg)
+	}
+	nic.Status.RolledBackRoutes = routeSetKeys(checked)
+	msg := fmt.Sprintf("%s, rolled back to the %d known good routes", failure, len(nic.Status.KnownGoodRoutes))
+	return true, nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesRolledBack, corev1.ConditionTrue, "HealthCheckFailed", msg)
+}
+
+// checkRouteHealth probes the target of the route health check of the PrivateNetwork after a change of the routes,
+// keeping them as the known good routes when it is reachable. Otherwise, it rolls back to the known good routes,
+// returning the results of their installation
+func (r *NetworkInterfaceReconciler) checkRouteHealth(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork, table int, routes []nics.Route) ([]nics.RouteResult, error) {
+	check := pnet.Spec.RouteHealthCheck
+	target := net.ParseIP(check.Target)
+	window := routeHealthCheckWindow(check)
+	reachable, err := r.NICs.ProbeReachable(routeHealthCheckSource(nic, target), target, window)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback, changed := recordRouteHealth(nic, routes, reachable, fmt.Sprintf("%s unreachable within %s", check.Target, window))
+	if reachable {
+		return nil, nil
+	}
+	if !rollback {
+		if changed {
+			r.Recorder.Event(nic, corev1.EventTypeWarning, "RoutesUnhealthy", nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceRoutesUnhealthy).Message)
+		}
+		return nil, nil
+	}
+
+	knownGood, err := routesFromKnownGood(nic.Status.KnownGoodRoutes, string(pnet.Spec.RouteConflictPolicy))
+	if err != nil {
+		return nil, err
+	}
+	results, err := r.NICs.ApplyRoutes(nic.Status.MacAddress, table, knownGood)
+	if err != nil {
+		return results, err
+	}
+	if changed {
+		r.Recorder.Event(nic, corev1.EventTypeWarning, "RoutesRolledBack", nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceRoutesRolledBack).Message)
+	}
+	return results, nil
+}
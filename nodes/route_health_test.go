@@ -0,0 +1,182 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+var _ = Describe("Route health check", func() {
+	mustRoute := func(to, via string, metric int) nics.Route {
+		route, err := nics.ParseRoute(to, via)
+		Expect(err).NotTo(HaveOccurred())
+		route.Metric = metric
+		return route
+	}
+	routes := []nics.Route{
+		mustRoute("10.0.0.0/16", "192.168.0.1", 100),
+		mustRoute("fd00:1::/64", "fd00::1", 0),
+	}
+	routes[1].Pref = "high"
+	_, connectedDst, _ := net.ParseCIDR("192.168.0.0/24")
+	connected := nics.Route{To: connectedDst, Src: net.ParseIP("192.168.0.10").To4()}
+	changed := []nics.Route{mustRoute("10.0.0.0/16", "192.168.0.254", 100)}
+	pnet := &vpcv1alpha1.PrivateNetwork{
+		Spec: vpcv1alpha1.PrivateNetworkSpec{
+			RouteHealthCheck:    &vpcv1alpha1.RouteHealthCheck{Target: "10.0.0.1"},
+			RouteConflictPolicy: vpcv1alpha1.RouteConflictPolicy(nics.RouteConflictSkip),
+		},
+	}
+
+	It("keeps the routes in the status", func() {
+		known := knownGoodRoutes(routes)
+		Expect(known).To(Equal([]vpcv1alpha1.KnownGoodRoute{
+			{To: "10.0.0.0/16", Via: "192.168.0.1", Metric: 100},
+			{To: "fd00:1::/64", Via: "fd00::1", Pref: "high"},
+		}))
+
+		restored, err := routesFromKnownGood(known, nics.RouteConflictSkip)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(knownGoodRoutes(restored)).To(Equal(known))
+		Expect(restored[0].OnConflict).To(Equal(nics.RouteConflictSkip))
+	})
+
+	It("keeps the connected routes in the status", func() {
+		known := knownGoodRoutes([]nics.Route{connected})
+		Expect(known).To(Equal([]vpcv1alpha1.KnownGoodRoute{{To: "192.168.0.0/24", Src: "192.168.0.10"}}))
+
+		restored, err := routesFromKnownGood(known, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored).To(Equal([]nics.Route{connected}))
+	})
+
+	It("compares route sets regardless of their order", func() {
+		reversed := []nics.Route{routes[1], routes[0]}
+		Expect(routeSetKeys(knownGoodRoutes(reversed))).To(Equal(routeSetKeys(knownGoodRoutes(routes))))
+		Expect(routeSetKeys(knownGoodRoutes(routes))).To(Equal([]string{
+			"10.0.0.0/16 via 192.168.0.1 metric 100",
+			"fd00:1::/64 via fd00::1 metric 0 pref high",
+		}))
+	})
+
+	It("checks a change of the routes", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		applied, check, err := routesToCheck(nic, pnet, routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeTrue())
+		Expect(applied).To(Equal(routes))
+
+		nic.Status.KnownGoodRoutes = knownGoodRoutes(routes)
+		_, check, err = routesToCheck(nic, pnet, routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeFalse())
+
+		_, check, err = routesToCheck(nic, pnet, changed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeTrue())
+	})
+
+	It("holds the rolled back routes until they change", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		nic.Status.KnownGoodRoutes = knownGoodRoutes(routes)
+		nic.Status.RolledBackRoutes = routeSetKeys(knownGoodRoutes(changed))
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesRolledBack, corev1.ConditionTrue, "HealthCheckFailed", "")
+
+		applied, check, err := routesToCheck(nic, pnet, changed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeFalse())
+		Expect(knownGoodRoutes(applied)).To(Equal(nic.Status.KnownGoodRoutes))
+
+		other := []nics.Route{mustRoute("10.0.0.0/16", "192.168.0.253", 100)}
+		applied, check, err = routesToCheck(nic, pnet, other)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeTrue())
+		Expect(applied).To(Equal(other))
+
+		_, check, err = routesToCheck(nic, pnet, routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeFalse())
+		Expect(nic.Status.RolledBackRoutes).To(BeNil())
+		Expect(nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesRolledBack)).To(BeFalse())
+	})
+
+	It("keeps the routes when the first check fails", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		applied, check, err := routesToCheck(nic, pnet, routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeTrue())
+
+		rollback, conditionChanged := recordRouteHealth(nic, applied, false, "10.0.0.1 unreachable within 10s")
+		Expect(rollback).To(BeFalse())
+		Expect(conditionChanged).To(BeTrue())
+		Expect(nic.Status.KnownGoodRoutes).To(BeNil())
+		Expect(nic.Status.RolledBackRoutes).To(BeNil())
+		Expect(nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesUnhealthy)).To(BeTrue())
+		Expect(nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceRoutesRolledBack)).To(BeNil())
+
+		applied, check, err = routesToCheck(nic, pnet, routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeTrue())
+		Expect(applied).To(Equal(routes))
+
+		rollback, _ = recordRouteHealth(nic, applied, true, "")
+		Expect(rollback).To(BeFalse())
+		Expect(nic.Status.KnownGoodRoutes).To(Equal(knownGoodRoutes(routes)))
+		Expect(nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesUnhealthy)).To(BeFalse())
+	})
+
+	It("rolls back a failing change to the known good routes", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		nic.Status.KnownGoodRoutes = knownGoodRoutes(routes)
+
+		rollback, conditionChanged := recordRouteHealth(nic, changed, false, "10.0.0.1 unreachable within 10s")
+		Expect(rollback).To(BeTrue())
+		Expect(conditionChanged).To(BeTrue())
+		Expect(nic.Status.KnownGoodRoutes).To(Equal(knownGoodRoutes(routes)))
+		Expect(nic.Status.RolledBackRoutes).To(Equal(routeSetKeys(knownGoodRoutes(changed))))
+		Expect(nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesRolledBack)).To(BeTrue())
+	})
+
+	It("forgets the known good routes without health check", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		nic.Status.KnownGoodRoutes = knownGoodRoutes(routes)
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceRoutesRolledBack, corev1.ConditionTrue, "HealthCheckFailed", "")
+
+		_, check, err := routesToCheck(nic, &vpcv1alpha1.PrivateNetwork{}, changed)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(check).To(BeFalse())
+		Expect(nic.Status.KnownGoodRoutes).To(BeNil())
+		Expect(nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceRoutesRolledBack)).To(BeFalse())
+	})
+
+	It("pings the target from the address of its family", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		nic.Status.Address = "192.168.0.10/24"
+		nic.Status.IPv6Address = "fd00::10/64"
+		Expect(routeHealthCheckSource(nic, parseAddress("10.0.0.1")).String()).To(Equal("192.168.0.10"))
+		Expect(routeHealthCheckSource(nic, parseAddress("fd00:1::1")).String()).To(Equal("fd00::10"))
+		nic.Status.IPv6Address = ""
+		Expect(routeHealthCheckSource(nic, parseAddress("fd00:1::1"))).To(BeNil())
+	})
+})
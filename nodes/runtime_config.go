@@ -0,0 +1,174 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// RuntimeSettings are the settings of the node daemon that can be changed without restarting it
+type RuntimeSettings struct {
+	// RequeueInterval is the RequeueInterval of the NetworkInterfaceReconciler
+	RequeueInterval time.Duration
+	// LinkUpTimeout is the LinkUpTimeout of the NetworkInterfaceReconciler
+	LinkUpTimeout time.Duration
+	// SlowReconcileThreshold is the SlowReconcileThreshold of the NetworkInterfaceReconciler
+	SlowReconcileThreshold time.Duration
+	// DADProbeCount is the DADProbeCount of the NetworkInterfaceReconciler
+	DADProbeCount int
+	// LogLevel is the verbosity of the logs
+	LogLevel int
+}
+
+// parseRuntimeSettings returns the settings of the data of a ConfigMap, the keys not set keeping
+// the given defaults. Unknown keys and invalid values are rejected
+func parseRuntimeSettings(data map[string]string, defaults RuntimeSettings) (RuntimeSettings, error) {
+	settings := defaults
+	durations := map[string]*time.Duration{
+		"requeueInterval":        &settings.RequeueInterval,
+		"linkUpTimeout":          &settings.LinkUpTimeout,
+		"slowReconcileThreshold": &settings.SlowReconcileThreshold,
+	}
+	ints := map[string]*int{
+		"dadProbeCount": &settings.DADProbeCount,
+		"logLevel":      &settings.LogLevel,
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := data[key]
+		if duration, ok := durations[key]; ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return defaults, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			if d < 0 {
+				return defaults, fmt.Errorf("invalid %s: %s is negative", key, value)
+			}
+			*duration = d
+			continue
+		}
+		if i, ok := ints[key]; ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return defaults, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			if n < 0 {
+				return defaults, fmt.Errorf("invalid %s: %s is negative", key, value)
+			}
+			*i = n
+			continue
+		}
+		return defaults, fmt.Errorf("unknown setting %s", key)
+	}
+	return settings, nil
+}
+
+// RuntimeConfig loads the RuntimeSettings from a ConfigMap, and reloads them whenever it changes.
+// An invalid ConfigMap is rejected, keeping the settings in effect, and the Defaults are set back
+// when it is deleted
+type RuntimeConfig struct {
+	Namespace string
+	Name      string
+	Client    kubernetes.Interface
+	Defaults  RuntimeSettings
+	Log       logr.Logger
+	// SetLogLevel sets the verbosity of the logs, nil leaves it unchanged
+	SetLogLevel func(level int) error
+
+	mu       sync.RWMutex
+	settings RuntimeSettings
+}
+
+// NewRuntimeConfig returns a RuntimeConfig loading the given ConfigMap, with the given defaults
+func NewRuntimeConfig(client kubernetes.Interface, namespace, name string, defaults RuntimeSettings, log logr.Logger) *RuntimeConfig {
+	return &RuntimeConfig{
+		Namespace: namespace,
+		Name:      name,
+		Client:    client,
+		Defaults:  defaults,
+		Log:       log,
+		settings:  defaults,
+	}
+}
+
+// Settings returns the settings in effect
+func (c *RuntimeConfig) Settings() RuntimeSettings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings
+}
+
+// load sets the settings of the data of the ConfigMap, nil for a deleted ConfigMap
+func (c *RuntimeConfig) load(data map[string]string) {
+	settings, err := parseRuntimeSettings(data, c.Defaults)
+	if err != nil {
+		c.Log.Error(err, "invalid runtime config, keeping the settings in effect", "configmap", c.Namespace+"/"+c.Name)
+		runtimeConfigReloadsTotal.WithLabelValues(metricsResult(err)).Inc()
+		return
+	}
+	if c.SetLogLevel != nil {
+		err = c.SetLogLevel(settings.LogLevel)
+		if err != nil {
+			c.Log.Error(err, "unable to set log level, keeping the settings in effect", "configmap", c.Namespace+"/"+c.Name)
+			runtimeConfigReloadsTotal.WithLabelValues(metricsResult(err)).Inc()
+			return
+		}
+	}
+
+	c.mu.Lock()
+	changed := c.settings != settings
+	c.settings = settings
+	c.mu.Unlock()
+	if changed {
+		c.Log.Info("loaded runtime config", "configmap", c.Namespace+"/"+c.Name, "settings", fmt.Sprintf("%+v", settings))
+	}
+	runtimeConfigReloadsTotal.WithLabelValues(metricsResult(nil)).Inc()
+}
+
+// Start watches the ConfigMap until stop is closed
+func (c *RuntimeConfig) Start(stop <-chan struct{}) error {
+	lw := cache.NewListWatchFromClient(c.Client.CoreV1().RESTClient(), "configmaps", c.Namespace,
+		fields.OneTermEqualSelector("metadata.name", c.Name))
+	_, informer := cache.NewInformer(lw, &corev1.ConfigMap{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.load(obj.(*corev1.ConfigMap).Data)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			c.load(obj.(*corev1.ConfigMap).Data)
+		},
+		DeleteFunc: func(interface{}) {
+			c.load(nil)
+		},
+	})
+	informer.Run(stop)
+	return nil
+}
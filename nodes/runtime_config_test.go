@@ -0,0 +1,83 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Runtime config", func() {
+	defaults := RuntimeSettings{
+		RequeueInterval: time.Minute,
+		LinkUpTimeout:   time.Second * 10,
+		LogLevel:        2,
+	}
+
+	It("overrides the defaults with the settings of the ConfigMap", func() {
+		settings, err := parseRuntimeSettings(map[string]string{
+			"requeueInterval":        "5m",
+			"slowReconcileThreshold": "2s",
+			"dadProbeCount":          "3",
+			"logLevel":               "4",
+		}, defaults)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(settings).To(Equal(RuntimeSettings{
+			RequeueInterval:        time.Minute * 5,
+			LinkUpTimeout:          time.Second * 10,
+			SlowReconcileThreshold: time.Second * 2,
+			DADProbeCount:          3,
+			LogLevel:               4,
+		}))
+	})
+
+	It("rejects the unknown and invalid settings", func() {
+		for _, data := range []map[string]string{
+			{"requeueIntervall": "5m"},
+			{"requeueInterval": "5"},
+			{"linkUpTimeout": "-1s"},
+			{"dadProbeCount": "three"},
+			{"logLevel": "-1"},
+		} {
+			_, err := parseRuntimeSettings(data, defaults)
+			Expect(err).To(HaveOccurred(), fmt.Sprintf("%v", data))
+		}
+	})
+
+	It("keeps the settings in effect on an invalid ConfigMap and sets back the defaults on deletion", func() {
+		levels := []int{}
+		config := NewRuntimeConfig(nil, "kube-system", "scaleway-k8s-vpc", defaults, ctrl.Log)
+		config.SetLogLevel = func(level int) error {
+			levels = append(levels, level)
+			return nil
+		}
+
+		config.load(map[string]string{"requeueInterval": "5m", "logLevel": "4"})
+		Expect(config.Settings().RequeueInterval).To(Equal(time.Minute * 5))
+
+		config.load(map[string]string{"requeueInterval": "soon"})
+		Expect(config.Settings().RequeueInterval).To(Equal(time.Minute * 5))
+
+		config.load(nil)
+		Expect(config.Settings()).To(Equal(defaults))
+		Expect(levels).To(Equal([]int{4, 2}))
+	})
+})
@@ -0,0 +1,94 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reconcileStepsKey is the context key of the steps of a reconcile
+type reconcileStepsKey struct{}
+
+// reconcileSteps records the duration of the steps of a reconcile, so that a slow reconcile reports
+// which step dominated. A nil reconcileSteps records nothing
+type reconcileSteps struct {
+	start     time.Time
+	current   string
+	begun     time.Time
+	durations map[string]time.Duration
+}
+
+func newReconcileSteps(now time.Time) *reconcileSteps {
+	return &reconcileSteps{
+		start:     now,
+		current:   "get",
+		begun:     now,
+		durations: make(map[string]time.Duration),
+	}
+}
+
+// withReconcileSteps returns a context carrying the steps of the reconcile
+func withReconcileSteps(ctx context.Context, steps *reconcileSteps) context.Context {
+	return context.WithValue(ctx, reconcileStepsKey{}, steps)
+}
+
+// stepsFrom returns the steps of the reconcile carried by the context, nil if none
+func stepsFrom(ctx context.Context) *reconcileSteps {
+	steps, _ := ctx.Value(reconcileStepsKey{}).(*reconcileSteps)
+	return steps
+}
+
+// step ends the current step of the reconcile and begins the given one
+func (s *reconcileSteps) step(name string) {
+	if s == nil {
+		return
+	}
+	s.stepAt(name, time.Now())
+}
+
+func (s *reconcileSteps) stepAt(name string, now time.Time) {
+	s.durations[s.current] += now.Sub(s.begun)
+	s.current = name
+	s.begun = now
+}
+
+// breakdown ends the current step and returns the total duration of the reconcile, and its steps
+// by decreasing duration, such as "routes=1.2s, metadata=300ms"
+func (s *reconcileSteps) breakdown(now time.Time) (time.Duration, string) {
+	s.stepAt("", now)
+	names := make([]string, 0, len(s.durations))
+	for name, duration := range s.durations {
+		if name != "" && duration > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.durations[names[i]] != s.durations[names[j]] {
+			return s.durations[names[i]] > s.durations[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, s.durations[name].Round(time.Millisecond)))
+	}
+	return now.Sub(s.start), strings.Join(parts, ", ")
+}
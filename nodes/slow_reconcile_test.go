@@ -0,0 +1,48 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Slow reconciles", func() {
+	It("reports the steps by decreasing duration", func() {
+		start := time.Now()
+		steps := newReconcileSteps(start)
+		steps.stepAt("metadata", start.Add(time.Millisecond*10))
+		steps.stepAt("routes", start.Add(time.Second))
+		steps.stepAt("metadata", start.Add(time.Second*3))
+
+		duration, breakdown := steps.breakdown(start.Add(time.Second * 4))
+		Expect(duration).To(Equal(time.Second * 4))
+		Expect(breakdown).To(Equal("routes=2s, metadata=1.99s, get=10ms"))
+	})
+
+	It("records nothing without steps in the context", func() {
+		steps := stepsFrom(context.Background())
+		Expect(steps).To(BeNil())
+		steps.step("routes")
+
+		steps = newReconcileSteps(time.Now())
+		Expect(stepsFrom(withReconcileSteps(context.Background(), steps))).To(BeIdenticalTo(steps))
+	})
+})
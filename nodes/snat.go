@@ -0,0 +1,219 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+const (
+	snatTable       = "nat"
+	snatParentChain = "POSTROUTING"
+	snatChainPrefix = "SCW-VPC-SNAT-"
+	// snatNFTable is the nftables table holding a chain per link with its masquerade rule
+	snatNFTable = "scw_vpc_snat"
+)
+
+// snatBackend returns the backend the masquerade rule is installed with
+func snatBackend(snat *vpcv1alpha1.SNAT) vpcv1alpha1.SNATBackend {
+	if snat.Backend == "" {
+		return vpcv1alpha1.SNATBackendIPTables
+	}
+	return snat.Backend
+}
+
+// snatChain returns the iptables chain holding the masquerade rule of a link
+func snatChain(linkName string) string {
+	return snatChainPrefix + linkName
+}
+
+// snatRule returns the iptables masquerade rule of the chain of a link
+func snatRule(cidr *net.IPNet) []string {
+	return []string{"-s", cidr.String(), "-j", "MASQUERADE"}
+}
+
+// snatJumpRule returns the rule sending the traffic leaving through a link to its chain
+func snatJumpRule(linkName string) []string {
+	return []string{"-o", linkName, "-j", snatChain(linkName)}
+}
+
+// nftSNATScript returns the nft commands replacing the masquerade rule of a link in its chain,
+// applied in a single transaction
+func nftSNATScript(linkName string, cidr *net.IPNet) string {
+	family := "ip"
+	if cidr.IP.To4() == nil {
+		family = "ip6"
+	}
+	chain := fmt.Sprintf("inet %s %q", snatNFTable, linkName)
+	return strings.Join([]string{
+		"add table inet " + snatNFTable,
+		"add chain " + chain + " { type nat hook postrouting priority 100; }",
+		"flush chain " + chain,
+		fmt.Sprintf("add rule %s oifname %q %s saddr %s masquerade", chain, linkName, family, cidr.String()),
+	}, "\n") + "\n"
+}
+
+// nftRemoveSNATScript returns the nft commands removing the chain of a link, whether it exists or not
+func nftRemoveSNATScript(linkName string) string {
+	chain := fmt.Sprintf("inet %s %q", snatNFTable, linkName)
+	return strings.Join([]string{
+		"add table inet " + snatNFTable,
+		"add chain " + chain + " { type nat hook postrouting priority 100; }",
+		"delete chain " + chain,
+	}, "\n") + "\n"
+}
+
+// syncSNAT installs the masquerade rule of the traffic of the source range of snat leaving through a link with
+// its backend, after removing it from the installed backend when it changed, or removes it when snat is nil.
+// It returns the backend now holding the rule
+func syncSNAT(linkName string, snat *vpcv1alpha1.SNAT, installed vpcv1alpha1.SNATBackend) (vpcv1alpha1.SNATBackend, error) {
+	var backend vpcv1alpha1.SNATBackend
+	var cidr *net.IPNet
+	if snat != nil {
+		var err error
+		_, cidr, err = net.ParseCIDR(snat.SourceCIDR)
+		if err != nil {
+			return installed, fmt.Errorf("invalid snat source cidr %s: %w", snat.SourceCIDR, err)
+		}
+		backend = snatBackend(snat)
+	}
+
+	if installed != "" && installed != backend {
+		err := removeSNAT(linkName, installed)
+		if err != nil {
+			return installed, err
+		}
+	}
+	if snat == nil {
+		return "", nil
+	}
+
+	var err error
+	switch backend {
+	case vpcv1alpha1.SNATBackendNFTables:
+		err = runNFT(nftSNATScript(linkName, cidr))
+	default:
+		err = addIPTablesSNAT(linkName, cidr)
+	}
+	// the rule may be partially installed on error, and is removed from this backend when it changes
+	return backend, err
+}
+
+func removeSNAT(linkName string, backend vpcv1alpha1.SNATBackend) error {
+	if backend == vpcv1alpha1.SNATBackendNFTables {
+		return runNFT(nftRemoveSNATScript(linkName))
+	}
+	for _, proto := range []iptables.Protocol{iptables.ProtocolIPv4, iptables.ProtocolIPv6} {
+		ipt, err := iptables.NewWithProtocol(proto)
+		if err != nil {
+			return err
+		}
+		err = removeIPTablesSNAT(ipt, linkName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addIPTablesSNAT installs the masquerade rule in the chain of the link for the family of the cidr,
+// removing it from the other family
+func addIPTablesSNAT(linkName string, cidr *net.IPNet) error {
+	proto, other := iptables.ProtocolIPv4, iptables.ProtocolIPv6
+	if cidr.IP.To4() == nil {
+		proto, other = other, proto
+	}
+
+	ipt, err := iptables.NewWithProtocol(other)
+	if err != nil {
+		return err
+	}
+	err = removeIPTablesSNAT(ipt, linkName)
+	if err != nil {
+		return err
+	}
+
+	ipt, err = iptables.NewWithProtocol(proto)
+	if err != nil {
+		return err
+	}
+	chain := snatChain(linkName)
+	exists, err := ipt.ChainExists(snatTable, chain)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = ipt.NewChain(snatTable, chain)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the chain only holds the masquerade rule, rebuilt when the source range changes
+	rule := snatRule(cidr)
+	exists, err = ipt.Exists(snatTable, chain, rule...)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = ipt.ClearChain(snatTable, chain)
+		if err != nil {
+			return err
+		}
+		err = ipt.Append(snatTable, chain, rule...)
+		if err != nil {
+			return err
+		}
+	}
+	return ipt.AppendUnique(snatTable, snatParentChain, snatJumpRule(linkName)...)
+}
+
+func removeIPTablesSNAT(ipt *iptables.IPTables, linkName string) error {
+	err := ipt.DeleteIfExists(snatTable, snatParentChain, snatJumpRule(linkName)...)
+	if err != nil {
+		return err
+	}
+
+	chain := snatChain(linkName)
+	exists, err := ipt.ChainExists(snatTable, chain)
+	if err != nil || !exists {
+		return err
+	}
+	err = ipt.ClearChain(snatTable, chain)
+	if err != nil {
+		return err
+	}
+	return ipt.DeleteChain(snatTable, chain)
+}
+
+// runNFT applies the nft commands of the script
+func runNFT(script string) error {
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nft failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
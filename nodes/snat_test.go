@@ -0,0 +1,57 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var _ = Describe("SNAT", func() {
+	_, ipv4CIDR, _ := net.ParseCIDR("10.0.0.0/24")
+	_, ipv6CIDR, _ := net.ParseCIDR("fd00:1::/64")
+
+	It("defaults to the iptables backend", func() {
+		Expect(snatBackend(&vpcv1alpha1.SNAT{SourceCIDR: "10.0.0.0/24"})).To(Equal(vpcv1alpha1.SNATBackendIPTables))
+		Expect(snatBackend(&vpcv1alpha1.SNAT{SourceCIDR: "10.0.0.0/24", Backend: vpcv1alpha1.SNATBackendNFTables})).To(Equal(vpcv1alpha1.SNATBackendNFTables))
+	})
+
+	It("masquerades the source range in the chain of the link", func() {
+		Expect(snatRule(ipv4CIDR)).To(Equal([]string{"-s", "10.0.0.0/24", "-j", "MASQUERADE"}))
+		Expect(snatJumpRule("ens5")).To(Equal([]string{"-o", "ens5", "-j", "SCW-VPC-SNAT-ens5"}))
+	})
+
+	It("replaces the nftables rule in a single transaction", func() {
+		Expect(nftSNATScript("ens5", ipv4CIDR)).To(Equal(`add table inet scw_vpc_snat
+add chain inet scw_vpc_snat "ens5" { type nat hook postrouting priority 100; }
+flush chain inet scw_vpc_snat "ens5"
+add rule inet scw_vpc_snat "ens5" oifname "ens5" ip saddr 10.0.0.0/24 masquerade
+`))
+		Expect(nftSNATScript("ens5", ipv6CIDR)).To(ContainSubstring(`oifname "ens5" ip6 saddr fd00:1::/64 masquerade`))
+		Expect(nftRemoveSNATScript("ens5")).To(HaveSuffix("delete chain inet scw_vpc_snat \"ens5\"\n"))
+	})
+
+	It("rejects an invalid source range", func() {
+		backend, err := syncSNAT("ens5", &vpcv1alpha1.SNAT{SourceCIDR: "10.0.0.1"}, vpcv1alpha1.SNATBackendNFTables)
+		Expect(err).To(HaveOccurred())
+		Expect(backend).To(Equal(vpcv1alpha1.SNATBackendNFTables))
+	})
+})
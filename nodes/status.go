@@ -0,0 +1,116 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+// statusUpdateBackoff is the backoff between the retries of a status patch conflicting with a concurrent
+// update, fully jittered so that the nodes updating at the same time don't retry in lockstep
+var statusUpdateBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 10 * time.Millisecond,
+	Factor:   2,
+	Jitter:   1,
+}
+
+// patchStatus patches the status of a NetworkInterface from the given base status. The merge patch only carries
+// the fields changed since the base, and is rejected when the NetworkInterface changed since it was read, not to
+// overwrite the fields set concurrently by the controller. On conflicts, the status is applied again on the latest
+// version of the NetworkInterface, keeping the fields owned by the controller, and the patch is skipped when
+// the latest version already has it. nic keeps its status but for the fields owned by the controller,
+// and its resource version is updated
+func (r *NetworkInterfaceReconciler) patchStatus(ctx context.Context, nic *vpcv1alpha1.NetworkInterface, base *vpcv1alpha1.NetworkInterfaceStatus) error {
+	if equality.Semantic.DeepEqual(*base, nic.Status) {
+		return nil
+	}
+	desired := nic.Status.DeepCopy()
+	conflicted := false
+	err := retry.RetryOnConflict(statusUpdateBackoff, func() error {
+		if conflicted {
+			latest := &vpcv1alpha1.NetworkInterface{}
+			err := r.Client.Get(ctx, types.NamespacedName{Namespace: nic.Namespace, Name: nic.Name}, latest)
+			if err != nil {
+				return err
+			}
+			nic.ResourceVersion = latest.ResourceVersion
+			nic.Status = mergeStatus(desired, &latest.Status)
+			base = latest.Status.DeepCopy()
+			if equality.Semantic.DeepEqual(*base, nic.Status) {
+				return nil
+			}
+		}
+		patched, patch := statusPatch(nic, base)
+		err := r.Client.Status().Patch(ctx, patched, patch)
+		if apierrors.IsConflict(err) {
+			conflicted = true
+			statusUpdateConflictsTotal.Inc()
+		}
+		if err != nil {
+			return err
+		}
+		nic.ResourceVersion = patched.ResourceVersion
+		return nil
+	})
+	return client.IgnoreNotFound(err)
+}
+
+// statusPatch returns a copy of a NetworkInterface and the merge patch turning the given base status into its status,
+// conditioned on the resource version of the NetworkInterface
+func statusPatch(nic *vpcv1alpha1.NetworkInterface, base *vpcv1alpha1.NetworkInterfaceStatus) (*vpcv1alpha1.NetworkInterface, client.Patch) {
+	original := nic.DeepCopy()
+	original.Status = *base.DeepCopy()
+	return nic.DeepCopy(), client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+}
+
+// mergeStatus returns the status computed by the node with the fields owned by the controller
+// taken from the latest status
+func mergeStatus(desired, latest *vpcv1alpha1.NetworkInterfaceStatus) vpcv1alpha1.NetworkInterfaceStatus {
+	status := *desired.DeepCopy()
+	status.MacAddress = latest.MacAddress
+	status.IPv6Address = latest.IPv6Address
+	status.IPv6ParentCIDR = latest.IPv6ParentCIDR
+	// the addresses of the static IPAM have a parent cidr, the DHCP ones are set by the node
+	if latest.ParentCIDR != "" {
+		status.Address = latest.Address
+	}
+	status.ParentCIDR = latest.ParentCIDR
+	status.ResolvedAddress = latest.ResolvedAddress
+
+	conditions := make([]vpcv1alpha1.NetworkInterfaceCondition, 0, len(status.Conditions))
+	for _, condition := range status.Conditions {
+		if condition.Type != vpcv1alpha1.NetworkInterfaceAddressResolved {
+			conditions = append(conditions, condition)
+		}
+	}
+	if condition := latest.GetCondition(vpcv1alpha1.NetworkInterfaceAddressResolved); condition != nil {
+		conditions = append(conditions, *condition)
+	}
+	status.Conditions = conditions
+	return status
+}
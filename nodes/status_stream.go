@@ -0,0 +1,186 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+const (
+	// StatusStateConfigured is the state of a NetworkInterface whose link is ready
+	StatusStateConfigured = "Configured"
+	// StatusStateDegraded is the state of a NetworkInterface whose link is not ready
+	StatusStateDegraded = "Degraded"
+	// StatusStateTornDown is the state of a NetworkInterface whose link was torn down on deletion
+	StatusStateTornDown = "TornDown"
+)
+
+// statusStreamWriteTimeout is how long a record is waited for to be written to a client before dropping it
+const statusStreamWriteTimeout = time.Second
+
+// StatusRecord is the state of a NetworkInterface sent to the clients of a StatusStream
+type StatusRecord struct {
+	Time       time.Time `json:"time"`
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	MacAddress string    `json:"macAddress"`
+	LinkName   string    `json:"linkName,omitempty"`
+	Address    string    `json:"address,omitempty"`
+	// Conditions are the statuses of the conditions of the NetworkInterface, by type
+	Conditions map[string]corev1.ConditionStatus `json:"conditions,omitempty"`
+}
+
+// StatusStream sends a record to the clients connected to a Unix socket, as newline-delimited JSON,
+// whenever the status of a NetworkInterface of the node materially changes
+type StatusStream struct {
+	// Path is the path of the Unix socket
+	Path string
+	Log  logr.Logger
+
+	mu      sync.Mutex
+	clients map[net.Conn]bool
+}
+
+// NewStatusStream returns a StatusStream listening on the Unix socket at the given path
+func NewStatusStream(path string, log logr.Logger) *StatusStream {
+	return &StatusStream{
+		Path:    path,
+		Log:     log,
+		clients: make(map[net.Conn]bool),
+	}
+}
+
+// Start accepts the clients of the socket until stop is closed
+func (s *StatusStream) Start(stop <-chan struct{}) error {
+	// a socket left by a previous run prevents listening
+	err := os.Remove(s.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	listener, err := net.Listen("unix", s.Path)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(s.Path, 0600)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		<-stop
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-stop:
+				s.closeClients()
+				return nil
+			default:
+			}
+			s.Log.Error(err, "unable to accept status stream client")
+			continue
+		}
+		s.mu.Lock()
+		s.clients[conn] = true
+		s.mu.Unlock()
+	}
+}
+
+// Publish sends the record to the connected clients, dropping the ones it can't be written to
+func (s *StatusStream) Publish(record StatusRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		s.Log.Error(err, "unable to encode status record")
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		err := conn.SetWriteDeadline(time.Now().Add(statusStreamWriteTimeout))
+		if err == nil {
+			_, err = conn.Write(line)
+		}
+		if err != nil {
+			s.Log.V(1).Info("dropping status stream client", "error", err.Error())
+			conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+}
+
+// closeClients disconnects all the clients
+func (s *StatusStream) closeClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		conn.Close()
+		delete(s.clients, conn)
+	}
+}
+
+// statusRecord returns the record of the given status of a NetworkInterface, without its time
+func statusRecord(nic *vpcv1alpha1.NetworkInterface, status *vpcv1alpha1.NetworkInterfaceStatus) StatusRecord {
+	record := StatusRecord{
+		Name:       nic.Name,
+		State:      StatusStateDegraded,
+		MacAddress: status.MacAddress,
+		LinkName:   status.LinkName,
+		Address:    status.Address,
+	}
+	if status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceReady) {
+		record.State = StatusStateConfigured
+	}
+	if len(status.Conditions) != 0 {
+		record.Conditions = make(map[string]corev1.ConditionStatus, len(status.Conditions))
+		for _, condition := range status.Conditions {
+			record.Conditions[string(condition.Type)] = condition.Status
+		}
+	}
+	return record
+}
+
+// publishStatus sends the status of a NetworkInterface to the StatusStream, if any, when it materially changed
+// from the given previous status, or when its link was torn down
+func (r *NetworkInterfaceReconciler) publishStatus(nic *vpcv1alpha1.NetworkInterface, previous *vpcv1alpha1.NetworkInterfaceStatus, tornDown bool) {
+	if r.StatusStream == nil {
+		return
+	}
+	record := statusRecord(nic, &nic.Status)
+	if tornDown {
+		record.State = StatusStateTornDown
+	} else if reflect.DeepEqual(record, statusRecord(nic, previous)) {
+		return
+	}
+	record.Time = time.Now()
+	r.StatusStream.Publish(record)
+}
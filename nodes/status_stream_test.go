@@ -0,0 +1,87 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var _ = Describe("Status stream", func() {
+	It("summarizes the status of a NetworkInterface", func() {
+		nic := &vpcv1alpha1.NetworkInterface{ObjectMeta: metav1.ObjectMeta{Name: "nic"}}
+		nic.Status.MacAddress = "02:00:00:00:00:01"
+		nic.Status.LinkName = "ens5"
+		Expect(statusRecord(nic, &nic.Status).State).To(Equal(StatusStateDegraded))
+
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionTrue, "Configured", "")
+		Expect(statusRecord(nic, &nic.Status)).To(Equal(StatusRecord{
+			Name:       "nic",
+			State:      StatusStateConfigured,
+			MacAddress: "02:00:00:00:00:01",
+			LinkName:   "ens5",
+			Conditions: map[string]corev1.ConditionStatus{string(vpcv1alpha1.NetworkInterfaceReady): corev1.ConditionTrue},
+		}))
+	})
+
+	It("sends the records to the connected clients", func() {
+		dir, err := ioutil.TempDir("", "status-stream")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		stream := NewStatusStream(filepath.Join(dir, "status.sock"), ctrl.Log)
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			defer GinkgoRecover()
+			Expect(stream.Start(stop)).To(Succeed())
+		}()
+
+		var conn net.Conn
+		Eventually(func() error {
+			conn, err = net.Dial("unix", stream.Path)
+			return err
+		}).Should(Succeed())
+		defer conn.Close()
+		Eventually(func() int {
+			stream.mu.Lock()
+			defer stream.mu.Unlock()
+			return len(stream.clients)
+		}).Should(Equal(1))
+
+		stream.Publish(StatusRecord{Name: "nic", State: StatusStateTornDown, MacAddress: "02:00:00:00:00:01"})
+
+		line, err := bufio.NewReader(conn).ReadBytes('\n')
+		Expect(err).NotTo(HaveOccurred())
+		record := StatusRecord{}
+		Expect(json.Unmarshal(line, &record)).To(Succeed())
+		Expect(record.Name).To(Equal("nic"))
+		Expect(record.State).To(Equal(StatusStateTornDown))
+	})
+})
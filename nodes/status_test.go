@@ -0,0 +1,91 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var _ = Describe("Status patch", func() {
+	It("only carries the fields changed since the base status", func() {
+		nic := &vpcv1alpha1.NetworkInterface{
+			ObjectMeta: metav1.ObjectMeta{Name: "nic", ResourceVersion: "1"},
+			Status: vpcv1alpha1.NetworkInterfaceStatus{
+				MacAddress: "02:00:00:00:00:01",
+				Address:    "192.168.0.10/24",
+			},
+		}
+		base := nic.Status.DeepCopy()
+		nic.Status.LinkName = "ens5"
+
+		patched, patch := statusPatch(nic, base)
+		data, err := patch.Data(patched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(MatchJSON(`{"metadata":{"resourceVersion":"1"},"status":{"linkName":"ens5"}}`))
+	})
+
+	It("replaces the conditions when one changed", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionFalse, "LinkNotFound", "")
+		base := nic.Status.DeepCopy()
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionTrue, "Configured", "")
+
+		patched, patch := statusPatch(nic, base)
+		data, err := patch.Data(patched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"reason":"Configured"`))
+		Expect(string(data)).NotTo(ContainSubstring("macAddress"))
+	})
+
+	It("keeps the fields owned by the controller from the latest status on conflicts", func() {
+		desired := &vpcv1alpha1.NetworkInterfaceStatus{
+			MacAddress: "02:00:00:00:00:01",
+			Address:    "192.168.0.10/24",
+			ParentCIDR: "192.168.0.0/24",
+			LinkName:   "ens5",
+		}
+		desired.SetCondition(vpcv1alpha1.NetworkInterfaceReady, corev1.ConditionTrue, "Configured", "")
+		desired.SetCondition(vpcv1alpha1.NetworkInterfaceAddressResolved, corev1.ConditionFalse, "NotFound", "")
+
+		latest := &vpcv1alpha1.NetworkInterfaceStatus{
+			MacAddress:      "02:00:00:00:00:01",
+			Address:         "192.168.0.20/24",
+			ParentCIDR:      "192.168.0.0/24",
+			IPv6Address:     "fd00::20/64",
+			ResolvedAddress: "192.168.1.10/24",
+		}
+		latest.SetCondition(vpcv1alpha1.NetworkInterfaceAddressResolved, corev1.ConditionTrue, "Resolved", "")
+
+		status := mergeStatus(desired, latest)
+		Expect(status.Address).To(Equal("192.168.0.20/24"))
+		Expect(status.IPv6Address).To(Equal("fd00::20/64"))
+		Expect(status.ResolvedAddress).To(Equal("192.168.1.10/24"))
+		Expect(status.LinkName).To(Equal("ens5"))
+		Expect(status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceReady)).To(BeTrue())
+		Expect(status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceAddressResolved)).To(BeTrue())
+	})
+
+	It("keeps the address set by the node without static IPAM", func() {
+		status := mergeStatus(&vpcv1alpha1.NetworkInterfaceStatus{Address: "192.168.0.10/24"}, &vpcv1alpha1.NetworkInterfaceStatus{})
+		Expect(status.Address).To(Equal("192.168.0.10/24"))
+	})
+})
@@ -0,0 +1,56 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+// sysctlFailures collects the sysctls of a link failing to be applied, for instance on nodes where /proc/sys
+// is read-only. They are optional tuning, so they don't fail the reconcile unless strict
+type sysctlFailures struct {
+	strict   bool
+	messages []string
+}
+
+// add logs the failure and records it, returning the error back in strict mode only
+func (f *sysctlFailures) add(log logr.Logger, err error, msg string) error {
+	log.Error(err, msg)
+	if f.strict {
+		return err
+	}
+	f.messages = append(f.messages, fmt.Sprintf("%s: %s", msg, err))
+	return nil
+}
+
+// setCondition reports the failures in the SysctlsApplied condition of the NetworkInterface,
+// returning whether they changed
+func (f *sysctlFailures) setCondition(nic *vpcv1alpha1.NetworkInterface) bool {
+	if len(f.messages) != 0 {
+		return nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceSysctlsApplied, corev1.ConditionFalse, "SysctlFailed", strings.Join(f.messages, "; "))
+	}
+	if nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceSysctlsApplied) != nil {
+		nic.Status.SetCondition(vpcv1alpha1.NetworkInterfaceSysctlsApplied, corev1.ConditionTrue, "Applied", "")
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+var _ = Describe("Sysctl failures", func() {
+	err := fmt.Errorf("open /proc/sys/net/ipv4/conf/ens5/accept_local: read-only file system")
+
+	It("fails the reconcile in strict mode", func() {
+		sysctls := sysctlFailures{strict: true}
+		Expect(sysctls.add(ctrl.Log, err, "unable to set local routing sysctls")).To(Equal(err))
+		Expect(sysctls.setCondition(&vpcv1alpha1.NetworkInterface{})).To(BeFalse())
+	})
+
+	It("reports the failures in the condition otherwise", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		sysctls := sysctlFailures{}
+		Expect(sysctls.add(ctrl.Log, err, "unable to set local routing sysctls")).To(Succeed())
+		Expect(sysctls.setCondition(nic)).To(BeTrue())
+		condition := nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceSysctlsApplied)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.Message).To(ContainSubstring("read-only file system"))
+
+		sysctls = sysctlFailures{}
+		Expect(sysctls.add(ctrl.Log, err, "unable to set local routing sysctls")).To(Succeed())
+		Expect(sysctls.setCondition(nic)).To(BeFalse())
+
+		Expect((&sysctlFailures{}).setCondition(nic)).To(BeFalse())
+		Expect(nic.Status.IsConditionTrue(vpcv1alpha1.NetworkInterfaceSysctlsApplied)).To(BeTrue())
+	})
+
+	It("only reports the condition once a failure happened", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		Expect((&sysctlFailures{}).setCondition(nic)).To(BeFalse())
+		Expect(nic.Status.GetCondition(vpcv1alpha1.NetworkInterfaceSysctlsApplied)).To(BeNil())
+	})
+})
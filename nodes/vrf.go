@@ -0,0 +1,62 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+)
+
+// syncVRF enslaves the link of a NetworkInterface to the VRF device of its PrivateNetwork, if any,
+// before its addresses are configured, since the kernel cycles the link when it joins or leaves a VRF
+func (r *NetworkInterfaceReconciler) syncVRF(nic *vpcv1alpha1.NetworkInterface, pnet *vpcv1alpha1.PrivateNetwork) error {
+	vrf := ""
+	if pnet.Spec.VRF != nil {
+		vrf = pnet.Spec.VRF.Name
+	}
+
+	if nic.Status.VRF != "" && nic.Status.VRF != vrf {
+		err := r.tearDownVRF(nic)
+		if err != nil {
+			return err
+		}
+	}
+	if vrf != "" {
+		err := r.NICs.SetLinkVRF(nic.Status.MacAddress, vrf, int(pnet.Spec.RouteTable))
+		if err != nil {
+			return err
+		}
+	}
+
+	nic.Status.VRF = vrf
+	return nil
+}
+
+// tearDownVRF removes the link of a NetworkInterface from its VRF device, if any, and deletes the VRF device
+// once no link is left in it, unless the TeardownPolicy of the NetworkInterface is FlushConfig
+func (r *NetworkInterfaceReconciler) tearDownVRF(nic *vpcv1alpha1.NetworkInterface) error {
+	if nic.Status.VRF == "" {
+		return nil
+	}
+	err := r.NICs.RemoveLinkVRF(nic.Status.MacAddress)
+	if err != nil {
+		return err
+	}
+	if nic.Spec.TeardownPolicy == vpcv1alpha1.TeardownPolicyFlushConfig {
+		return nil
+	}
+	return r.NICs.DeleteVRF(nic.Status.VRF)
+}
@@ -0,0 +1,120 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog"
+)
+
+// Watchdog detects the reconciles running for longer than a threshold, for instance stuck on a netlink call,
+// which would otherwise leave the NetworkInterfaces of the node silently unreconciled
+type Watchdog struct {
+	// Threshold is the duration after which a running reconcile is considered stalled
+	Threshold time.Duration
+	// Crash exits the process when a reconcile is stalled, so that it is restarted
+	Crash bool
+	Log   logr.Logger
+
+	mu sync.Mutex
+	// running holds the start time of the running reconciles, by NetworkInterface name
+	running map[string]time.Time
+	// reported holds the stalled reconciles already reported, by NetworkInterface name
+	reported map[string]bool
+
+	exit func(int)
+}
+
+// NewWatchdog returns a Watchdog with the given threshold
+func NewWatchdog(threshold time.Duration, crash bool, log logr.Logger) *Watchdog {
+	return &Watchdog{
+		Threshold: threshold,
+		Crash:     crash,
+		Log:       log,
+		running:   make(map[string]time.Time),
+		reported:  make(map[string]bool),
+		exit:      os.Exit,
+	}
+}
+
+// Begin records the start of the reconcile of a NetworkInterface, and returns the function recording its end
+func (w *Watchdog) Begin(name string) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running[name] = time.Now()
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.running, name)
+		delete(w.reported, name)
+	}
+}
+
+// stalled returns the reconciles running for longer than the threshold at the given time and not yet reported
+func (w *Watchdog) stalled(now time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stalled := []string{}
+	for name, start := range w.running {
+		if now.Sub(start) > w.Threshold && !w.reported[name] {
+			w.reported[name] = true
+			stalled = append(stalled, name)
+		}
+	}
+	sort.Strings(stalled)
+	return stalled
+}
+
+// check reports the stalled reconciles, and exits the process if Crash is set
+func (w *Watchdog) check(now time.Time) {
+	stalled := w.stalled(now)
+	for _, name := range stalled {
+		w.Log.Error(fmt.Errorf("reconcile running for more than %s", w.Threshold), "stalled reconcile, the NetworkInterfaces of the node are not reconciled anymore",
+			"networkinterface", name)
+		reconcileStallsTotal.Inc()
+	}
+	if len(stalled) != 0 && w.Crash {
+		w.Log.Info("exiting because of stalled reconciles")
+		klog.Flush()
+		w.exit(1)
+	}
+}
+
+// Start checks the running reconciles until stop is closed
+func (w *Watchdog) Start(stop <-chan struct{}) error {
+	interval := w.Threshold / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			w.check(now)
+		case <-stop:
+			return nil
+		}
+	}
+}
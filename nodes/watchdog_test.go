@@ -0,0 +1,55 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodes
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile watchdog", func() {
+	It("reports the stalled reconciles once", func() {
+		w := NewWatchdog(time.Minute, false, ctrl.Log)
+		done := w.Begin("nic-a")
+		w.Begin("nic-b")
+
+		now := time.Now()
+		Expect(w.stalled(now)).To(BeEmpty())
+		Expect(w.stalled(now.Add(2 * time.Minute))).To(Equal([]string{"nic-a", "nic-b"}))
+		Expect(w.stalled(now.Add(3 * time.Minute))).To(BeEmpty())
+
+		done()
+		w.Begin("nic-a")
+		Expect(w.stalled(now.Add(2 * time.Minute))).To(Equal([]string{"nic-a"}))
+	})
+
+	It("exits on stalled reconciles when crash is set", func() {
+		w := NewWatchdog(time.Minute, true, ctrl.Log)
+		code := -1
+		w.exit = func(c int) { code = c }
+
+		done := w.Begin("nic-a")
+		w.check(time.Now())
+		Expect(code).To(Equal(-1))
+		w.check(time.Now().Add(2 * time.Minute))
+		Expect(code).To(Equal(1))
+		done()
+	})
+})
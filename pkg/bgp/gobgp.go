@@ -0,0 +1,215 @@
+package bgp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+const (
+	// attrTypeNextHop is the NEXT_HOP path attribute
+	attrTypeNextHop = 3
+	// attrTypeMPReachNLRI is the MP_REACH_NLRI path attribute, holding the next hop of IPv6 paths
+	attrTypeMPReachNLRI = 14
+)
+
+// GoBGP polls the best paths of the global RIB of a local gobgp speaker with the gobgp CLI,
+// which uses the gobgp gRPC API
+type GoBGP struct {
+	// Host and Port are the address of the gobgp gRPC API
+	Host string
+	Port string
+	// Interval is the interval between two polls
+	Interval time.Duration
+	// HoldTime is how long the learned routes are kept when gobgp can't be reached, 0 to keep them forever
+	HoldTime time.Duration
+	Log      logr.Logger
+
+	events chan event.GenericEvent
+
+	mu     sync.RWMutex
+	routes []nics.Route
+	// lastPoll is the time of the last successful poll
+	lastPoll time.Time
+}
+
+// NewGoBGP returns a GoBGP polling the gobgp gRPC API at the given address, keeping the learned routes
+// for holdTime when it can't be reached
+func NewGoBGP(address string, interval, holdTime time.Duration, log logr.Logger) (*GoBGP, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	return &GoBGP{
+		Host:     host,
+		Port:     port,
+		Interval: interval,
+		HoldTime: holdTime,
+		Log:      log,
+		events:   make(chan event.GenericEvent),
+	}, nil
+}
+
+// Events returns a channel receiving an event each time the learned routes change
+func (g *GoBGP) Events() <-chan event.GenericEvent {
+	return g.events
+}
+
+// Routes returns the last learned routes
+// They are kept when gobgp can't be reached, like a graceful restart would, until the hold time expires
+func (g *GoBGP) Routes() []nics.Route {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]nics.Route{}, g.routes...)
+}
+
+// Start polls gobgp until stop is closed
+func (g *GoBGP) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+
+	for {
+		changed := false
+		routes, err := g.learnedRoutes()
+		if err != nil {
+			if g.expireRoutes(time.Now()) {
+				g.Log.Error(err, fmt.Sprintf("unable to get the routes learned by gobgp for %s, removing the previous ones", g.HoldTime))
+				changed = true
+			} else {
+				g.Log.Error(err, "unable to get the routes learned by gobgp, keeping the previous ones")
+			}
+		} else {
+			g.setLastPoll(time.Now())
+			if g.setRoutes(routes) {
+				g.Log.Info(fmt.Sprintf("gobgp learned routes changed, %d routes", len(routes)))
+				changed = true
+			}
+		}
+		if changed {
+			select {
+			case g.events <- event.GenericEvent{
+				Meta:   &metav1.ObjectMeta{Name: "gobgp"},
+				Object: &metav1.PartialObjectMetadata{},
+			}:
+			case <-stop:
+				return nil
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+func (g *GoBGP) setLastPoll(now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lastPoll = now
+}
+
+// expireRoutes removes the learned routes once the hold time elapsed since the last successful poll,
+// like the hold timer of a BGP session, returning whether there were any
+func (g *GoBGP) expireRoutes(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.HoldTime == 0 || len(g.routes) == 0 || now.Sub(g.lastPoll) < g.HoldTime {
+		return false
+	}
+	g.routes = nil
+	return true
+}
+
+func (g *GoBGP) setRoutes(routes []nics.Route) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if reflect.DeepEqual(g.routes, routes) {
+		return false
+	}
+	g.routes = routes
+	return true
+}
+
+func (g *GoBGP) learnedRoutes() ([]nics.Route, error) {
+	routes := []nics.Route{}
+	for _, family := range []string{"ipv4", "ipv6"} {
+		out, err := exec.Command("gobgp", "-u", g.Host, "-p", g.Port, "global", "rib", "-a", family, "-j").Output()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the %s rib: %w", family, err)
+		}
+		familyRoutes, err := parseRIB(out)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the %s rib: %w", family, err)
+		}
+		routes = append(routes, familyRoutes...)
+	}
+	return routes, nil
+}
+
+type ribPath struct {
+	Best  bool `json:"best"`
+	Attrs []struct {
+		Type    int    `json:"type"`
+		NextHop string `json:"nexthop"`
+	} `json:"attrs"`
+}
+
+// parseRIB parses the best paths of the json output of `gobgp global rib` into routes
+// tagged with the BGP protocol
+func parseRIB(data []byte) ([]nics.Route, error) {
+	rib := map[string][]ribPath{}
+	err := json.Unmarshal(data, &rib)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []nics.Route{}
+	for prefix, paths := range rib {
+		_, dst, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			if !path.Best {
+				continue
+			}
+			var nextHop net.IP
+			for _, attr := range path.Attrs {
+				if attr.Type == attrTypeNextHop || attr.Type == attrTypeMPReachNLRI {
+					nextHop = net.ParseIP(attr.NextHop)
+				}
+			}
+			// paths originated by the speaker itself have an unspecified next hop
+			if nextHop == nil || nextHop.IsUnspecified() {
+				continue
+			}
+			if nextHop.To4() != nil {
+				nextHop = nextHop.To4()
+			}
+			routes = append(routes, nics.Route{
+				To:       dst,
+				Via:      nextHop,
+				Protocol: nics.BGPRouteProtocol,
+			})
+			break
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].To.String() < routes[j].To.String()
+	})
+	return routes, nil
+}
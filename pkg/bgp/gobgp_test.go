@@ -0,0 +1,80 @@
+package bgp
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+var _ = Describe("gobgp rib parsing", func() {
+	It("parses the best paths", func() {
+		routes, err := parseRIB([]byte(`{
+			"10.1.0.0/16": [
+				{"nlri": {"prefix": "10.1.0.0/16"}, "best": false, "attrs": [{"type": 1, "value": 0}, {"type": 3, "nexthop": "192.168.0.3"}]},
+				{"nlri": {"prefix": "10.1.0.0/16"}, "best": true, "attrs": [{"type": 1, "value": 0}, {"type": 3, "nexthop": "192.168.0.2"}]}
+			],
+			"10.0.0.0/24": [
+				{"nlri": {"prefix": "10.0.0.0/24"}, "best": true, "attrs": [{"type": 3, "nexthop": "192.168.0.4"}]}
+			],
+			"fd00:1::/64": [
+				{"nlri": {"prefix": "fd00:1::/64"}, "best": true, "attrs": [{"type": 14, "nexthop": "fd00::2"}]}
+			]
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(HaveLen(3))
+
+		Expect(routes[0].To.String()).To(Equal("10.0.0.0/24"))
+		Expect(routes[0].Via.String()).To(Equal("192.168.0.4"))
+		Expect(routes[1].To.String()).To(Equal("10.1.0.0/16"))
+		Expect(routes[1].Via.String()).To(Equal("192.168.0.2"))
+		Expect(routes[2].To.String()).To(Equal("fd00:1::/64"))
+		Expect(routes[2].Via.String()).To(Equal("fd00::2"))
+		for _, route := range routes {
+			Expect(route.Protocol).To(Equal(nics.BGPRouteProtocol))
+		}
+	})
+
+	It("ignores the locally originated paths", func() {
+		routes, err := parseRIB([]byte(`{"10.0.0.0/24": [{"best": true, "attrs": [{"type": 3, "nexthop": "0.0.0.0"}]}]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(BeEmpty())
+	})
+
+	It("parses an empty rib", func() {
+		routes, err := parseRIB([]byte(`{}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(BeEmpty())
+	})
+})
+
+var _ = Describe("gobgp learned routes hold time", func() {
+	_, dst, _ := net.ParseCIDR("10.1.0.0/16")
+	routes := []nics.Route{{To: dst, Via: net.ParseIP("192.168.0.2").To4(), Protocol: nics.BGPRouteProtocol}}
+	polled := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	It("keeps the routes until the hold time elapsed since the last successful poll", func() {
+		g := &GoBGP{HoldTime: time.Second * 90}
+		g.setLastPoll(polled)
+		Expect(g.setRoutes(routes)).To(BeTrue())
+
+		Expect(g.expireRoutes(polled.Add(time.Second * 30))).To(BeFalse())
+		Expect(g.Routes()).To(HaveLen(1))
+
+		Expect(g.expireRoutes(polled.Add(time.Second * 90))).To(BeTrue())
+		Expect(g.Routes()).To(BeEmpty())
+		Expect(g.expireRoutes(polled.Add(time.Second * 120))).To(BeFalse())
+	})
+
+	It("keeps the routes forever without hold time", func() {
+		g := &GoBGP{}
+		g.setLastPoll(polled)
+		Expect(g.setRoutes(routes)).To(BeTrue())
+
+		Expect(g.expireRoutes(polled.Add(time.Hour))).To(BeFalse())
+		Expect(g.Routes()).To(HaveLen(1))
+	})
+})
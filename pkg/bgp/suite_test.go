@@ -0,0 +1,13 @@
+package bgp
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestBGP(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "BGP Suite")
+}
@@ -0,0 +1,62 @@
+package nics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// procStatusPath is the status file of the process, holding its effective capabilities
+const procStatusPath = "/proc/self/status"
+
+// CheckAccess verifies that the node daemon can manage the links of the node: that it can list them
+// on its netlink socket, that it sees more than the loopback, as in the host network namespace,
+// and that it has the CAP_NET_ADMIN capability
+func (n *NICs) CheckAccess() error {
+	links, err := n.Handle.LinkList()
+	if err != nil {
+		return fmt.Errorf("unable to list the links on the netlink socket: %w", err)
+	}
+	if len(links) <= 1 {
+		return fmt.Errorf("only %d link found, the node daemon needs to run in the host network namespace", len(links))
+	}
+
+	f, err := os.Open(procStatusPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	netAdmin, err := hasEffectiveCapability(f, unix.CAP_NET_ADMIN)
+	if err != nil {
+		return err
+	}
+	if !netAdmin {
+		return fmt.Errorf("missing the CAP_NET_ADMIN capability, needed to configure the links")
+	}
+	return nil
+}
+
+// hasEffectiveCapability returns whether the CapEff line of the given process status holds the capability
+func hasEffectiveCapability(status io.Reader, capability uint) (bool, error) {
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "CapEff:" {
+			continue
+		}
+		caps, err := strconv.ParseUint(fields[1], 16, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid effective capabilities %s: %w", fields[1], err)
+		}
+		return caps&(1<<capability) != 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	return false, fmt.Errorf("no effective capabilities in the process status")
+}
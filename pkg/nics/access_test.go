@@ -0,0 +1,29 @@
+package nics
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("Access check", func() {
+	It("reads the effective capabilities of the process", func() {
+		status := "Name:\tnode\nCapInh:\t0000000000000000\nCapPrm:\t0000003fffffffff\nCapEff:\t0000000000001000\n"
+		netAdmin, err := hasEffectiveCapability(strings.NewReader(status), unix.CAP_NET_ADMIN)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(netAdmin).To(BeTrue())
+
+		netAdmin, err = hasEffectiveCapability(strings.NewReader("CapEff:\t00000000a80425fb\n"), unix.CAP_NET_ADMIN)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(netAdmin).To(BeFalse())
+	})
+
+	It("rejects a status without effective capabilities", func() {
+		_, err := hasEffectiveCapability(strings.NewReader("Name:\tnode\n"), unix.CAP_NET_ADMIN)
+		Expect(err).To(HaveOccurred())
+		_, err = hasEffectiveCapability(strings.NewReader("CapEff:\tnothex\n"), unix.CAP_NET_ADMIN)
+		Expect(err).To(HaveOccurred())
+	})
+})
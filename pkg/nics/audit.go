@@ -0,0 +1,193 @@
+package nics
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/vishvananda/netlink"
+)
+
+// audit records a mutating netlink operation on the audit log, if enabled
+// before and after are the values changed by the operation, empty when the value is created or removed
+func (n *NICs) audit(operation string, linkName string, before, after string, err error) {
+	if n.AuditLog == nil {
+		return
+	}
+	keysAndValues := []interface{}{"operation", operation, "link", linkName, "before", before, "after", after}
+	if err != nil {
+		n.AuditLog.Error(err, "host networking change failed", keysAndValues...)
+		return
+	}
+	n.AuditLog.Info("host networking change", keysAndValues...)
+}
+
+func (n *NICs) addrAdd(link netlink.Link, addr *netlink.Addr) error {
+	err := n.Handle.AddrAdd(link, addr)
+	n.audit("addr add", link.Attrs().Name, "", addr.IPNet.String(), err)
+	return err
+}
+
+func (n *NICs) addrReplace(link netlink.Link, addr *netlink.Addr) error {
+	err := n.Handle.AddrReplace(link, addr)
+	n.audit("addr replace", link.Attrs().Name, "", addr.IPNet.String(), err)
+	return err
+}
+
+func (n *NICs) addrDel(link netlink.Link, addr *netlink.Addr) error {
+	err := n.Handle.AddrDel(link, addr)
+	n.audit("addr del", link.Attrs().Name, addr.IPNet.String(), "", err)
+	return err
+}
+
+func (n *NICs) routeAdd(link netlink.Link, route *netlink.Route) error {
+	err := n.retry(func() error {
+		return n.Handle.RouteAdd(route)
+	})
+	n.audit("route add", link.Attrs().Name, "", route.String(), err)
+	return err
+}
+
+func (n *NICs) routeReplace(link netlink.Link, route *netlink.Route) error {
+	err := n.retry(func() error {
+		return n.Handle.RouteReplace(route)
+	})
+	n.audit("route replace", link.Attrs().Name, "", route.String(), err)
+	return err
+}
+
+func (n *NICs) routeDel(link netlink.Link, route *netlink.Route) error {
+	err := n.retry(func() error {
+		return n.Handle.RouteDel(route)
+	})
+	n.audit("route del", link.Attrs().Name, route.String(), "", err)
+	return err
+}
+
+func (n *NICs) ruleAdd(rule *netlink.Rule) error {
+	err := n.retry(func() error {
+		return n.Handle.RuleAdd(rule)
+	})
+	n.audit("rule add", "", "", rule.String(), err)
+	return err
+}
+
+func (n *NICs) ruleDel(rule *netlink.Rule) error {
+	err := n.retry(func() error {
+		return n.Handle.RuleDel(rule)
+	})
+	n.audit("rule del", "", rule.String(), "", err)
+	return err
+}
+
+func (n *NICs) qdiscAdd(link netlink.Link, qdisc netlink.Qdisc) error {
+	err := n.Handle.QdiscAdd(qdisc)
+	n.audit("qdisc add", link.Attrs().Name, "", qdisc.Type(), err)
+	return err
+}
+
+func (n *NICs) qdiscDel(link netlink.Link, qdisc netlink.Qdisc) error {
+	err := n.Handle.QdiscDel(qdisc)
+	n.audit("qdisc del", link.Attrs().Name, qdisc.Type(), "", err)
+	return err
+}
+
+func (n *NICs) filterDel(link netlink.Link, filter netlink.Filter) error {
+	err := n.Handle.FilterDel(filter)
+	n.audit("filter del", link.Attrs().Name, filter.Type(), "", err)
+	return err
+}
+
+// linkSetUp brings the link up, only auditing the change when it was down
+func (n *NICs) linkSetUp(link netlink.Link) error {
+	before := n.auditLinkState(link)
+	err := n.Handle.LinkSetUp(link)
+	if before != "up" {
+		n.audit("link set up", link.Attrs().Name, before, "up", err)
+	}
+	return err
+}
+
+// linkSetDown brings the link down, only auditing the change when it was up
+func (n *NICs) linkSetDown(link netlink.Link) error {
+	before := n.auditLinkState(link)
+	err := n.Handle.LinkSetDown(link)
+	if before != "down" {
+		n.audit("link set down", link.Attrs().Name, before, "down", err)
+	}
+	return err
+}
+
+func (n *NICs) linkSetPromisc(link netlink.Link, promisc bool) error {
+	var err error
+	after := "off"
+	if promisc {
+		err = n.Handle.SetPromiscOn(link)
+		after = "on"
+	} else {
+		err = n.Handle.SetPromiscOff(link)
+	}
+	before := "off"
+	if link.Attrs().Promisc != 0 {
+		before = "on"
+	}
+	n.audit("link set promisc", link.Attrs().Name, before, after, err)
+	return err
+}
+
+func (n *NICs) linkSetHardwareAddr(link netlink.Link, hwAddr net.HardwareAddr) error {
+	err := n.Handle.LinkSetHardwareAddr(link, hwAddr)
+	n.audit("link set address", link.Attrs().Name, link.Attrs().HardwareAddr.String(), hwAddr.String(), err)
+	return err
+}
+
+func (n *NICs) linkSetMTU(link netlink.Link, mtu int) error {
+	err := n.Handle.LinkSetMTU(link, mtu)
+	n.audit("link set mtu", link.Attrs().Name, strconv.Itoa(link.Attrs().MTU), strconv.Itoa(mtu), err)
+	return err
+}
+
+func (n *NICs) linkSetAlias(link netlink.Link, alias string) error {
+	err := n.Handle.LinkSetAlias(link, alias)
+	n.audit("link set alias", link.Attrs().Name, link.Attrs().Alias, alias, err)
+	return err
+}
+
+func (n *NICs) linkAdd(link netlink.Link) error {
+	err := n.Handle.LinkAdd(link)
+	n.audit("link add", link.Attrs().Name, "", link.Type(), err)
+	return err
+}
+
+func (n *NICs) linkDel(link netlink.Link) error {
+	err := n.Handle.LinkDel(link)
+	n.audit("link del", link.Attrs().Name, link.Type(), "", err)
+	return err
+}
+
+func (n *NICs) linkSetMaster(link netlink.Link, master netlink.Link) error {
+	err := n.Handle.LinkSetMasterByIndex(link, master.Attrs().Index)
+	n.audit("link set master", link.Attrs().Name, "", master.Attrs().Name, err)
+	return err
+}
+
+func (n *NICs) linkSetNoMaster(link netlink.Link, master netlink.Link) error {
+	err := n.Handle.LinkSetNoMaster(link)
+	n.audit("link set nomaster", link.Attrs().Name, master.Attrs().Name, "", err)
+	return err
+}
+
+// auditLinkState returns the administrative state of the link for the audit log, read only when it is enabled
+// since the cached link attributes may be outdated
+func (n *NICs) auditLinkState(link netlink.Link) string {
+	if n.AuditLog == nil {
+		return ""
+	}
+	current, err := n.Handle.LinkByIndex(link.Attrs().Index)
+	if err != nil {
+		return "unknown"
+	}
+	if current.Attrs().Flags&net.FlagUp != 0 {
+		return "up"
+	}
+	return "down"
+}
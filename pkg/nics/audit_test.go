@@ -0,0 +1,49 @@
+package nics
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingLogger records the messages and values of its entries
+type recordingLogger struct {
+	entries *[]string
+}
+
+func (l recordingLogger) Enabled() bool { return true }
+
+func (l recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	*l.entries = append(*l.entries, fmt.Sprint(append([]interface{}{msg}, keysAndValues...)...))
+}
+
+func (l recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.Info(msg, append(keysAndValues, "error", err.Error())...)
+}
+
+func (l recordingLogger) V(level int) logr.InfoLogger { return l }
+
+func (l recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger { return l }
+
+func (l recordingLogger) WithName(name string) logr.Logger { return l }
+
+var _ = Describe("Audit log", func() {
+	It("does nothing when disabled", func() {
+		nics := &NICs{}
+		nics.audit("addr add", "pn0", "", "192.168.0.10/24", nil)
+	})
+
+	It("records the operations with their values", func() {
+		entries := []string{}
+		nics := &NICs{AuditLog: recordingLogger{entries: &entries}}
+		nics.audit("addr add", "pn0", "", "192.168.0.10/24", nil)
+		nics.audit("addr del", "pn0", "192.168.0.10/24", "", fmt.Errorf("no such address"))
+
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0]).To(Equal(fmt.Sprint("host networking change", "operation", "addr add", "link", "pn0", "before", "", "after", "192.168.0.10/24")))
+		Expect(entries[1]).To(ContainSubstring("host networking change failed"))
+		Expect(entries[1]).To(ContainSubstring("no such address"))
+	})
+})
@@ -0,0 +1,108 @@
+package nics
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// DHCPLease is the DHCP lease obtained by dhcpcd on a link
+type DHCPLease struct {
+	// Address is the leased address, in CIDR notation
+	Address string
+	// Gateway is the first router of the lease, if any
+	Gateway net.IP
+	// DNS are the name servers of the lease
+	DNS []net.IP
+	// MTU is the interface MTU of the lease, 0 if none
+	MTU int
+	// Routes are the classless static routes of the lease
+	Routes []Route
+}
+
+// GetDHCPLease returns the current DHCP lease of the link with the given mac, nil when it has none
+func (n *NICs) GetDHCPLease(mac string) (*DHCPLease, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("dhcpcd", "-4", "-U", link.Attrs().Name).Output()
+	if err != nil {
+		// dhcpcd fails to dump the lease of a link without one
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseDHCPLease(out)
+}
+
+// parseDHCPLease parses a lease as dumped by dhcpcd -U, one shell variable per line
+func parseDHCPLease(dump []byte) (*DHCPLease, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values[parts[0]] = strings.Trim(parts[1], `'"`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if values["ip_address"] == "" {
+		return nil, nil
+	}
+	lease := &DHCPLease{
+		Address: values["ip_address"],
+	}
+	if values["subnet_cidr"] != "" {
+		lease.Address += "/" + values["subnet_cidr"]
+	}
+	if _, err := netlink.ParseIPNet(lease.Address); err != nil {
+		return nil, fmt.Errorf("invalid lease address %s: %w", lease.Address, err)
+	}
+	if routers := strings.Fields(values["routers"]); len(routers) != 0 {
+		lease.Gateway = net.ParseIP(routers[0])
+		if lease.Gateway == nil {
+			return nil, fmt.Errorf("invalid lease router %s", routers[0])
+		}
+	}
+	for _, server := range strings.Fields(values["domain_name_servers"]) {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid lease name server %s", server)
+		}
+		lease.DNS = append(lease.DNS, ip)
+	}
+	if values["interface_mtu"] != "" {
+		mtu, err := strconv.Atoi(values["interface_mtu"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid lease mtu %s: %w", values["interface_mtu"], err)
+		}
+		lease.MTU = mtu
+	}
+	// the classless static routes are listed as destination and gateway pairs
+	fields := strings.Fields(values["classless_static_routes"])
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("invalid lease classless static routes %s", values["classless_static_routes"])
+	}
+	for i := 0; i < len(fields); i += 2 {
+		route, err := ParseRoute(fields[i], fields[i+1])
+		if err != nil {
+			return nil, err
+		}
+		lease.Routes = append(lease.Routes, route)
+	}
+	return lease, nil
+}
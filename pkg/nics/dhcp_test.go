@@ -0,0 +1,40 @@
+package nics
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DHCP lease parsing", func() {
+	It("parses the lease dumped by dhcpcd", func() {
+		lease, err := parseDHCPLease([]byte(`broadcast_address='172.16.0.255'
+dhcp_lease_time='3600'
+domain_name_servers='172.16.0.2 172.16.0.3'
+interface_mtu='1450'
+ip_address='172.16.0.10'
+routers='172.16.0.1'
+classless_static_routes='10.0.0.0/16 172.16.0.1 0.0.0.0/0 172.16.0.1'
+subnet_cidr='24'
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lease.Address).To(Equal("172.16.0.10/24"))
+		Expect(lease.Gateway).To(Equal(net.ParseIP("172.16.0.1")))
+		Expect(lease.DNS).To(Equal([]net.IP{net.ParseIP("172.16.0.2"), net.ParseIP("172.16.0.3")}))
+		Expect(lease.MTU).To(Equal(1450))
+		Expect(lease.Routes).To(HaveLen(2))
+		Expect(lease.Routes[0].To.String()).To(Equal("10.0.0.0/16"))
+	})
+
+	It("returns no lease without address", func() {
+		lease, err := parseDHCPLease([]byte("reason='NOCARRIER'\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lease).To(BeNil())
+	})
+
+	It("rejects invalid leases", func() {
+		_, err := parseDHCPLease([]byte("ip_address='172.16.0.10'\nsubnet_cidr='24'\ninterface_mtu='large'\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,64 @@
+package nics
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultENOBUFSRetries is the default number of retries of a netlink operation failing with ENOBUFS
+	DefaultENOBUFSRetries = 3
+	// DefaultMaxReceiveBufferSize is the default size up to which the netlink socket receive buffer is grown
+	DefaultMaxReceiveBufferSize = 8 << 20
+)
+
+// retryENOBUFS runs op, and runs it again up to retries times while it fails because the receive buffer
+// of the netlink socket overflowed, calling grow before each retry
+func retryENOBUFS(retries int, grow func(), op func() error) error {
+	err := op()
+	for i := 0; i < retries && errors.Is(err, unix.ENOBUFS); i++ {
+		grow()
+		err = op()
+	}
+	return err
+}
+
+// retry runs a netlink operation, retrying it when the receive buffer of the netlink socket overflows
+func (n *NICs) retry(op func() error) error {
+	return retryENOBUFS(n.ENOBUFSRetries, n.growReceiveBuffer, op)
+}
+
+// growReceiveBuffer doubles the receive buffer of the netlink socket, up to MaxReceiveBufferSize
+// It is best effort, the operation being retried anyway
+func (n *NICs) growReceiveBuffer() {
+	if n.receiveBufferSize == 0 {
+		sizes, err := n.Handle.GetSocketReceiveBufferSize()
+		if err != nil || len(sizes) == 0 {
+			return
+		}
+		n.receiveBufferSize = sizes[0]
+	}
+
+	size := n.receiveBufferSize * 2
+	if size > n.MaxReceiveBufferSize {
+		size = n.MaxReceiveBufferSize
+	}
+	if size <= n.receiveBufferSize {
+		return
+	}
+	// forcing the size allows to exceed net.core.rmem_max, with CAP_NET_ADMIN
+	if n.Handle.SetSocketReceiveBufferSize(size, true) == nil {
+		n.receiveBufferSize = size
+	}
+}
+
+// SetReceiveBufferSize sets the receive buffer size of the netlink socket
+func (n *NICs) SetReceiveBufferSize(size int) error {
+	err := n.Handle.SetSocketReceiveBufferSize(size, true)
+	if err != nil {
+		return err
+	}
+	n.receiveBufferSize = size
+	return nil
+}
@@ -0,0 +1,44 @@
+package nics
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("ENOBUFS retries", func() {
+	failing := func(failures int, err error) (func() error, *int) {
+		calls := 0
+		return func() error {
+			calls++
+			if calls <= failures {
+				return err
+			}
+			return nil
+		}, &calls
+	}
+
+	It("retries the operations failing with ENOBUFS", func() {
+		grown := 0
+		op, calls := failing(2, unix.ENOBUFS)
+		Expect(retryENOBUFS(3, func() { grown++ }, op)).To(Succeed())
+		Expect(*calls).To(Equal(3))
+		Expect(grown).To(Equal(2))
+	})
+
+	It("gives up after the given number of retries", func() {
+		grown := 0
+		op, calls := failing(10, fmt.Errorf("route list: %w", unix.ENOBUFS))
+		Expect(retryENOBUFS(3, func() { grown++ }, op)).To(MatchError(unix.ENOBUFS))
+		Expect(*calls).To(Equal(4))
+		Expect(grown).To(Equal(3))
+	})
+
+	It("does not retry the other errors", func() {
+		op, calls := failing(10, unix.EEXIST)
+		Expect(retryENOBUFS(3, func() {}, op)).To(MatchError(unix.EEXIST))
+		Expect(*calls).To(Equal(1))
+	})
+})
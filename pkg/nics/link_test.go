@@ -0,0 +1,535 @@
+package nics
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("Link configuration", func() {
+	const (
+		mac     = "02:00:00:00:00:01"
+		address = "192.168.0.10/24"
+	)
+
+	var (
+		testNS *testNamespace
+		handle *netlink.Handle
+		nics   *NICs
+		link   netlink.Link
+	)
+
+	BeforeEach(func() {
+		testNS = newTestNamespace()
+		handle = testNS.handle
+		nics = testNS.nics()
+		link = testNS.addDummy("pn0", mac)
+	})
+
+	AfterEach(func() {
+		testNS.close()
+		testNS = nil
+	})
+
+	It("removes the routes before the addresses", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		route, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nics.SyncRoutes(mac, 0, []Route{route})).To(Succeed())
+
+		routes, err := handle.RouteList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(HaveLen(2))
+
+		Expect(nics.TearDownStaticLink(mac, address)).To(Succeed())
+
+		routes, err = handle.RouteList(link, netlink.FAMILY_ALL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(BeEmpty())
+		addrs, err := handle.AddrList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(addrs).To(BeEmpty())
+		link, err = handle.LinkByIndex(link.Attrs().Index)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.Attrs().Flags & net.FlagUp).To(BeZero())
+	})
+
+	It("leaves the link up when flushing it", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		route, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nics.SyncRoutes(mac, 0, []Route{route})).To(Succeed())
+
+		Expect(nics.FlushStaticLink(mac, address)).To(Succeed())
+
+		routes, err := handle.RouteList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(BeEmpty())
+		addrs, err := handle.AddrList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(addrs).To(BeEmpty())
+		link, err = handle.LinkByIndex(link.Attrs().Index)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.Attrs().Flags & net.FlagUp).NotTo(BeZero())
+	})
+
+	It("leaves the routes it did not install untouched", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		_, staticDst, err := net.ParseCIDR("10.1.0.0/16")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handle.RouteAdd(&netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       staticDst,
+			Gw:        net.IPv4(192, 168, 0, 2),
+			Protocol:  unix.RTPROT_STATIC,
+		})).To(Succeed())
+
+		route, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nics.SyncRoutes(mac, 0, []Route{route})).To(Succeed())
+		Expect(nics.SyncRoutes(mac, 0, nil)).To(Succeed())
+
+		routes, err := handle.RouteList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		dsts := []string{}
+		for _, r := range routes {
+			dsts = append(dsts, r.Dst.String())
+		}
+		Expect(dsts).To(ConsistOf("192.168.0.0/24", "10.1.0.0/16"))
+	})
+
+	It("applies the conflict policy to the routes of other agents", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		_, dst, err := net.ParseCIDR("10.0.0.0/16")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handle.RouteAdd(&netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       dst,
+			Gw:        net.IPv4(192, 168, 0, 2),
+			Protocol:  unix.RTPROT_STATIC,
+		})).To(Succeed())
+		gateways := func() []string {
+			routes, err := handle.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Dst: dst}, netlink.RT_FILTER_DST)
+			Expect(err).NotTo(HaveOccurred())
+			gws := []string{}
+			for _, r := range routes {
+				gws = append(gws, r.Gw.String())
+			}
+			return gws
+		}
+
+		route, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		route.OnConflict = RouteConflictSkip
+		results, err := nics.ApplyRoutes(mac, 0, []Route{route})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Skipped).To(BeTrue())
+		Expect(gateways()).To(ConsistOf("192.168.0.2"))
+
+		route.OnConflict = RouteConflictFail
+		results, err = nics.ApplyRoutes(mac, 0, []Route{route})
+		Expect(IsRouteConflict(err)).To(BeTrue())
+		Expect(IsRouteConflict(results[0].Err)).To(BeTrue())
+		Expect(gateways()).To(ConsistOf("192.168.0.2"))
+
+		route.OnConflict = RouteConflictReplace
+		results, err = nics.ApplyRoutes(mac, 0, []Route{route})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Applied).To(BeTrue())
+		Expect(gateways()).To(ConsistOf("192.168.0.1"))
+	})
+
+	It("reports the routes it installed", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		route, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+
+		results, err := nics.ApplyRoutes(mac, 0, []Route{route})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Applied).To(BeTrue())
+
+		results, err = nics.ApplyRoutes(mac, 0, []Route{route})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Applied).To(BeFalse())
+	})
+
+	It("aborts the configuration when the link address changed", func() {
+		resolved, err := nics.getLink(mac)
+		Expect(err).NotTo(HaveOccurred())
+		hwAddr, err := net.ParseMAC("02:00:00:00:00:02")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handle.LinkSetHardwareAddr(link, hwAddr)).To(Succeed())
+
+		err = nics.verifyLink(mac, resolved)
+		Expect(IsLinkChanged(err)).To(BeTrue())
+		Expect(nics.Links).NotTo(HaveKey(mac))
+
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).NotTo(Succeed())
+		addrs, err := handle.AddrList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(addrs).To(BeEmpty())
+	})
+
+	It("installs the connected routes in another table", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		routes, err := nics.ConnectedRoutes(mac)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(HaveLen(1))
+		Expect(routes[0].To.String()).To(Equal("192.168.0.0/24"))
+		Expect(routes[0].Src.String()).To(Equal("192.168.0.10"))
+
+		Expect(nics.SyncRoutes(mac, 100, routes)).To(Succeed())
+		tableRoutes, err := handle.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Table:     100,
+		}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tableRoutes).To(HaveLen(1))
+		Expect(tableRoutes[0].Dst.String()).To(Equal("192.168.0.0/24"))
+		Expect(tableRoutes[0].Gw).To(BeNil())
+		Expect(tableRoutes[0].Scope).To(Equal(netlink.SCOPE_LINK))
+
+		results, err := nics.ApplyRoutes(mac, 100, routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Applied).To(BeFalse())
+		Expect(nics.SyncRoutes(mac, 100, nil)).To(Succeed())
+	})
+
+	It("moves the routes to another table", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		route, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		tableDsts := func(table int) []string {
+			routes, err := handle.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+				LinkIndex: link.Attrs().Index,
+				Table:     table,
+			}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+			Expect(err).NotTo(HaveOccurred())
+			dsts := []string{}
+			for _, r := range routes {
+				dsts = append(dsts, r.Dst.String())
+			}
+			return dsts
+		}
+
+		Expect(nics.SyncRoutes(mac, 0, []Route{route})).To(Succeed())
+		Expect(nics.SyncRoutes(mac, 0, nil)).To(Succeed())
+		Expect(nics.SyncRoutes(mac, 100, []Route{route})).To(Succeed())
+		Expect(tableDsts(unix.RT_TABLE_MAIN)).To(ConsistOf("192.168.0.0/24"))
+		Expect(tableDsts(100)).To(ConsistOf("10.0.0.0/16"))
+
+		Expect(nics.SyncRoutes(mac, 100, nil)).To(Succeed())
+		Expect(nics.SyncRoutes(mac, 101, []Route{route})).To(Succeed())
+		Expect(tableDsts(100)).To(BeEmpty())
+		Expect(tableDsts(101)).To(ConsistOf("10.0.0.0/16"))
+	})
+
+	It("adds the addresses without prefix route", func() {
+		mainRoutes := func() []string {
+			routes, err := handle.RouteList(link, netlink.FAMILY_V4)
+			Expect(err).NotTo(HaveOccurred())
+			dsts := []string{}
+			for _, r := range routes {
+				dsts = append(dsts, r.Dst.String())
+			}
+			return dsts
+		}
+		noPrefixRoute := func() bool {
+			addrs, err := handle.AddrList(link, netlink.FAMILY_V4)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(addrs).To(HaveLen(1))
+			return addrs[0].Flags&unix.IFA_F_NOPREFIXROUTE != 0
+		}
+
+		Expect(nics.ConfigureStaticLinkNoPrefixRoute(mac, nil, address)).To(Succeed())
+		Expect(noPrefixRoute()).To(BeTrue())
+		Expect(mainRoutes()).To(BeEmpty())
+
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		Expect(noPrefixRoute()).To(BeFalse())
+		Expect(mainRoutes()).To(ConsistOf("192.168.0.0/24"))
+
+		Expect(nics.ConfigureStaticLinkNoPrefixRoute(mac, nil, address)).To(Succeed())
+		Expect(nics.TearDownStaticLink(mac, address)).To(Succeed())
+		addrs, err := handle.AddrList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(addrs).To(BeEmpty())
+	})
+
+	It("only removes the replaced addresses", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		vip, err := netlink.ParseIPNet("192.168.0.100/32")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handle.AddrAdd(link, &netlink.Addr{IPNet: vip})).To(Succeed())
+
+		Expect(nics.ConfigureStaticLink(mac, []string{address}, address)).To(Succeed())
+		Expect(nics.ConfigureStaticLink(mac, []string{address}, "192.168.0.20/24")).To(Succeed())
+
+		addrs, err := handle.AddrList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		ips := []string{}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IPNet.String())
+		}
+		Expect(ips).To(ConsistOf("192.168.0.20/24", "192.168.0.100/32"))
+	})
+
+	It("tears down a link without routes", func() {
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		Expect(nics.TearDownStaticLink(mac, address)).To(Succeed())
+		Expect(nics.TearDownStaticLink(mac, address)).To(Succeed())
+	})
+
+	It("waits for the link to be up", func() {
+		up, carrier, err := nics.WaitLinkUp(mac, time.Millisecond*200)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(up).To(BeFalse())
+		Expect(carrier).To(BeFalse())
+
+		Expect(nics.SetLinkUp(mac)).To(Succeed())
+		up, carrier, err = nics.WaitLinkUp(mac, time.Millisecond*200)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(up).To(BeTrue())
+		Expect(carrier).To(BeTrue())
+	})
+	It("reports the administrative state of the link", func() {
+		up, err := nics.IsLinkAdminUp(mac)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(up).To(BeFalse())
+
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		up, err = nics.IsLinkAdminUp(mac)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(up).To(BeTrue())
+
+		_, err = nics.IsLinkAdminUp("02:00:00:00:00:09")
+		Expect(IsNotFound(err)).To(BeTrue())
+	})
+
+	It("updates an address in place when only its attributes change", func() {
+		Expect(handle.LinkSetUp(link)).To(Succeed())
+		ipnet, err := netlink.ParseIPNet(address)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handle.AddrAdd(link, &netlink.Addr{IPNet: ipnet, ValidLft: 300, PreferedLft: 300})).To(Succeed())
+		// the kernel removes the routes through the address if it is deleted, even briefly
+		_, staticDst, err := net.ParseCIDR("10.1.0.0/16")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handle.RouteAdd(&netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       staticDst,
+			Gw:        net.IPv4(192, 168, 0, 2),
+			Protocol:  unix.RTPROT_STATIC,
+		})).To(Succeed())
+
+		nics.ReplaceAddresses = true
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+
+		addrs, err := handle.AddrList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(addrs).To(HaveLen(1))
+		Expect(addrs[0].Flags & unix.IFA_F_PERMANENT).NotTo(BeZero())
+		routes, err := handle.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Dst: staticDst}, netlink.RT_FILTER_DST)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(HaveLen(1))
+	})
+
+	It("finds the link regardless of the format of the mac address", func() {
+		name, err := nics.GetLinkName("02-00-00-00-00-01")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("pn0"))
+	})
+
+	It("sets and clears the promiscuous mode", func() {
+		promisc := func() bool {
+			current, err := handle.LinkByIndex(link.Attrs().Index)
+			Expect(err).NotTo(HaveOccurred())
+			return current.Attrs().Promisc != 0
+		}
+
+		Expect(nics.SetPromiscuous(mac, true)).To(Succeed())
+		Expect(promisc()).To(BeTrue())
+		Expect(nics.SetPromiscuous(mac, true)).To(Succeed())
+		Expect(promisc()).To(BeTrue())
+
+		Expect(nics.SetPromiscuous(mac, false)).To(Succeed())
+		Expect(promisc()).To(BeFalse())
+
+		Expect(nics.SetPromiscuous("02:00:00:00:00:09", false)).To(Succeed())
+		Expect(IsNotFound(nics.SetPromiscuous("02:00:00:00:00:09", true))).To(BeTrue())
+	})
+
+	It("sets back a reset MTU", func() {
+		Expect(nics.SetLinkMTU(mac, 1400)).To(Succeed())
+		Expect(nics.GetLinkMTU(mac)).To(Equal(1400))
+
+		// the link comes back with its default MTU after a reboot
+		Expect(handle.LinkSetMTU(link, 1500)).To(Succeed())
+		Expect(nics.GetLinkMTU(mac)).To(Equal(1500))
+
+		Expect(nics.SetLinkMTU(mac, 1400)).To(Succeed())
+		Expect(nics.GetLinkMTU(mac)).To(Equal(1400))
+		Expect(nics.SetLinkMTU(mac, 1400)).To(Succeed())
+		Expect(nics.GetLinkMTU(mac)).To(Equal(1400))
+	})
+
+	It("picks the link with the lowest index when several have the mac address", func() {
+		duplicate := testNS.addDummy("pn1", mac)
+		Expect(duplicate.Attrs().Index).To(BeNumerically(">", link.Attrs().Index))
+
+		name, err := nics.GetLinkName(mac)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("pn0"))
+		Expect(nics.DuplicateLinks(mac)).To(Equal([]string{"pn0", "pn1"}))
+
+		Expect(handle.LinkDel(duplicate)).To(Succeed())
+		delete(nics.Links, mac)
+		_, err = nics.GetLinkName(mac)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nics.DuplicateLinks(mac)).To(BeEmpty())
+	})
+
+	It("fails to resolve a mac address of several links with the fail policy", func() {
+		testNS.addDummy("pn1", mac)
+		nics.DuplicateMACPolicy = DuplicateMACFail
+
+		_, err := nics.GetLinkName(mac)
+		Expect(IsDuplicateMAC(err)).To(BeTrue())
+		Expect(IsNotFound(err)).To(BeFalse())
+		Expect(nics.Links).NotTo(HaveKey(mac))
+		Expect(nics.DuplicateLinks(mac)).To(Equal([]string{"pn0", "pn1"}))
+	})
+
+	It("reports whether the link was already resolved", func() {
+		Expect(nics.IsLinkCached(mac)).To(BeFalse())
+		_, err := nics.GetLinkName(mac)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nics.IsLinkCached(mac)).To(BeTrue())
+
+		Expect(handle.LinkDel(link)).To(Succeed())
+		Expect(nics.IsLinkCached(mac)).To(BeFalse())
+	})
+
+	It("sets and restores the router advertisements handling", func() {
+		dir, err := ioutil.TempDir("", "ipv6conf")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		for _, linkName := range []string{"default", "pn0"} {
+			Expect(os.Mkdir(filepath.Join(dir, linkName), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, linkName, "accept_ra"), []byte("1\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, linkName, "autoconf"), []byte("1\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, linkName, "accept_ra_defrtr"), []byte("1\n"), 0644)).To(Succeed())
+		}
+		nics.ipv6ConfDir = dir
+
+		Expect(nics.ConfigureStaticLink(mac, nil, "fd00::10/64")).To(Succeed())
+		Expect(nics.SetAcceptRA(mac, AcceptRA{Accept: true, DefaultRoute: true})).To(Succeed())
+		Expect(readSysctl(dir, "pn0", "accept_ra")).To(Equal("2"))
+		Expect(readSysctl(dir, "pn0", "autoconf")).To(Equal("0"))
+		Expect(readSysctl(dir, "pn0", "accept_ra_defrtr")).To(Equal("1"))
+		addrs, err := handle.AddrList(link, netlink.FAMILY_V6)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hasAddr(addrs, &net.IPNet{IP: net.ParseIP("fd00::10"), Mask: net.CIDRMask(64, 128)})).To(BeTrue())
+
+		Expect(nics.RestoreAcceptRA(mac)).To(Succeed())
+		Expect(readSysctl(dir, "pn0", "accept_ra")).To(Equal("1"))
+		Expect(readSysctl(dir, "pn0", "autoconf")).To(Equal("1"))
+		Expect(readSysctl(dir, "pn0", "accept_ra_defrtr")).To(Equal("1"))
+	})
+
+	It("removes the learned default routes when the router advertisements default route is not accepted", func() {
+		dir, err := ioutil.TempDir("", "ipv6conf")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(os.Mkdir(filepath.Join(dir, "pn0"), 0755)).To(Succeed())
+		for _, name := range []string{"accept_ra", "autoconf", "accept_ra_defrtr"} {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "pn0", name), []byte("1\n"), 0644)).To(Succeed())
+		}
+		nics.ipv6ConfDir = dir
+
+		Expect(nics.ConfigureStaticLink(mac, nil, "fd00::10/64")).To(Succeed())
+		_, raDst, err := net.ParseCIDR("fd01::/64")
+		Expect(err).NotTo(HaveOccurred())
+		for _, dst := range []*net.IPNet{nil, raDst} {
+			Expect(handle.RouteAdd(&netlink.Route{
+				LinkIndex: link.Attrs().Index,
+				Dst:       dst,
+				Gw:        net.ParseIP("fd00::1"),
+				Protocol:  unix.RTPROT_RA,
+			})).To(Succeed())
+		}
+
+		Expect(nics.SetAcceptRA(mac, AcceptRA{Accept: true, Autoconf: true})).To(Succeed())
+		Expect(readSysctl(dir, "pn0", "accept_ra")).To(Equal("2"))
+		Expect(readSysctl(dir, "pn0", "accept_ra_defrtr")).To(Equal("0"))
+		routes, err := handle.RouteList(link, netlink.FAMILY_V6)
+		Expect(err).NotTo(HaveOccurred())
+		var raRoutes []string
+		for _, route := range routes {
+			if route.Protocol == unix.RTPROT_RA {
+				raRoutes = append(raRoutes, route.Dst.String())
+			}
+		}
+		Expect(raRoutes).To(Equal([]string{"fd01::/64"}))
+	})
+	It("sets and restores the local routing sysctls", func() {
+		dir, err := ioutil.TempDir("", "ipv4conf")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		for _, linkName := range []string{"default", "pn0"} {
+			Expect(os.Mkdir(filepath.Join(dir, linkName), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, linkName, "accept_local"), []byte("0\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, linkName, "route_localnet"), []byte("0\n"), 0644)).To(Succeed())
+		}
+		nics.ipv4ConfDir = dir
+
+		Expect(nics.SetLocalRouting(mac, true, false)).To(Succeed())
+		Expect(readSysctl(dir, "pn0", "accept_local")).To(Equal("1"))
+		Expect(readSysctl(dir, "pn0", "route_localnet")).To(Equal("0"))
+
+		Expect(nics.SetLocalRouting(mac, false, true)).To(Succeed())
+		Expect(readSysctl(dir, "pn0", "accept_local")).To(Equal("0"))
+		Expect(readSysctl(dir, "pn0", "route_localnet")).To(Equal("1"))
+
+		Expect(nics.SetLocalRouting(mac, false, false)).To(Succeed())
+		Expect(readSysctl(dir, "pn0", "route_localnet")).To(Equal("0"))
+	})
+
+	It("sets and restores the neighbor table parameters", func() {
+		ipv4Dir, err := ioutil.TempDir("", "ipv4neigh")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(ipv4Dir)
+		for _, linkName := range []string{"default", "pn0"} {
+			Expect(os.Mkdir(filepath.Join(ipv4Dir, linkName), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(ipv4Dir, linkName, "base_reachable_time_ms"), []byte("30000\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(ipv4Dir, linkName, "gc_stale_time"), []byte("60\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(ipv4Dir, linkName, "mcast_solicit"), []byte("3\n"), 0644)).To(Succeed())
+		}
+		// IPv6 disabled on the link
+		ipv6Dir, err := ioutil.TempDir("", "ipv6neigh")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(ipv6Dir)
+		nics.ipv4NeighDir = ipv4Dir
+		nics.ipv6NeighDir = ipv6Dir
+
+		effective, err := nics.SetNeighborSettings(mac, NeighborSettings{BaseReachableTime: 2 * time.Minute, MCastSolicit: 6})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(effective).To(Equal(NeighborSettings{BaseReachableTime: 2 * time.Minute, GCStaleTime: time.Minute, MCastSolicit: 6}))
+		Expect(readSysctl(ipv4Dir, "pn0", "base_reachable_time_ms")).To(Equal("120000"))
+		Expect(readSysctl(ipv4Dir, "pn0", "mcast_solicit")).To(Equal("6"))
+
+		Expect(nics.RestoreNeighborSettings(mac)).To(Succeed())
+		Expect(readSysctl(ipv4Dir, "pn0", "base_reachable_time_ms")).To(Equal("30000"))
+		Expect(readSysctl(ipv4Dir, "pn0", "mcast_solicit")).To(Equal("3"))
+
+		Expect(nics.RestoreNeighborSettings("02:00:00:00:00:09")).To(Succeed())
+	})
+})
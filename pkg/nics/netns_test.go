@@ -0,0 +1,87 @@
+package nics
+
+import (
+	"net"
+	"os"
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// testNamespace is a fresh network namespace the NICs operations are run against
+type testNamespace struct {
+	ns     netns.NsHandle
+	handle *netlink.Handle
+}
+
+// newTestNamespace creates a fresh network namespace, skipping the test when not running as root
+func newTestNamespace() *testNamespace {
+	if os.Geteuid() != 0 {
+		Skip("creating a network namespace requires root")
+	}
+
+	// netns.New switches the thread to the new namespace, switch it back right away
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	origin, err := netns.Get()
+	Expect(err).NotTo(HaveOccurred())
+	defer origin.Close()
+	ns, err := netns.New()
+	Expect(err).NotTo(HaveOccurred())
+	Expect(netns.Set(origin)).To(Succeed())
+
+	handle, err := netlink.NewHandleAt(ns)
+	Expect(err).NotTo(HaveOccurred())
+	return &testNamespace{ns: ns, handle: handle}
+}
+
+// close deletes the namespace with its links
+func (t *testNamespace) close() {
+	if t == nil {
+		return
+	}
+	t.handle.Delete()
+	t.ns.Close()
+}
+
+// nics returns the NICs operating in the namespace
+func (t *testNamespace) nics() *NICs {
+	return &NICs{
+		Handle:            t.handle,
+		Links:             make(map[string]netlink.Link),
+		linkMACs:          make(map[string]string),
+		duplicateLinks:    make(map[string][]string),
+		ingressRateLimits: make(map[string]RateLimit),
+		routePrefs:        make(map[string]string),
+		routeRealms:       make(map[string]int),
+	}
+}
+
+// addDummy adds a dummy link with the given mac address
+func (t *testNamespace) addDummy(name, mac string) netlink.Link {
+	hwAddr, err := net.ParseMAC(mac)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(t.handle.LinkAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name, HardwareAddr: hwAddr}})).To(Succeed())
+	link, err := t.handle.LinkByName(name)
+	Expect(err).NotTo(HaveOccurred())
+	return link
+}
+
+// addVeth adds a veth pair, the link with the given mac address standing for the NIC and its peer
+// for the private network. The peer is left down
+func (t *testNamespace) addVeth(name, peerName, mac string) (netlink.Link, netlink.Link) {
+	hwAddr, err := net.ParseMAC(mac)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(t.handle.LinkAdd(&netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: name, HardwareAddr: hwAddr},
+		PeerName:  peerName,
+	})).To(Succeed())
+	link, err := t.handle.LinkByName(name)
+	Expect(err).NotTo(HaveOccurred())
+	peer, err := t.handle.LinkByName(peerName)
+	Expect(err).NotTo(HaveOccurred())
+	return link, peer
+}
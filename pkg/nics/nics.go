@@ -1,32 +1,196 @@
 package nics
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
 const (
 	dhcpcdRunFilePrefix = "/var/run/dhcpcd-"
 	dhcpcdRunFileSuffix = "-4.pid"
+
+	ingressHandleMajor = 0xffff
+	// ingressFilterPriority is the priority of the filter policing the inbound traffic
+	ingressFilterPriority = 1
+
+	linkUpPollInterval = time.Millisecond * 100
 )
 
 var (
-	nicNotFoundErr = errors.New("NIC not found")
+	nicNotFoundErr   = errors.New("NIC not found")
+	linkChangedErr   = errors.New("link address changed")
+	routeConflictErr = errors.New("route installed by another agent")
+	duplicateMACErr  = errors.New("several links have the address")
+)
+
+const (
+	// DuplicateMACPickFirst resolves a mac address matching several links to the one with the lowest index
+	DuplicateMACPickFirst = "PickFirst"
+	// DuplicateMACFail fails to resolve a mac address matching several links
+	DuplicateMACFail = "Fail"
 )
 
 type Route struct {
 	To  *net.IPNet
 	Via net.IP
+	// Src is the preferred source address of the route, if any
+	Src net.IP
+	// Metric is the priority of the route, lower is preferred
+	Metric int
+	// Protocol is the origin of the route, if any
+	Protocol int
+	// Pref is the preference of an IPv6 route, one of low, medium or high, medium if empty
+	Pref string
+	// Realm is the realm of an IPv4 route for traffic accounting, none if 0
+	Realm int
+	// OnConflict is what is done when another agent installed a route to the same destination at the same metric,
+	// one of RouteConflictReplace, RouteConflictFail or RouteConflictSkip, Replace if empty
+	OnConflict string
+}
+
+const (
+	// RouteConflictReplace replaces the route installed by another agent
+	RouteConflictReplace = "Replace"
+	// RouteConflictFail leaves the route installed by another agent and fails the installation of the route
+	RouteConflictFail = "Fail"
+	// RouteConflictSkip leaves the route installed by another agent and skips the route
+	RouteConflictSkip = "Skip"
+)
+
+// MaxRouteRealm is the highest realm a route can be installed with
+const MaxRouteRealm = 255
+
+// ipv6DefaultMetric is the metric given by the kernel to IPv6 routes without metric
+const ipv6DefaultMetric = 1024
+
+// RouteProtocol is the protocol of the routes installed without an explicit protocol
+// Only the routes carrying it or BGPRouteProtocol are ever removed, so that the routes of the kernel,
+// of the router advertisements, of other routing daemons or added by hand are left untouched
+const RouteProtocol = 200
+
+// BGPRouteProtocol is the protocol of the routes learned by gobgp, only used by the node daemon,
+// the bgp protocol being shared with the other routing daemons of the node
+const BGPRouteProtocol = 201
+
+// pref returns the preference the route is installed with
+func (r Route) pref() string {
+	if r.Pref == "" {
+		return "medium"
+	}
+	return r.Pref
+}
+
+// key identifies the route in the given routing table
+func (r Route) key(table int) string {
+	return fmt.Sprintf("%d %s %s %d", table, r.To, r.Via, r.priority())
+}
+
+// protocol returns the protocol the route is installed with
+func (r Route) protocol() int {
+	if r.Protocol == 0 {
+		return RouteProtocol
+	}
+	return r.Protocol
+}
+
+// IsOwned returns whether the route was installed by the node daemon
+func IsOwned(route netlink.Route) bool {
+	return route.Protocol == RouteProtocol || route.Protocol == BGPRouteProtocol
+}
+
+// priority returns the priority of the route as set by the kernel
+func (r Route) priority() int {
+	if r.Metric == 0 && r.To != nil && r.To.IP.To4() == nil {
+		return ipv6DefaultMetric
+	}
+	return r.Metric
+}
+
+// AboveDefaultRoute returns the route with its metric raised to the metric of the given node default route
+// plus offset when it would otherwise be preferred over, or tie with, the node default route
+func (r Route) AboveDefaultRoute(defaultRoute *DefaultRoute, offset int) Route {
+	if defaultRoute != nil && r.priority() <= defaultRoute.Metric {
+		r.Metric = defaultRoute.Metric + offset
+	}
+	return r
+}
+
+// ParseRoute parses and validates a route to a CIDR or a single address via a gateway
+// A single address, or a /32 or /128 CIDR, gives a host route
+func ParseRoute(to string, via string) (Route, error) {
+	var dst *net.IPNet
+	if ip := net.ParseIP(to); ip != nil {
+		dst = hostIPNet(ip)
+	} else {
+		ip, ipnet, err := net.ParseCIDR(to)
+		if err != nil {
+			return Route{}, fmt.Errorf("invalid route destination %s: %w", to, err)
+		}
+		if !ip.Equal(ipnet.IP) {
+			return Route{}, fmt.Errorf("route destination %s has host bits set, use %s instead", to, ipnet.String())
+		}
+		dst = ipnet
+	}
+
+	gw := net.ParseIP(via)
+	if gw == nil {
+		return Route{}, fmt.Errorf("invalid route gateway %s", via)
+	}
+	if (gw.To4() == nil) != (dst.IP.To4() == nil) {
+		return Route{}, fmt.Errorf("route gateway %s and destination %s are not of the same family", via, to)
+	}
+	if IsHostRoute(dst) && dst.IP.Equal(gw) {
+		return Route{}, fmt.Errorf("host route to %s can't use itself as gateway", to)
+	}
+	if gw.To4() != nil {
+		gw = gw.To4()
+	}
+
+	return Route{
+		To:  dst,
+		Via: gw,
+	}, nil
+}
+
+func hostIPNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// IsHostRoute returns whether the destination is a single address
+func IsHostRoute(dst *net.IPNet) bool {
+	if dst == nil {
+		return false
+	}
+	ones, bits := dst.Mask.Size()
+	return bits != 0 && ones == bits
+}
+
+// sameDst returns whether the destination of an existing route is to, netlink listing the default routes
+// without destination
+func sameDst(dst *net.IPNet, to *net.IPNet) bool {
+	return dst.String() == to.String() || (isDefault(dst) && isDefault(to))
 }
 
 func (r Route) isIn(routes []netlink.Route) bool {
 	for _, route := range routes {
-		if route.Dst.String() == r.To.String() && route.Gw.Equal(r.Via) {
+		if sameDst(route.Dst, r.To) && route.Gw.Equal(r.Via) && route.Priority == r.priority() && route.Protocol == r.protocol() &&
+			(r.Src == nil || route.Src.Equal(r.Src)) {
 			return true
 		}
 	}
@@ -35,16 +199,96 @@ func (r Route) isIn(routes []netlink.Route) bool {
 
 func isIn(r netlink.Route, routes []Route) bool {
 	for _, route := range routes {
-		if r.Dst.String() == route.To.String() && r.Gw.Equal(route.Via) {
+		if sameDst(r.Dst, route.To) && r.Gw.Equal(route.Via) && r.Priority == route.priority() {
 			return true
 		}
 	}
 	return false
 }
 
+// staleRoutes returns the existing routes installed by the node daemon which are not in routes
+func staleRoutes(existingRoutes []netlink.Route, routes []Route) []netlink.Route {
+	stale := []netlink.Route{}
+	for _, existingRoute := range existingRoutes {
+		if IsOwned(existingRoute) && !isIn(existingRoute, routes) {
+			stale = append(stale, existingRoute)
+		}
+	}
+	return stale
+}
+
+// DefaultRoute is a default route of the node
+type DefaultRoute struct {
+	// Family is the netlink family of the route, as a route without gateway doesn't tell it
+	Family   int
+	Via      net.IP
+	LinkName string
+	// Metric is the metric of the route, the route is restored without it
+	Metric int
+}
+
+// IsDefault returns whether the route is a default route
+func (r Route) IsDefault() bool {
+	return isDefault(r.To)
+}
+
+func isDefault(dst *net.IPNet) bool {
+	if dst == nil {
+		return true
+	}
+	ones, _ := dst.Mask.Size()
+	return ones == 0 && dst.IP.IsUnspecified()
+}
+
 type NICs struct {
 	Handle *netlink.Handle
 	Links  map[string]netlink.Link
+
+	// AuditLog records every change made to the links, addresses, routes and rules, nil disables it
+	AuditLog logr.Logger
+
+	// ENOBUFSRetries is the number of retries of the route and rule operations failing because
+	// the receive buffer of the netlink socket overflowed
+	ENOBUFSRetries int
+	// MaxReceiveBufferSize is the size up to which the receive buffer of the netlink socket is grown
+	// on overflow
+	MaxReceiveBufferSize int
+	// DuplicateMACPolicy is how a mac address matching several links is resolved, one of DuplicateMACPickFirst
+	// or DuplicateMACFail, PickFirst if empty
+	DuplicateMACPolicy string
+	// ReplaceAddresses updates in place the static addresses whose attributes differ, such as an address
+	// with a lifetime or another prefix route flag, rather than deleting and adding them again
+	ReplaceAddresses bool
+	// receiveBufferSize is the current size of the receive buffer of the netlink socket, 0 if unknown
+	receiveBufferSize int
+	// ipv4ConfDir and ipv6ConfDir are the directories holding the IPv4 and IPv6 sysctls of the links
+	ipv4ConfDir string
+	ipv6ConfDir string
+	// ipv4NeighDir and ipv6NeighDir are the directories holding the IPv4 and IPv6 neighbor table parameters of the links
+	ipv4NeighDir string
+	ipv6NeighDir string
+
+	// linkMACs holds the mac address set on a link, by original mac address
+	linkMACs map[string]string
+	// duplicateLinks holds the names of the links matching a mac address when there are several,
+	// as of the last time it was resolved, by mac address
+	duplicateLinks map[string][]string
+	// ingressRateLimits holds the ingress rate limit applied on a link, by mac address
+	ingressRateLimits map[string]RateLimit
+	// routePrefs holds the preference the IPv6 routes were installed with, by route key,
+	// as netlink can't read it back
+	routePrefs map[string]string
+	// routeRealms holds the realm the IPv4 routes were installed with, by route key,
+	// as netlink can't read it back
+	routeRealms map[string]int
+}
+
+// RateLimit is a bandwidth limit
+type RateLimit struct {
+	// Rate is the limit in bits per second
+	Rate uint64
+	// Burst is the amount of bytes allowed above the rate at once
+	Burst uint64
 }
 
 func NewNICs(macs []string) (*NICs, error) {
@@ -54,8 +298,19 @@ func NewNICs(macs []string) (*NICs, error) {
 	}
 
 	nics := &NICs{
-		Handle: handle,
-		Links:  make(map[string]netlink.Link),
+		Handle:               handle,
+		Links:                make(map[string]netlink.Link),
+		ENOBUFSRetries:       DefaultENOBUFSRetries,
+		MaxReceiveBufferSize: DefaultMaxReceiveBufferSize,
+		ipv4ConfDir:          DefaultIPv4ConfDir,
+		ipv6ConfDir:          DefaultIPv6ConfDir,
+		ipv4NeighDir:         DefaultIPv4NeighDir,
+		ipv6NeighDir:         DefaultIPv6NeighDir,
+		linkMACs:             make(map[string]string),
+		duplicateLinks:       make(map[string][]string),
+		ingressRateLimits:    make(map[string]RateLimit),
+		routePrefs:           make(map[string]string),
+		routeRealms:          make(map[string]int),
 	}
 
 	links, err := handle.LinkList()
@@ -63,18 +318,25 @@ func NewNICs(macs []string) (*NICs, error) {
 		return nil, err
 	}
 
-	for _, link := range links {
-		for _, mac := range macs {
-			if link.Attrs().HardwareAddr.String() == mac {
-				nics.Links[mac] = link
-				break
-			}
+	for _, mac := range macs {
+		// the links of a duplicate mac address are resolved on first use, with the DuplicateMACPolicy set
+		if matching := nics.matchLinks(mac, links); len(matching) == 1 {
+			nics.Links[mac] = matching[0]
 		}
 	}
 
 	return nics, nil
 }
 
+// GetLinkMAC returns the current hardware address of the link with the given mac
+func (n *NICs) GetLinkMAC(mac string) (string, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return "", err
+	}
+	return link.Attrs().HardwareAddr.String(), nil
+}
+
 func (n *NICs) GetLinkName(mac string) (string, error) {
 	link, err := n.getLink(mac)
 	if err != nil {
@@ -83,9 +345,80 @@ func (n *NICs) GetLinkName(mac string) (string, error) {
 	return link.Attrs().Name, nil
 }
 
+// IsLinkCached returns whether the link with the given mac was already resolved and still exists,
+// without looking it up among the links of the node
+func (n *NICs) IsLinkCached(mac string) bool {
+	link, ok := n.Links[mac]
+	if !ok {
+		return false
+	}
+	current, err := n.Handle.LinkByIndex(link.Attrs().Index)
+	return err == nil && n.isLinkOf(mac, current)
+}
+
+// IsNotFound returns whether the error is due to a missing link
+func IsNotFound(err error) bool {
+	return errors.Is(err, nicNotFoundErr)
+}
+
+// IsDuplicateMAC returns whether the error is due to a mac address matching several links,
+// with the DuplicateMACFail policy
+func IsDuplicateMAC(err error) bool {
+	return errors.Is(err, duplicateMACErr)
+}
+
+// IsLinkChanged returns true if the link resolved for a NIC no longer carried its address when configured
+// the configuration is aborted before any change and can be retried
+func IsLinkChanged(err error) bool {
+	return errors.Is(err, linkChangedErr)
+}
+
+// verifyLink checks that the link resolved for the mac still carries it, right before mutating it
+func (n *NICs) verifyLink(mac string, link netlink.Link) error {
+	current, err := n.Handle.LinkByIndex(link.Attrs().Index)
+	if err != nil {
+		delete(n.Links, mac)
+		return fmt.Errorf("link %s of address %s: %v: %w", link.Attrs().Name, mac, err, linkChangedErr)
+	}
+	if !n.isLinkOf(mac, current) {
+		delete(n.Links, mac)
+		return fmt.Errorf("link %s now has address %s instead of %s: %w", current.Attrs().Name, current.Attrs().HardwareAddr, mac, linkChangedErr)
+	}
+	return nil
+}
+
+func (n *NICs) isLinkOf(mac string, link netlink.Link) bool {
+	hwAddr := link.Attrs().HardwareAddr.String()
+	return MACEqual(hwAddr, mac) || (n.linkMACs[mac] != "" && hwAddr == n.linkMACs[mac])
+}
+
+// NormalizeMAC returns the mac address in lowercase and colon separated, as the kernel reports it,
+// or only lowercased when it can't be parsed
+func NormalizeMAC(mac string) string {
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return strings.ToLower(mac)
+	}
+	return hwAddr.String()
+}
+
+// MACEqual returns whether the mac addresses are the same, regardless of their case and separators
+func MACEqual(a, b string) bool {
+	return NormalizeMAC(a) == NormalizeMAC(b)
+}
+
+// getLink returns the link with the given mac. The links are only matched by mac address, as the metadata
+// of the SDK only exposes the id, server id, private network id and mac address of the private NICs,
+// their state being decoded by the node daemon itself, and none of them identifies a link otherwise
 func (n *NICs) getLink(mac string) (netlink.Link, error) {
 	if link, ok := n.Links[mac]; ok {
-		return link, nil
+		// the link may have been removed since it was cached
+		current, err := n.Handle.LinkByIndex(link.Attrs().Index)
+		if err == nil && n.isLinkOf(mac, current) {
+			n.Links[mac] = current
+			return current, nil
+		}
+		delete(n.Links, mac)
 	}
 
 	links, err := n.Handle.LinkList()
@@ -93,14 +426,155 @@ func (n *NICs) getLink(mac string) (netlink.Link, error) {
 		return nil, err
 	}
 
+	matching := n.matchLinks(mac, links)
+	if len(matching) == 0 {
+		delete(n.duplicateLinks, mac)
+		return nil, fmt.Errorf("link with address %s: %w", mac, nicNotFoundErr)
+	}
+	if len(matching) == 1 {
+		delete(n.duplicateLinks, mac)
+		n.Links[mac] = matching[0]
+		return matching[0], nil
+	}
+
+	names := make([]string, 0, len(matching))
+	for _, link := range matching {
+		names = append(names, link.Attrs().Name)
+	}
+	n.duplicateLinks[mac] = names
+	if n.DuplicateMACPolicy == DuplicateMACFail {
+		return nil, fmt.Errorf("links %s with address %s: %w", strings.Join(names, ", "), mac, duplicateMACErr)
+	}
+	n.Links[mac] = matching[0]
+	return matching[0], nil
+}
+
+// matchLinks returns the links of the given mac among the links, sorted by index
+func (n *NICs) matchLinks(mac string, links []netlink.Link) []netlink.Link {
+	matching := []netlink.Link{}
 	for _, link := range links {
-		if link.Attrs().HardwareAddr.String() == mac {
-			n.Links[mac] = link
-			return link, nil
+		if n.isLinkOf(mac, link) {
+			matching = append(matching, link)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].Attrs().Index < matching[j].Attrs().Index
+	})
+	return matching
+}
+
+// DuplicateLinks returns the names of the links matching the given mac, sorted by index, when several
+// matched the last time it was resolved
+func (n *NICs) DuplicateLinks(mac string) []string {
+	return n.duplicateLinks[mac]
+}
+
+// SetPromiscuous sets or clears the promiscuous mode of the link with the given mac
+func (n *NICs) SetPromiscuous(mac string, promisc bool) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if !promisc && errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+	if (link.Attrs().Promisc != 0) == promisc {
+		return nil
+	}
+	return n.linkSetPromisc(link, promisc)
+}
+
+// SetLinkAlias sets the alias of the link with the given mac, an empty alias removing it
+func (n *NICs) SetLinkAlias(mac string, alias string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if alias == "" && errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+	if link.Attrs().Alias == alias {
+		return nil
+	}
+	return n.linkSetAlias(link, alias)
+}
+
+// SetLinkMAC sets linkMAC as the hardware address of the link with the given mac
+// The link is still resolved with its original mac afterwards
+func (n *NICs) SetLinkMAC(mac string, linkMAC string) error {
+	hwAddr, err := net.ParseMAC(linkMAC)
+	if err != nil {
+		return err
+	}
+	if len(hwAddr) != 6 || hwAddr[0]&1 == 1 || bytes.Equal(hwAddr, make(net.HardwareAddr, 6)) {
+		return fmt.Errorf("mac address %s is not a valid unicast ethernet address", linkMAC)
+	}
+	n.linkMACs[mac] = hwAddr.String()
+
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+
+	if link.Attrs().HardwareAddr.String() == hwAddr.String() {
+		return nil
+	}
+	return n.setHardwareAddr(mac, link, hwAddr)
+}
+
+// TrackLinkMAC records linkMAC as the hardware address set on the link with the given mac by a previous run,
+// so that the link is still resolved with its original mac, and its hardware address can be restored
+func (n *NICs) TrackLinkMAC(mac string, linkMAC string) {
+	if linkMAC == "" || n.linkMACs[mac] != "" {
+		return
+	}
+	n.linkMACs[mac] = NormalizeMAC(linkMAC)
+}
+
+// RestoreLinkMAC sets back the original hardware address of the link with the given mac
+func (n *NICs) RestoreLinkMAC(mac string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
 		}
+		return err
+	}
+	delete(n.linkMACs, mac)
+
+	if MACEqual(link.Attrs().HardwareAddr.String(), mac) {
+		return nil
+	}
+
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return err
+	}
+	return n.setHardwareAddr(mac, link, hwAddr)
+}
+
+func (n *NICs) setHardwareAddr(mac string, link netlink.Link, hwAddr net.HardwareAddr) error {
+	err := n.linkSetDown(link)
+	if err != nil {
+		return err
+	}
+
+	err = n.linkSetHardwareAddr(link, hwAddr)
+	if err != nil {
+		return err
+	}
+
+	err = n.linkSetUp(link)
+	if err != nil {
+		return err
 	}
 
-	return nil, fmt.Errorf("link with address %s: %w", mac, nicNotFoundErr)
+	link, err = n.Handle.LinkByIndex(link.Attrs().Index)
+	if err != nil {
+		return err
+	}
+	n.Links[mac] = link
+	return nil
 }
 
 func maskEqual(m1, m2 net.IPMask) bool {
@@ -116,11 +590,27 @@ func maskEqual(m1, m2 net.IPMask) bool {
 	return true
 }
 
+func addrEqual(addr netlink.Addr, ipnet *net.IPNet) bool {
+	return maskEqual(addr.IPNet.Mask, ipnet.Mask) && addr.IPNet.IP.Equal(ipnet.IP)
+}
+
+func hasAddr(addrs []netlink.Addr, ipnet *net.IPNet) bool {
+	for _, addr := range addrs {
+		if addrEqual(addr, ipnet) {
+			return true
+		}
+	}
+	return false
+}
+
 func (n *NICs) ConfigureDHCPLink(mac string) (string, error) {
 	link, err := n.getLink(mac)
 	if err != nil {
 		return "", err
 	}
+	if err := n.verifyLink(mac, link); err != nil {
+		return "", err
+	}
 	if _, err := os.Stat(dhcpcdRunFilePrefix + link.Attrs().Name + dhcpcdRunFileSuffix); err != nil {
 		if !os.IsNotExist(err) {
 			return "", err
@@ -133,12 +623,12 @@ func (n *NICs) ConfigureDHCPLink(mac string) (string, error) {
 		}
 	}
 
-	err = netlink.LinkSetUp(link)
+	err = n.linkSetUp(link)
 	if err != nil {
 		return "", err
 	}
 
-	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	addrs, err := n.Handle.AddrList(link, netlink.FAMILY_V4)
 	if err != nil {
 		return "", err
 	}
@@ -150,77 +640,171 @@ func (n *NICs) ConfigureDHCPLink(mac string) (string, error) {
 	return addrs[0].IP.String(), nil
 }
 
-func (n *NICs) ConfigureStaticLink(mac string, ip string) error {
+// ConfigureStaticLink configures the link with the given mac with the given addresses
+// The previous addresses, configured before and replaced by the given ones, are removed, the other
+// addresses of the link being left to whoever added them
+func (n *NICs) ConfigureStaticLink(mac string, previous []string, ips ...string) error {
+	return n.configureStaticLink(mac, false, previous, ips)
+}
+
+// ConfigureStaticLinkNoPrefixRoute configures the link with the given mac like ConfigureStaticLink,
+// adding the addresses with the NOPREFIXROUTE flag so that the kernel doesn't install their connected routes
+// The addresses already on the link without the flag are added again with it
+func (n *NICs) ConfigureStaticLinkNoPrefixRoute(mac string, previous []string, ips ...string) error {
+	return n.configureStaticLink(mac, true, previous, ips)
+}
+
+func parseIPNets(ips []string) ([]*net.IPNet, error) {
+	ipnets := []*net.IPNet{}
+	for _, ip := range ips {
+		ipnet, err := netlink.ParseIPNet(ip)
+		if err != nil {
+			return nil, err
+		}
+		ipnets = append(ipnets, ipnet)
+	}
+	return ipnets, nil
+}
+
+func (n *NICs) configureStaticLink(mac string, noPrefixRoute bool, previous, ips []string) error {
 	link, err := n.getLink(mac)
 	if err != nil {
 		return err
 	}
 
-	ipnet, err := netlink.ParseIPNet(ip)
+	ipnets, err := parseIPNets(ips)
+	if err != nil {
+		return err
+	}
+	previousNets, err := parseIPNets(previous)
 	if err != nil {
 		return err
 	}
 
-	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	addrs, err := n.Handle.AddrList(link, netlink.FAMILY_ALL)
 	if err != nil {
 		return err
 	}
 
-	ipFound := false
-	for _, addr := range addrs {
-		if maskEqual(addr.IPNet.Mask, ipnet.Mask) && addr.IPNet.IP.Equal(ipnet.IP) {
-			ipFound = true
-			break
-		}
+	err = n.verifyLink(mac, link)
+	if err != nil {
+		return err
 	}
 
-	if !ipFound {
-		err := netlink.AddrAdd(link, &netlink.Addr{
-			IPNet: ipnet,
-		})
+	flags := 0
+	if noPrefixRoute {
+		flags = unix.IFA_F_NOPREFIXROUTE
+	}
+
+	kept := []netlink.Addr{}
+	for _, addr := range addrs {
+		if addr.Scope != int(netlink.SCOPE_UNIVERSE) {
+			kept = append(kept, addr)
+			continue
+		}
+		var desired *net.IPNet
+		for _, ipnet := range ipnets {
+			if addrEqual(addr, ipnet) {
+				desired = ipnet
+				break
+			}
+		}
+		if desired == nil {
+			// only the replaced addresses are removed, the VIPs or the addresses added by hand are left untouched
+			replaced := false
+			for _, ipnet := range previousNets {
+				if addrEqual(addr, ipnet) {
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				kept = append(kept, addr)
+				continue
+			}
+			err := n.addrDel(link, &addr)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		permanent := addr.Flags&unix.IFA_F_PERMANENT != 0
+		if !permanent && !n.ReplaceAddresses {
+			kept = append(kept, addr)
+			continue
+		}
+		if permanent && addr.Flags&unix.IFA_F_NOPREFIXROUTE == flags {
+			kept = append(kept, addr)
+			continue
+		}
+		if n.ReplaceAddresses {
+			replaced := netlink.Addr{IPNet: desired, Flags: flags}
+			err := n.replaceAddr(link, &replaced)
+			if err != nil {
+				return err
+			}
+			kept = append(kept, replaced)
+			continue
+		}
+		// the flag of an address can't be changed on all kernels, it is added again instead
+		err := n.addrDel(link, &addr)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = netlink.LinkSetUp(link)
+	for _, ipnet := range ipnets {
+		if !hasAddr(kept, ipnet) {
+			err := n.addrAdd(link, &netlink.Addr{
+				IPNet: ipnet,
+				Flags: flags,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = n.linkSetUp(link)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (n *NICs) TearDownDHCPLink(mac string) error {
-	link, err := n.getLink(mac)
+// replaceAddr updates the address of the link in place, making it permanent, and adds it again
+// when the kernel doesn't update its prefix route flag in place
+func (n *NICs) replaceAddr(link netlink.Link, addr *netlink.Addr) error {
+	err := n.addrReplace(link, addr)
 	if err != nil {
-		if errors.Is(err, nicNotFoundErr) {
-			return nil
-		}
 		return err
 	}
-
-	_, err = os.Stat(dhcpcdRunFilePrefix + link.Attrs().Name + dhcpcdRunFileSuffix)
-	if err != nil && !os.IsNotExist(err) {
+	family := netlink.FAMILY_V6
+	if addr.IP.To4() != nil {
+		family = netlink.FAMILY_V4
+	}
+	addrs, err := n.Handle.AddrList(link, family)
+	if err != nil {
 		return err
 	}
-
-	if err == nil {
-		cmd := exec.Command("dhcpcd", "-A4", "--waitip", "-C", "resolv.conf", "-G", "-k", link.Attrs().Name)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return err
+	for i := range addrs {
+		if addrEqual(addrs[i], addr.IPNet) && addrs[i].Flags&unix.IFA_F_NOPREFIXROUTE != addr.Flags&unix.IFA_F_NOPREFIXROUTE {
+			err = n.addrDel(link, &addrs[i])
+			if err != nil {
+				return err
+			}
+			return n.addrAdd(link, addr)
 		}
 	}
+	return nil
+}
 
-	err = netlink.LinkSetDown(link)
+// TearDownDHCPLink flushes the link with the given mac like FlushDHCPLink, and brings it down
+func (n *NICs) TearDownDHCPLink(mac string) error {
+	err := n.FlushDHCPLink(mac)
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-func (n *NICs) TearDownStaticLink(mac string, ip string) error {
 	link, err := n.getLink(mac)
 	if err != nil {
 		if errors.Is(err, nicNotFoundErr) {
@@ -228,72 +812,1017 @@ func (n *NICs) TearDownStaticLink(mac string, ip string) error {
 		}
 		return err
 	}
+	return n.linkSetDown(link)
+}
 
-	ipnet, err := netlink.ParseIPNet(ip)
+// FlushDHCPLink removes the routes the node daemon installed on the link with the given mac in the main table
+// and releases its DHCP lease, in this order, leaving it up
+func (n *NICs) FlushDHCPLink(mac string) error {
+	link, err := n.getLink(mac)
 	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
 		return err
 	}
 
-	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	err = n.tearDownRoutes(link)
 	if err != nil {
 		return err
 	}
 
-	ipFound := false
-	for _, addr := range addrs {
-		if maskEqual(addr.IPNet.Mask, ipnet.Mask) && addr.IPNet.IP.Equal(ipnet.IP) {
-			ipFound = true
-			break
-		}
+	_, err = os.Stat(dhcpcdRunFilePrefix + link.Attrs().Name + dhcpcdRunFileSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
 
-	if ipFound {
-		err := netlink.AddrDel(link, &netlink.Addr{
-			IPNet: ipnet,
-		})
-		if err != nil {
+	if err == nil {
+		cmd := exec.Command("dhcpcd", "-A4", "--waitip", "-C", "resolv.conf", "-G", "-k", link.Attrs().Name)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	err = netlink.LinkSetDown(link)
+// TearDownStaticLink flushes the link with the given mac like FlushStaticLink, and brings it down
+func (n *NICs) TearDownStaticLink(mac string, ips ...string) error {
+	err := n.FlushStaticLink(mac, ips...)
 	if err != nil {
 		return err
 	}
-	return nil
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+	return n.linkSetDown(link)
 }
 
-func (n *NICs) SyncRoutes(mac string, routes []Route) error {
+// FlushStaticLink removes the routes the node daemon installed on the link with the given mac in the main table
+// and removes the given addresses from it, in this order, leaving it up
+func (n *NICs) FlushStaticLink(mac string, ips ...string) error {
 	link, err := n.getLink(mac)
 	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
 		return err
 	}
 
-	existingRoutes, err := netlink.RouteList(link, netlink.FAMILY_ALL)
+	err = n.tearDownRoutes(link)
 	if err != nil {
 		return err
 	}
 
-	for _, existingRoute := range existingRoutes {
-		if !isIn(existingRoute, routes) && existingRoute.Src == nil {
-			err := netlink.RouteDel(&existingRoute)
+	addrs, err := n.Handle.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return err
+	}
+
+	for _, ip := range ips {
+		ipnet, err := netlink.ParseIPNet(ip)
+		if err != nil {
+			return err
+		}
+
+		if hasAddr(addrs, ipnet) {
+			err := n.addrDel(link, &netlink.Addr{
+				IPNet: ipnet,
+			})
 			if err != nil {
 				return err
 			}
 		}
 	}
+	return nil
+}
+
+// tearDownRoutes removes the routes the node daemon installed on the link in the main table. It must run before the addresses are removed: the kernel then removes the routes
+// through the gateways they made reachable, and deleting them fails
+func (n *NICs) tearDownRoutes(link netlink.Link) error {
+	return n.flushRoutes(link, 0)
+}
+
+// flushRoutes removes the routes the node daemon installed on the link in the given routing table,
+// 0 being the main table. The routes added by the kernel for its addresses, or by other tools, are kept
+func (n *NICs) flushRoutes(link netlink.Link, table int) error {
+	routes, err := n.routeList(link, table)
+	if err != nil {
+		return err
+	}
 
 	for _, route := range routes {
-		if !route.isIn(existingRoutes) {
-			err := netlink.RouteAdd(&netlink.Route{
+		if !IsOwned(route) {
+			continue
+		}
+		err := n.routeDel(link, &route)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// routeList returns the routes of the link in the given routing table, 0 being the main table
+func (n *NICs) routeList(link netlink.Link, table int) ([]netlink.Route, error) {
+	var routes []netlink.Route
+	err := n.retry(func() error {
+		var err error
+		if table == 0 {
+			routes, err = n.Handle.RouteList(link, netlink.FAMILY_ALL)
+		} else {
+			routes, err = n.Handle.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{
 				LinkIndex: link.Attrs().Index,
-				Dst:       route.To,
-				Gw:        route.Via,
-			})
-			if err != nil {
-				return err
+				Table:     table,
+			}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+		}
+		return err
+	})
+	return routes, err
+}
+
+// FlushRoutes removes the routes the node daemon installed on the link with the given mac in the given
+// routing table, 0 being the main table
+func (n *NICs) FlushRoutes(mac string, table int) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+	return n.flushRoutes(link, table)
+}
+
+// SetLinkUp brings up the link with the given mac
+func (n *NICs) SetLinkUp(mac string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+	return n.linkSetUp(link)
+}
+
+// IsLinkAdminUp returns whether the link with the given mac is administratively up
+func (n *NICs) IsLinkAdminUp(mac string) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+	return link.Attrs().Flags&net.FlagUp != 0, nil
+}
+
+// WaitLinkUp polls the link with the given mac until it is operationally up with a carrier,
+// returning false if it is still not up after the timeout, and whether it has a carrier
+func (n *NICs) WaitLinkUp(mac string, timeout time.Duration) (bool, bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, false, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		link, err = n.Handle.LinkByIndex(link.Attrs().Index)
+		if err != nil {
+			return false, false, err
+		}
+		if isLinkUp(link.Attrs()) {
+			return true, true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, hasCarrier(link.Attrs()), nil
+		}
+		time.Sleep(linkUpPollInterval)
+	}
+}
+
+// IsLinkUp returns whether the link with the given mac is operationally up with a carrier,
+// a missing link being down
+func (n *NICs) IsLinkUp(mac string) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return false, nil
+		}
+		return false, err
+	}
+	return isLinkUp(link.Attrs()), nil
+}
+
+// isLinkUp returns whether the link is operationally up with a carrier, the drivers without
+// operational state reporting an unknown state
+func isLinkUp(attrs *netlink.LinkAttrs) bool {
+	return hasCarrier(attrs) && (attrs.OperState == netlink.OperUp || attrs.OperState == netlink.OperUnknown)
+}
+
+// hasCarrier returns whether the link is up and has a carrier (LOWER_UP)
+func hasCarrier(attrs *netlink.LinkAttrs) bool {
+	return attrs.Flags&net.FlagUp != 0 && attrs.RawFlags&unix.IFF_LOWER_UP != 0
+}
+
+// SetLinkDown brings down the link with the given mac, the kernel removing its routes
+func (n *NICs) SetLinkDown(mac string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+	return n.linkSetDown(link)
+}
+
+// SyncRoutes syncs the routes of the link with the given mac in the given routing table,
+// 0 being the main table. Only the routes installed by the node daemon are removed
+func (n *NICs) SyncRoutes(mac string, table int, routes []Route) error {
+	_, err := n.ApplyRoutes(mac, table, routes)
+	return err
+}
+
+// RouteResult is the outcome of the installation of a route
+type RouteResult struct {
+	Route Route
+	// Priority is the priority of the route as set by the kernel
+	Priority int
+	// Err is the error the route failed to be installed with, nil if it is installed
+	Err error
+	// Applied is whether the route was installed or replaced, false when it was already present
+	Applied bool
+	// Skipped is whether the route was left out for a route of another agent, with the RouteConflictSkip policy
+	Skipped bool
+}
+
+// IsRouteConflict returns true if a route was not installed since another agent installed a route in its place
+func IsRouteConflict(err error) bool {
+	return errors.Is(err, routeConflictErr)
+}
+
+// ApplyRoutes syncs the routes of the link with the given mac like SyncRoutes, and returns the outcome
+// of the installation of each route. A route failing to be installed does not prevent the next ones
+// from being installed, the first failure being returned once all of them were tried
+func (n *NICs) ApplyRoutes(mac string, table int, routes []Route) ([]RouteResult, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	existingRoutes, err := n.routeList(link, table)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, staleRoute := range staleRoutes(existingRoutes, routes) {
+		err := n.routeDel(link, &staleRoute)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]RouteResult, 0, len(routes))
+	var firstErr error
+	for _, route := range routes {
+		result := RouteResult{Route: route, Priority: route.priority()}
+		// the preference and realm of the routes installed before a restart are unknown, they are replaced once
+		prefChanged := route.To.IP.To4() == nil && n.routePrefs[route.key(table)] != route.pref()
+		realm, realmKnown := n.routeRealms[route.key(table)]
+		realmChanged := route.To.IP.To4() != nil && (!realmKnown || realm != route.Realm)
+		if !route.isIn(existingRoutes) || prefChanged || realmChanged {
+			result.Skipped, result.Err = n.checkRouteConflict(table, route)
+			if result.Err == nil && !result.Skipped {
+				result.Err = n.replaceRoute(link, table, route)
+				result.Applied = result.Err == nil
+			}
+			if result.Err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("unable to install route to %s via %s: %w", route.To, route.Via, result.Err)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, firstErr
+}
+
+// checkRouteConflict applies the conflict policy of the route when another agent installed a route
+// in the given table that installing it would replace, returning whether the route is skipped
+// with the Skip policy, and an error with the Fail policy
+func (n *NICs) checkRouteConflict(table int, route Route) (bool, error) {
+	if route.OnConflict == "" || route.OnConflict == RouteConflictReplace {
+		return false, nil
+	}
+	foreign, err := n.foreignRoute(table, route)
+	if err != nil || foreign == nil {
+		return false, err
+	}
+	if route.OnConflict == RouteConflictSkip {
+		return true, nil
+	}
+	return false, fmt.Errorf("route to %s via %s with protocol %d: %w", route.To, foreign.Gw, foreign.Protocol, routeConflictErr)
+}
+
+// foreignRoute returns the route not installed by the node daemon to the destination and at the priority
+// of the route in the given table, on any link, nil if there is none
+func (n *NICs) foreignRoute(table int, route Route) (*netlink.Route, error) {
+	family := netlink.FAMILY_V4
+	if route.To.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	filter, filterMask := &netlink.Route{Table: table}, uint64(0)
+	if table != 0 {
+		filterMask = netlink.RT_FILTER_TABLE
+	}
+
+	var routes []netlink.Route
+	err := n.retry(func() error {
+		var err error
+		routes, err = n.Handle.RouteListFiltered(family, filter, filterMask)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, existing := range routes {
+		if sameDst(existing.Dst, route.To) && existing.Priority == route.priority() && !IsOwned(existing) {
+			return &existing, nil
+		}
+	}
+	return nil, nil
+}
+
+// replaceRoute installs the route in the given routing table, the IPv4 routes with a realm and the IPv6 routes
+// with a non default preference are installed with ip since the netlink library does not support them
+func (n *NICs) replaceRoute(link netlink.Link, table int, route Route) error {
+	ipv4 := route.To.IP.To4() != nil
+	if (ipv4 && route.Realm == 0) || (!ipv4 && route.pref() == "medium") {
+		nlRoute := &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       route.To,
+			Gw:        route.Via,
+			Src:       route.Src,
+			Priority:  route.Metric,
+			Protocol:  route.protocol(),
+			Table:     table,
+		}
+		if route.Via == nil {
+			nlRoute.Scope = netlink.SCOPE_LINK
+		}
+		err := n.routeReplace(link, nlRoute)
+		if err != nil {
+			return err
+		}
+		n.routeInstalled(table, route)
+		return nil
+	}
+
+	routeTable := "main"
+	if table != 0 {
+		routeTable = strconv.Itoa(table)
+	}
+	family, attr := "-6", []string{"pref", route.pref()}
+	if ipv4 {
+		family, attr = "-4", []string{"realm", strconv.Itoa(route.Realm)}
+	}
+	args := []string{family, "route", "replace", route.To.String()}
+	if route.Via != nil {
+		args = append(args, "via", route.Via.String())
+	} else {
+		args = append(args, "scope", "link")
+	}
+	args = append(args, "dev", link.Attrs().Name,
+		"metric", strconv.Itoa(route.Metric), "proto", strconv.Itoa(route.protocol()), "table", routeTable)
+	if route.Src != nil {
+		args = append(args, "src", route.Src.String())
+	}
+	args = append(args, attr...)
+	cmd := exec.Command("ip", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	n.audit("route replace", link.Attrs().Name, "", fmt.Sprintf("%s via %s %s %s", route.To, route.Via, attr[0], attr[1]), err)
+	if err != nil {
+		return err
+	}
+	n.routeInstalled(table, route)
+	return nil
+}
+
+// ConnectedRoutes returns the connected routes of the global addresses of the link with the given mac,
+// without gateway and with the address as preferred source, to install them in another routing table
+func (n *NICs) ConnectedRoutes(mac string) ([]Route, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := n.Handle.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []Route{}
+	for _, addr := range addrs {
+		if addr.Scope != int(netlink.SCOPE_UNIVERSE) || IsHostRoute(addr.IPNet) {
+			continue
+		}
+		ip := addr.IP
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		routes = append(routes, Route{
+			To:  &net.IPNet{IP: ip.Mask(addr.Mask), Mask: addr.Mask},
+			Src: ip,
+		})
+	}
+	return routes, nil
+}
+
+// routeInstalled records the preference or realm an installed route has
+func (n *NICs) routeInstalled(table int, route Route) {
+	if route.To.IP.To4() != nil {
+		n.routeRealms[route.key(table)] = route.Realm
+		return
+	}
+	n.routePrefs[route.key(table)] = route.pref()
+}
+
+// Rule is a policy routing rule
+type Rule struct {
+	// Priority orders the rule, lower first
+	Priority int
+	// Src and Dst are the source and destination matched by the rule, if any
+	Src *net.IPNet
+	Dst *net.IPNet
+}
+
+func ipNetEqual(a, b *net.IPNet) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.String() == b.String()
+}
+
+func (r Rule) matches(rule netlink.Rule) bool {
+	return rule.Priority == r.Priority && ipNetEqual(rule.Src, r.Src) && ipNetEqual(rule.Dst, r.Dst)
+}
+
+func (r Rule) family() int {
+	for _, ipnet := range []*net.IPNet{r.Src, r.Dst} {
+		if ipnet != nil && ipnet.IP.To4() == nil {
+			return netlink.FAMILY_V6
+		}
+	}
+	return netlink.FAMILY_V4
+}
+
+// diffRules returns the rules to add and the existing rules to delete for the table to hold the given rules
+func diffRules(existingRules []netlink.Rule, table int, rules []Rule) ([]Rule, []netlink.Rule) {
+	toDelete := []netlink.Rule{}
+	for _, existingRule := range existingRules {
+		if existingRule.Table != table {
+			continue
+		}
+		wanted := false
+		for _, rule := range rules {
+			if rule.matches(existingRule) {
+				wanted = true
+				break
+			}
+		}
+		if !wanted {
+			toDelete = append(toDelete, existingRule)
+		}
+	}
+
+	toAdd := []Rule{}
+	for _, rule := range rules {
+		found := false
+		for _, existingRule := range existingRules {
+			if existingRule.Table == table && rule.matches(existingRule) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			toAdd = append(toAdd, rule)
+		}
+	}
+	return toAdd, toDelete
+}
+
+// SyncRules syncs the policy routing rules looking up the given table
+func (n *NICs) SyncRules(table int, rules []Rule) error {
+	var existingRules []netlink.Rule
+	err := n.retry(func() error {
+		var err error
+		existingRules, err = n.Handle.RuleList(netlink.FAMILY_ALL)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	toAdd, toDelete := diffRules(existingRules, table, rules)
+	for _, rule := range toDelete {
+		err := n.ruleDel(&rule)
+		if err != nil {
+			return err
+		}
+	}
+	for _, rule := range toAdd {
+		nlRule := netlink.NewRule()
+		nlRule.Family = rule.family()
+		nlRule.Priority = rule.Priority
+		nlRule.Table = table
+		nlRule.Src = rule.Src
+		nlRule.Dst = rule.Dst
+		err := n.ruleAdd(nlRule)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TearDownRules removes the policy routing rules looking up the given table
+func (n *NICs) TearDownRules(table int) error {
+	return n.SyncRules(table, nil)
+}
+
+// RuleTables returns the tables looked up by the policy routing rules
+func (n *NICs) RuleTables() ([]int, error) {
+	rules, err := n.Handle.RuleList(netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	tables := []int{}
+	seen := make(map[int]bool)
+	for _, rule := range rules {
+		if !seen[rule.Table] {
+			seen[rule.Table] = true
+			tables = append(tables, rule.Table)
+		}
+	}
+	return tables, nil
+}
+
+// LinkState is the live state of a link, as read from netlink
+type LinkState struct {
+	Name         string       `json:"name"`
+	Index        int          `json:"index"`
+	HardwareAddr string       `json:"hardwareAddr"`
+	Up           bool         `json:"up"`
+	MTU          int          `json:"mtu"`
+	Addresses    []string     `json:"addresses"`
+	Routes       []RouteState `json:"routes"`
+}
+
+// RouteState is a live route of a link, in any routing table
+type RouteState struct {
+	To       string `json:"to"`
+	Via      string `json:"via,omitempty"`
+	Src      string `json:"src,omitempty"`
+	Metric   int    `json:"metric"`
+	Protocol int    `json:"protocol"`
+	Table    int    `json:"table"`
+}
+
+// GetLinkState returns the live state of the link with the given mac, with its addresses
+// and its routes in all the routing tables
+func (n *NICs) GetLinkState(mac string) (*LinkState, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &LinkState{
+		Name:         link.Attrs().Name,
+		Index:        link.Attrs().Index,
+		HardwareAddr: link.Attrs().HardwareAddr.String(),
+		Up:           link.Attrs().Flags&net.FlagUp != 0,
+		MTU:          link.Attrs().MTU,
+		Addresses:    []string{},
+		Routes:       []RouteState{},
+	}
+
+	addrs, err := n.Handle.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, addr.IPNet.String())
+	}
+
+	routes, err := n.Handle.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Table:     unix.RT_TABLE_UNSPEC,
+	}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		routeState := RouteState{
+			To:       "default",
+			Metric:   route.Priority,
+			Protocol: route.Protocol,
+			Table:    route.Table,
+		}
+		if !isDefault(route.Dst) {
+			routeState.To = route.Dst.String()
+		}
+		if route.Gw != nil {
+			routeState.Via = route.Gw.String()
+		}
+		if route.Src != nil {
+			routeState.Src = route.Src.String()
+		}
+		state.Routes = append(state.Routes, routeState)
+	}
+	return state, nil
+}
+
+// HasAnyAddress returns whether one of the given ips is an address of the link with the given mac
+func (n *NICs) HasAnyAddress(mac string, ips []net.IP) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+
+	addrs, err := n.Handle.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return false, err
+	}
+	for _, addr := range addrs {
+		for _, ip := range ips {
+			if addr.IP.Equal(ip) {
+				return true, nil
 			}
 		}
 	}
+	return false, nil
+}
+
+// IsOnLink returns whether the ip is in the subnet of an address of the link with the given mac
+func (n *NICs) IsOnLink(mac string, ip net.IP) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+
+	addrs, err := n.Handle.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return false, err
+	}
+	for _, addr := range addrs {
+		if addr.IPNet.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ProbeGateway returns whether the gateway answers a ping sent through the link with the given mac
+func (n *NICs) ProbeGateway(mac string, gw net.IP) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+
+	return ping("-c", "1", "-W", "1", "-I", link.Attrs().Name, gw.String())
+}
+
+// ProbeReachable returns whether the ip answers a ping sent through the routes of the node within the window,
+// from the src address if any so that the rules matching it are looked up
+func (n *NICs) ProbeReachable(src, ip net.IP, window time.Duration) (bool, error) {
+	seconds := int((window + time.Second - 1) / time.Second)
+	args := []string{"-c", "1", "-w", strconv.Itoa(seconds)}
+	if src != nil {
+		args = append(args, "-I", src.String())
+	}
+	return ping(append(args, ip.String())...)
+}
+
+// ping returns whether the ping command with the given arguments got a reply
+func ping(args ...string) (bool, error) {
+	cmd := exec.Command("ping", args...)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ProbeDuplicateAddress returns whether another host answers for the IPv4 ip on the link with the given mac,
+// sending count duplicate address detection probes with arping and waiting at most timeout for a reply
+// The link is brought up to send the probes
+func (n *NICs) ProbeDuplicateAddress(mac string, ip net.IP, count int, timeout time.Duration) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+
+	err = n.linkSetUp(link)
+	if err != nil {
+		return false, err
+	}
+
+	deadline := int(math.Ceil(timeout.Seconds()))
+	if deadline < 1 {
+		deadline = 1
+	}
+	// in duplicate address detection mode, arping exits with 1 when a reply is received
+	cmd := exec.Command("arping", "-D", "-q", "-c", strconv.Itoa(count), "-w", strconv.Itoa(deadline), "-I", link.Attrs().Name, ip.String())
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// GetDefaultRoute returns the node default route of the given family with the lowest metric not going through
+// the link with the given mac, or nil if there is none
+func (n *NICs) GetDefaultRoute(mac string, family int) (*DefaultRoute, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []netlink.Route
+	err = n.retry(func() error {
+		var err error
+		routes, err = n.Handle.RouteList(nil, family)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var defaultRoute *netlink.Route
+	for i, route := range routes {
+		if !isDefault(route.Dst) || route.LinkIndex == link.Attrs().Index {
+			continue
+		}
+		if defaultRoute == nil || route.Priority < defaultRoute.Priority {
+			defaultRoute = &routes[i]
+		}
+	}
+	if defaultRoute == nil {
+		return nil, nil
+	}
+	defaultLink, err := n.Handle.LinkByIndex(defaultRoute.LinkIndex)
+	if err != nil {
+		return nil, err
+	}
+	return &DefaultRoute{
+		Family:   family,
+		Via:      defaultRoute.Gw,
+		LinkName: defaultLink.Attrs().Name,
+		Metric:   defaultRoute.Priority,
+	}, nil
+}
+
+// HasDefaultRoute returns whether the link with the given mac holds a default route
+func (n *NICs) HasDefaultRoute(mac string) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	routes, err := n.Handle.RouteList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return false, err
+	}
+
+	for _, route := range routes {
+		if isDefault(route.Dst) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RestoreDefaultRoute adds back the given default route if the node does not have one anymore
+func (n *NICs) RestoreDefaultRoute(route DefaultRoute) error {
+	family := route.Family
+	if family != netlink.FAMILY_V4 && family != netlink.FAMILY_V6 {
+		return fmt.Errorf("default route via %s on %s has no address family", route.Via, route.LinkName)
+	}
+
+	var routes []netlink.Route
+	err := n.retry(func() error {
+		var err error
+		routes, err = n.Handle.RouteList(nil, family)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range routes {
+		if isDefault(r.Dst) {
+			return nil
+		}
+	}
+
+	link, err := n.Handle.LinkByName(route.LinkName)
+	if err != nil {
+		return err
+	}
+
+	dst := &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}
+	if family == netlink.FAMILY_V6 {
+		dst = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+	}
+	nlRoute := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       dst,
+		Gw:        route.Via,
+	}
+	if route.Via == nil {
+		nlRoute.Scope = netlink.SCOPE_LINK
+	}
+	return n.routeAdd(link, nlRoute)
+}
+
+// ingressQdisc returns the ingress qdisc of the link, nil when it has none
+func (n *NICs) ingressQdisc(link netlink.Link) (netlink.Qdisc, error) {
+	qdiscs, err := n.Handle.QdiscList(link)
+	if err != nil {
+		return nil, err
+	}
+	for _, qdisc := range qdiscs {
+		if _, ok := qdisc.(*netlink.Ingress); ok {
+			return qdisc, nil
+		}
+	}
+	return nil, nil
+}
+
+// ingressRateLimitFilters returns the filters of the ingress qdisc of the link holding the policer,
+// the ones with another priority being left to the other agents
+func (n *NICs) ingressRateLimitFilters(link netlink.Link) ([]netlink.Filter, []netlink.Filter, error) {
+	filters, err := n.Handle.FilterList(link, netlink.MakeHandle(ingressHandleMajor, 0))
+	if err != nil {
+		return nil, nil, err
+	}
+	owned := []netlink.Filter{}
+	others := []netlink.Filter{}
+	for _, filter := range filters {
+		if filter.Attrs().Priority == ingressFilterPriority {
+			owned = append(owned, filter)
+		} else {
+			others = append(others, filter)
+		}
+	}
+	return owned, others, nil
+}
+
+// SetIngressRateLimit polices the inbound traffic of the link with the given mac, and returns whether
+// the ingress qdisc was added for it, even on failure, so that RemoveIngressRateLimit can remove it
+// The ingress qdisc is managed with netlink, but the policer is added with tc since
+// the netlink library does not support police actions
+func (n *NICs) SetIngressRateLimit(mac string, limit RateLimit) (bool, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return false, err
+	}
+
+	ingress, err := n.ingressQdisc(link)
+	if err != nil {
+		return false, err
+	}
+	added := false
+	if ingress == nil {
+		ingress = &netlink.Ingress{
+			QdiscAttrs: netlink.QdiscAttrs{
+				LinkIndex: link.Attrs().Index,
+				Handle:    netlink.MakeHandle(ingressHandleMajor, 0),
+				Parent:    netlink.HANDLE_INGRESS,
+			},
+		}
+		err := n.qdiscAdd(link, ingress)
+		if err != nil {
+			return false, err
+		}
+		added = true
+	}
+
+	filters, _, err := n.ingressRateLimitFilters(link)
+	if err != nil {
+		return added, err
+	}
+	if applied, ok := n.ingressRateLimits[mac]; ok && applied == limit && len(filters) != 0 {
+		return added, nil
+	}
+
+	for _, filter := range filters {
+		err := n.filterDel(link, filter)
+		if err != nil {
+			return added, err
+		}
+	}
+
+	out, err := exec.Command("tc", "filter", "add", "dev", link.Attrs().Name, "parent", "ffff:", "protocol", "all",
+		"prio", strconv.Itoa(ingressFilterPriority),
+		"u32", "match", "u32", "0", "0",
+		"police", "rate", fmt.Sprintf("%dbit", limit.Rate), "burst", fmt.Sprintf("%db", limit.Burst), "drop").CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("tc failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	n.audit("filter add", link.Attrs().Name, "", fmt.Sprintf("police rate %dbit burst %db", limit.Rate, limit.Burst), err)
+	if err != nil {
+		return added, err
+	}
+
+	n.ingressRateLimits[mac] = limit
+	return added, nil
+}
+
+// RemoveIngressRateLimit removes the policer of the inbound traffic of the link with the given mac,
+// and its ingress qdisc when it was added by SetIngressRateLimit and holds no other filter
+func (n *NICs) RemoveIngressRateLimit(mac string, removeQdisc bool) error {
+	delete(n.ingressRateLimits, mac)
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+
+	ingress, err := n.ingressQdisc(link)
+	if err != nil || ingress == nil {
+		return err
+	}
+	filters, others, err := n.ingressRateLimitFilters(link)
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		err := n.filterDel(link, filter)
+		if err != nil {
+			return err
+		}
+	}
+	if !removeQdisc || len(others) != 0 {
+		return nil
+	}
+	return n.qdiscDel(link, ingress)
+}
+
+// GetLinkMTU returns the MTU of the link with the given mac
+func (n *NICs) GetLinkMTU(mac string) (int, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return 0, err
+	}
+	current, err := n.Handle.LinkByIndex(link.Attrs().Index)
+	if err != nil {
+		return 0, err
+	}
+	return current.Attrs().MTU, nil
+}
+
+// SetLinkMTU sets the MTU of the link with the given mac
+func (n *NICs) SetLinkMTU(mac string, mtu int) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+	current, err := n.Handle.LinkByIndex(link.Attrs().Index)
+	if err != nil {
+		return err
+	}
+	if current.Attrs().MTU == mtu {
+		return nil
+	}
+	return n.linkSetMTU(current, mtu)
+}
+
+// DeleteRoute removes the route of the link with the given mac to the destination and via the gateway
+// of the given route from the given table, whatever its metric, if present
+func (n *NICs) DeleteRoute(mac string, table int, route Route) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+	existingRoutes, err := n.routeList(link, table)
+	if err != nil {
+		return err
+	}
+	for _, existingRoute := range existingRoutes {
+		if IsOwned(existingRoute) && sameDst(existingRoute.Dst, route.To) && existingRoute.Gw.Equal(route.Via) {
+			return n.routeDel(link, &existingRoute)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,215 @@
+package nics
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("Route parsing", func() {
+	It("parses subnet routes", func() {
+		route, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.To.String()).To(Equal("10.0.0.0/16"))
+		Expect(route.Via.String()).To(Equal("192.168.0.1"))
+		Expect(IsHostRoute(route.To)).To(BeFalse())
+	})
+
+	It("parses host routes", func() {
+		for _, r := range [][3]string{
+			{"10.0.0.5/32", "192.168.0.1", "10.0.0.5/32"},
+			{"10.0.0.5", "192.168.0.1", "10.0.0.5/32"},
+			{"fd00::5/128", "fd00:1::1", "fd00::5/128"},
+			{"fd00::5", "fd00:1::1", "fd00::5/128"},
+		} {
+			route, err := ParseRoute(r[0], r[1])
+			Expect(err).NotTo(HaveOccurred(), r[0])
+			Expect(route.To.String()).To(Equal(r[2]), r[0])
+			Expect(IsHostRoute(route.To)).To(BeTrue(), r[0])
+		}
+	})
+
+	It("rejects invalid routes", func() {
+		for _, r := range [][2]string{
+			{"10.0.0.5/24", "192.168.0.1"},
+			{"10.0.0.0/33", "192.168.0.1"},
+			{"10.0.0.5/32", "fd00::1"},
+			{"fd00::5/128", "192.168.0.1"},
+			{"10.0.0.5/32", "10.0.0.5"},
+			{"10.0.0.0/16", "gateway"},
+			{"", "192.168.0.1"},
+		} {
+			_, err := ParseRoute(r[0], r[1])
+			Expect(err).To(HaveOccurred(), r[0]+" via "+r[1])
+		}
+	})
+
+	It("matches the host routes returned by the kernel", func() {
+		route, err := ParseRoute("10.0.0.5", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+
+		kernelRoute := netlink.Route{
+			Dst:      &net.IPNet{IP: net.IPv4(10, 0, 0, 5).To4(), Mask: net.CIDRMask(32, 32)},
+			Gw:       net.IPv4(192, 168, 0, 1),
+			Protocol: RouteProtocol,
+		}
+		Expect(route.isIn([]netlink.Route{kernelRoute})).To(BeTrue())
+		Expect(isIn(kernelRoute, []Route{route})).To(BeTrue())
+
+		otherRoute := netlink.Route{
+			Dst:      &net.IPNet{IP: net.IPv4(10, 0, 0, 6).To4(), Mask: net.CIDRMask(32, 32)},
+			Gw:       net.IPv4(192, 168, 0, 1),
+			Protocol: RouteProtocol,
+		}
+		Expect(route.isIn([]netlink.Route{otherRoute})).To(BeFalse())
+		Expect(isIn(otherRoute, []Route{route})).To(BeFalse())
+	})
+})
+
+var _ = Describe("Stale routes", func() {
+	mustParseCIDR := func(cidr string) *net.IPNet {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		Expect(err).NotTo(HaveOccurred())
+		return ipnet
+	}
+
+	It("only removes the routes installed by the node daemon", func() {
+		kept, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+
+		existingRoutes := []netlink.Route{
+			// connected routes of the addresses of the link
+			{Dst: mustParseCIDR("192.168.0.0/24"), Src: net.IPv4(192, 168, 0, 10).To4(), Protocol: unix.RTPROT_KERNEL, Scope: netlink.SCOPE_LINK},
+			{Dst: mustParseCIDR("fe80::/64"), Protocol: unix.RTPROT_KERNEL, Priority: 256},
+			{Dst: mustParseCIDR("fd00::/64"), Protocol: unix.RTPROT_KERNEL, Priority: 256},
+			{Dst: mustParseCIDR("ff00::/8"), Protocol: unix.RTPROT_KERNEL, Priority: 256},
+			// router advertisement, static and hand-made routes
+			{Dst: mustParseCIDR("fd01::/64"), Gw: net.ParseIP("fe80::1"), Protocol: unix.RTPROT_RA, Priority: 1024},
+			{Dst: mustParseCIDR("10.1.0.0/16"), Gw: net.IPv4(192, 168, 0, 2).To4(), Protocol: unix.RTPROT_STATIC},
+			{Dst: mustParseCIDR("10.2.0.0/16"), Gw: net.IPv4(192, 168, 0, 2).To4(), Protocol: unix.RTPROT_BOOT},
+			// route of another routing daemon
+			{Dst: mustParseCIDR("10.5.0.0/16"), Gw: net.IPv4(192, 168, 0, 3).To4(), Protocol: unix.RTPROT_BGP},
+			// routes of the node daemon
+			{Dst: mustParseCIDR("10.0.0.0/16"), Gw: net.IPv4(192, 168, 0, 1).To4(), Protocol: RouteProtocol},
+			{Dst: mustParseCIDR("10.3.0.0/16"), Gw: net.IPv4(192, 168, 0, 1).To4(), Protocol: RouteProtocol},
+			{Dst: mustParseCIDR("10.4.0.0/16"), Gw: net.IPv4(192, 168, 0, 3).To4(), Protocol: BGPRouteProtocol},
+		}
+
+		stale := staleRoutes(existingRoutes, []Route{kept})
+		Expect(stale).To(HaveLen(2))
+		Expect(stale[0].Dst.String()).To(Equal("10.3.0.0/16"))
+		Expect(stale[1].Dst.String()).To(Equal("10.4.0.0/16"))
+
+		Expect(staleRoutes(existingRoutes[:8], nil)).To(BeEmpty())
+	})
+
+	It("installs the routes with the node daemon protocol by default", func() {
+		route, err := ParseRoute("10.0.0.0/16", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.protocol()).To(Equal(RouteProtocol))
+
+		route.Protocol = BGPRouteProtocol
+		Expect(route.protocol()).To(Equal(BGPRouteProtocol))
+	})
+})
+
+var _ = Describe("Rules diff", func() {
+	mustParseCIDR := func(cidr string) *net.IPNet {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		Expect(err).NotTo(HaveOccurred())
+		return ipnet
+	}
+	installed := func(table int, rules []Rule) []netlink.Rule {
+		nlRules := []netlink.Rule{}
+		for _, rule := range rules {
+			nlRule := netlink.NewRule()
+			nlRule.Table = table
+			nlRule.Priority = rule.Priority
+			nlRule.Src = rule.Src
+			nlRule.Dst = rule.Dst
+			nlRules = append(nlRules, *nlRule)
+		}
+		return nlRules
+	}
+
+	rules := []Rule{
+		{Priority: 100, Src: mustParseCIDR("192.168.0.0/24")},
+		{Priority: 200, Dst: mustParseCIDR("10.0.0.0/8")},
+	}
+
+	It("adds all the rules of an empty table", func() {
+		toAdd, toDelete := diffRules(nil, 100, rules)
+		Expect(toAdd).To(Equal(rules))
+		Expect(toDelete).To(BeEmpty())
+	})
+
+	It("removes all the rules added for a table", func() {
+		toAdd, toDelete := diffRules(installed(100, rules), 100, nil)
+		Expect(toAdd).To(BeEmpty())
+		Expect(toDelete).To(HaveLen(len(rules)))
+	})
+
+	It("does nothing once the rules are added", func() {
+		toAdd, toDelete := diffRules(installed(100, rules), 100, rules)
+		Expect(toAdd).To(BeEmpty())
+		Expect(toDelete).To(BeEmpty())
+	})
+
+	It("only touches the rules of the table", func() {
+		existing := append(installed(100, rules), installed(254, []Rule{{Priority: 32766}})...)
+		existing = append(existing, installed(200, rules)...)
+		toAdd, toDelete := diffRules(existing, 100, nil)
+		Expect(toAdd).To(BeEmpty())
+		Expect(toDelete).To(HaveLen(len(rules)))
+		for _, rule := range toDelete {
+			Expect(rule.Table).To(Equal(100))
+		}
+	})
+
+	It("replaces the rules whose priority changed", func() {
+		changed := []Rule{
+			{Priority: 150, Src: mustParseCIDR("192.168.0.0/24")},
+			rules[1],
+		}
+		toAdd, toDelete := diffRules(installed(100, rules), 100, changed)
+		Expect(toAdd).To(Equal(changed[:1]))
+		Expect(toDelete).To(HaveLen(1))
+		Expect(toDelete[0].Priority).To(Equal(100))
+	})
+})
+
+var _ = Describe("Default route metric", func() {
+	It("raises the metric of a default route not above the node default route", func() {
+		route, err := ParseRoute("0.0.0.0/0", "192.168.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.AboveDefaultRoute(&DefaultRoute{Metric: 100}, 100).Metric).To(Equal(200))
+		Expect(route.AboveDefaultRoute(nil, 100).Metric).To(Equal(0))
+
+		route.Metric = 300
+		Expect(route.AboveDefaultRoute(&DefaultRoute{Metric: 100}, 100).Metric).To(Equal(300))
+	})
+
+	It("compares the IPv6 routes with the metric given by the kernel", func() {
+		route, err := ParseRoute("::/0", "fd00::1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(route.AboveDefaultRoute(&DefaultRoute{Metric: 1024}, 100).Metric).To(Equal(1124))
+		Expect(route.AboveDefaultRoute(&DefaultRoute{Metric: 100}, 100).Metric).To(Equal(0))
+	})
+})
+
+var _ = Describe("MAC address normalization", func() {
+	It("normalizes the case and separators of mac addresses", func() {
+		Expect(NormalizeMAC("02:00:00:AB:cd:01")).To(Equal("02:00:00:ab:cd:01"))
+		Expect(NormalizeMAC("02-00-00-AB-CD-01")).To(Equal("02:00:00:ab:cd:01"))
+		Expect(NormalizeMAC("0200.00ab.cd01")).To(Equal("02:00:00:ab:cd:01"))
+		Expect(NormalizeMAC("NOT-A-MAC")).To(Equal("not-a-mac"))
+	})
+
+	It("compares mac addresses regardless of their format", func() {
+		Expect(MACEqual("02:00:00:AB:CD:01", "02-00-00-ab-cd-01")).To(BeTrue())
+		Expect(MACEqual("02:00:00:ab:cd:01", "02:00:00:ab:cd:02")).To(BeFalse())
+	})
+})
@@ -0,0 +1,13 @@
+package nics
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestNICs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "NICs Suite")
+}
@@ -0,0 +1,279 @@
+package nics
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultIPv4ConfDir is the directory holding the IPv4 sysctls of the links
+	DefaultIPv4ConfDir = "/proc/sys/net/ipv4/conf"
+	// DefaultIPv6ConfDir is the directory holding the IPv6 sysctls of the links
+	DefaultIPv6ConfDir = "/proc/sys/net/ipv6/conf"
+	// DefaultIPv4NeighDir is the directory holding the IPv4 neighbor table parameters of the links
+	DefaultIPv4NeighDir = "/proc/sys/net/ipv4/neigh"
+	// DefaultIPv6NeighDir is the directory holding the IPv6 neighbor table parameters of the links
+	DefaultIPv6NeighDir = "/proc/sys/net/ipv6/neigh"
+)
+
+// AcceptRA is how the IPv6 router advertisements received on a link are handled
+type AcceptRA struct {
+	// Accept processes the router advertisements, even when forwarding is enabled
+	Accept bool
+	// Autoconf configures addresses from the prefixes of the router advertisements
+	Autoconf bool
+	// DefaultRoute learns the default route from the router advertisements
+	DefaultRoute bool
+}
+
+// sysctls returns the accept_ra, autoconf and accept_ra_defrtr sysctl values of the setting
+func (ra AcceptRA) sysctls() (string, string, string) {
+	acceptRA, autoconf, defrtr := "0", "0", "0"
+	if ra.Accept {
+		// 1 ignores the router advertisements when forwarding is enabled, as on most nodes
+		acceptRA = "2"
+	}
+	if ra.Autoconf {
+		autoconf = "1"
+	}
+	if ra.DefaultRoute {
+		defrtr = "1"
+	}
+	return acceptRA, autoconf, defrtr
+}
+
+func readSysctl(confDir, linkName, name string) (string, error) {
+	value, err := ioutil.ReadFile(filepath.Join(confDir, linkName, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// writeSysctl writes the sysctl of the link in the given directory if it differs
+func (n *NICs) writeSysctl(confDir, linkName, name, value string) error {
+	before, err := readSysctl(confDir, linkName, name)
+	if err != nil {
+		return err
+	}
+	if before == value {
+		return nil
+	}
+	err = ioutil.WriteFile(filepath.Join(confDir, linkName, name), []byte(value), 0644)
+	n.audit("sysctl "+name, linkName, before, value, err)
+	return err
+}
+
+// SetAcceptRA sets how the link with the given mac handles the IPv6 router advertisements,
+// removing the autoconfigured addresses when autoconf is disabled and the learned default routes when
+// the default route is not accepted
+func (n *NICs) SetAcceptRA(mac string, ra AcceptRA) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+	acceptRA, autoconf, defrtr := ra.sysctls()
+	for _, sysctl := range [][2]string{{"accept_ra", acceptRA}, {"autoconf", autoconf}, {"accept_ra_defrtr", defrtr}} {
+		err = n.writeSysctl(n.ipv6ConfDir, link.Attrs().Name, sysctl[0], sysctl[1])
+		if err != nil {
+			return err
+		}
+	}
+	if !ra.DefaultRoute {
+		// the kernel keeps the default routes already learned until they expire
+		err = n.flushRADefaultRoutes(link)
+		if err != nil {
+			return err
+		}
+	}
+	if ra.Autoconf {
+		return nil
+	}
+	return n.flushAutoconfAddresses(link)
+}
+
+// RestoreAcceptRA sets back the node defaults for the IPv6 router advertisements on the link with the given mac,
+// the ones a new link gets
+func (n *NICs) RestoreAcceptRA(mac string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+	return n.restoreSysctls(n.ipv6ConfDir, link.Attrs().Name, "accept_ra", "autoconf", "accept_ra_defrtr")
+}
+
+// restoreSysctls sets back the given sysctls of the link in the given directory to the node defaults
+func (n *NICs) restoreSysctls(confDir, linkName string, names ...string) error {
+	for _, name := range names {
+		value, err := readSysctl(confDir, "default", name)
+		if err != nil {
+			return err
+		}
+		err = n.writeSysctl(confDir, linkName, name, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetLocalRouting sets the accept_local and route_localnet sysctls of the link with the given mac,
+// a false value setting back the node default
+func (n *NICs) SetLocalRouting(mac string, acceptLocal, routeLocalnet bool) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if !acceptLocal && !routeLocalnet && errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+	for name, enabled := range map[string]bool{"accept_local": acceptLocal, "route_localnet": routeLocalnet} {
+		if enabled {
+			err = n.writeSysctl(n.ipv4ConfDir, link.Attrs().Name, name, "1")
+		} else {
+			err = n.restoreSysctls(n.ipv4ConfDir, link.Attrs().Name, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isAutoconfAddress returns whether the address is a global IPv6 address with a lifetime,
+// as configured from the router advertisements
+func isAutoconfAddress(addr netlink.Addr) bool {
+	return addr.IP.To4() == nil && addr.Scope == unix.RT_SCOPE_UNIVERSE && addr.Flags&unix.IFA_F_PERMANENT == 0
+}
+
+// flushAutoconfAddresses removes the IPv6 addresses configured from the router advertisements on the link
+func (n *NICs) flushAutoconfAddresses(link netlink.Link) error {
+	addrs, err := n.Handle.AddrList(link, netlink.FAMILY_V6)
+	if err != nil {
+		return err
+	}
+	for i := range addrs {
+		if !isAutoconfAddress(addrs[i]) {
+			continue
+		}
+		err = n.addrDel(link, &addrs[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushRADefaultRoutes removes the IPv6 default routes learned from the router advertisements on the link
+func (n *NICs) flushRADefaultRoutes(link netlink.Link) error {
+	routes, err := n.Handle.RouteList(link, netlink.FAMILY_V6)
+	if err != nil {
+		return err
+	}
+	for i := range routes {
+		if routes[i].Protocol != unix.RTPROT_RA || !isDefault(routes[i].Dst) {
+			continue
+		}
+		err = n.routeDel(link, &routes[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// neighborSysctls are the sysctls of the NeighborSettings, in the order of their values
+var neighborSysctls = []string{"base_reachable_time_ms", "gc_stale_time", "mcast_solicit"}
+
+// NeighborSettings are the neighbor table parameters of a link, the zero ones being the node defaults
+type NeighborSettings struct {
+	// BaseReachableTime is the base time a neighbor is considered reachable after its reachability is confirmed
+	BaseReachableTime time.Duration
+	// GCStaleTime is the time after which an unused stale neighbor entry can be removed
+	GCStaleTime time.Duration
+	// MCastSolicit is the number of multicast solicitations sent to resolve a neighbor
+	MCastSolicit int
+}
+
+// sysctls returns the values of the neighborSysctls of the settings, empty for the zero ones
+func (s NeighborSettings) sysctls() []string {
+	values := make([]string, len(neighborSysctls))
+	if s.BaseReachableTime != 0 {
+		values[0] = strconv.FormatInt(s.BaseReachableTime.Milliseconds(), 10)
+	}
+	if s.GCStaleTime != 0 {
+		values[1] = strconv.FormatInt(int64(s.GCStaleTime/time.Second), 10)
+	}
+	if s.MCastSolicit != 0 {
+		values[2] = strconv.Itoa(s.MCastSolicit)
+	}
+	return values
+}
+
+// SetNeighborSettings sets the neighbor table parameters of the link with the given mac, for IPv4 and for IPv6
+// when it is enabled on the link, setting back the node defaults for the zero ones. It returns the effective
+// IPv4 settings
+func (n *NICs) SetNeighborSettings(mac string, settings NeighborSettings) (NeighborSettings, error) {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return NeighborSettings{}, err
+	}
+	linkName := link.Attrs().Name
+
+	values := settings.sysctls()
+	for _, dir := range []string{n.ipv4NeighDir, n.ipv6NeighDir} {
+		_, err = os.Stat(filepath.Join(dir, linkName))
+		if dir == n.ipv6NeighDir && os.IsNotExist(err) {
+			// IPv6 is disabled on the link
+			continue
+		}
+		for i, name := range neighborSysctls {
+			if values[i] == "" {
+				err = n.restoreSysctls(dir, linkName, name)
+			} else {
+				err = n.writeSysctl(dir, linkName, name, values[i])
+			}
+			if err != nil {
+				return NeighborSettings{}, err
+			}
+		}
+	}
+	return readNeighborSettings(n.ipv4NeighDir, linkName)
+}
+
+// RestoreNeighborSettings sets back the node defaults for the neighbor table parameters of the link with the given mac
+func (n *NICs) RestoreNeighborSettings(mac string) error {
+	_, err := n.SetNeighborSettings(mac, NeighborSettings{})
+	if errors.Is(err, nicNotFoundErr) {
+		return nil
+	}
+	return err
+}
+
+// readNeighborSettings reads the neighbor table parameters of the link in the given directory
+func readNeighborSettings(dir, linkName string) (NeighborSettings, error) {
+	values := make([]int, len(neighborSysctls))
+	for i, name := range neighborSysctls {
+		value, err := readSysctl(dir, linkName, name)
+		if err != nil {
+			return NeighborSettings{}, err
+		}
+		values[i], err = strconv.Atoi(value)
+		if err != nil {
+			return NeighborSettings{}, err
+		}
+	}
+	return NeighborSettings{
+		BaseReachableTime: time.Duration(values[0]) * time.Millisecond,
+		GCStaleTime:       time.Duration(values[1]) * time.Second,
+		MCastSolicit:      values[2],
+	}, nil
+}
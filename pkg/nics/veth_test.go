@@ -0,0 +1,287 @@
+package nics
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("Link lifecycle on a veth pair", func() {
+	const (
+		mac         = "02:00:00:00:00:02"
+		address     = "192.168.1.10/24"
+		ipv6Address = "fd00:1::10/64"
+		table       = 100
+	)
+
+	var (
+		testNS *testNamespace
+		nics   *NICs
+		link   netlink.Link
+		peer   netlink.Link
+	)
+
+	BeforeEach(func() {
+		testNS = newTestNamespace()
+		nics = testNS.nics()
+		link, peer = testNS.addVeth("pn0", "pn0-peer", mac)
+	})
+
+	AfterEach(func() {
+		testNS.close()
+		testNS = nil
+	})
+
+	// routeDsts returns the destinations of the routes of the link in the given table, except the link-local ones
+	routeDsts := func(table int) []string {
+		routes, err := testNS.handle.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Table:     table,
+		}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+		Expect(err).NotTo(HaveOccurred())
+		dsts := []string{}
+		for _, route := range routes {
+			if route.Dst != nil && route.Dst.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			dsts = append(dsts, route.Dst.String())
+		}
+		return dsts
+	}
+
+	It("configures, routes and tears down a dual-stack link", func() {
+		Expect(testNS.handle.LinkSetUp(peer)).To(Succeed())
+		Expect(nics.ConfigureStaticLink(mac, nil, address, ipv6Address)).To(Succeed())
+		addrs, err := testNS.handle.AddrList(link, netlink.FAMILY_ALL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hasAddr(addrs, &net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)})).To(BeTrue())
+		Expect(hasAddr(addrs, &net.IPNet{IP: net.ParseIP("fd00:1::10"), Mask: net.CIDRMask(64, 128)})).To(BeTrue())
+
+		route, err := ParseRoute("10.0.0.0/16", "192.168.1.1")
+		Expect(err).NotTo(HaveOccurred())
+		ipv6Route, err := ParseRoute("fd01::/64", "fd00:1::1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nics.SyncRoutes(mac, 0, []Route{route})).To(Succeed())
+		Expect(nics.SyncRoutes(mac, table, []Route{route, ipv6Route})).To(Succeed())
+		Expect(routeDsts(unix.RT_TABLE_MAIN)).To(ContainElement("10.0.0.0/16"))
+		Expect(routeDsts(table)).To(ConsistOf("10.0.0.0/16", "fd01::/64"))
+
+		_, src, err := net.ParseCIDR("192.168.1.0/24")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nics.SyncRules(table, []Rule{{Priority: 1000, Src: src}})).To(Succeed())
+		rules, err := testNS.handle.RuleList(netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		toAdd, _ := diffRules(rules, table, []Rule{{Priority: 1000, Src: src}})
+		Expect(toAdd).To(BeEmpty())
+
+		// a route added by another tool, with its own protocol
+		_, foreignDst, err := net.ParseCIDR("10.2.0.0/16")
+		Expect(err).NotTo(HaveOccurred())
+		for _, foreignTable := range []int{unix.RT_TABLE_MAIN, table} {
+			Expect(testNS.handle.RouteAdd(&netlink.Route{
+				LinkIndex: link.Attrs().Index,
+				Dst:       foreignDst,
+				Scope:     netlink.SCOPE_LINK,
+				Protocol:  unix.RTPROT_STATIC,
+				Table:     foreignTable,
+			})).To(Succeed())
+		}
+
+		Expect(nics.TearDownRules(table)).To(Succeed())
+		Expect(nics.FlushRoutes(mac, table)).To(Succeed())
+		Expect(routeDsts(table)).To(ConsistOf("10.2.0.0/16"))
+		Expect(nics.FlushStaticLink(mac, address, ipv6Address)).To(Succeed())
+		Expect(routeDsts(unix.RT_TABLE_MAIN)).To(ConsistOf("10.2.0.0/16"))
+		Expect(nics.TearDownStaticLink(mac, address, ipv6Address)).To(Succeed())
+
+		Expect(routeDsts(unix.RT_TABLE_MAIN)).NotTo(ContainElement("10.0.0.0/16"))
+		Expect(routeDsts(table)).NotTo(ContainElement("fd01::/64"))
+		addrs, err = testNS.handle.AddrList(link, netlink.FAMILY_ALL)
+		Expect(err).NotTo(HaveOccurred())
+		for _, addr := range addrs {
+			Expect(addr.IP.IsLinkLocalUnicast()).To(BeTrue())
+		}
+		rules, err = testNS.handle.RuleList(netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		for _, rule := range rules {
+			Expect(rule.Table).NotTo(Equal(table))
+		}
+	})
+
+	It("only reports the link up once its peer has a carrier", func() {
+		Expect(nics.SetLinkUp(mac)).To(Succeed())
+		up, carrier, err := nics.WaitLinkUp(mac, time.Millisecond*200)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(up).To(BeFalse())
+		Expect(carrier).To(BeFalse())
+
+		Expect(testNS.handle.LinkSetUp(peer)).To(Succeed())
+		up, carrier, err = nics.WaitLinkUp(mac, time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(up).To(BeTrue())
+		Expect(carrier).To(BeTrue())
+	})
+	It("keeps the node default route preferred", func() {
+		primary, primaryPeer := testNS.addVeth("eth0", "eth0-peer", "02:00:00:00:00:01")
+		Expect(testNS.handle.LinkSetUp(primaryPeer)).To(Succeed())
+		Expect(testNS.handle.LinkSetUp(primary)).To(Succeed())
+		primaryAddr, err := netlink.ParseAddr("10.1.0.10/24")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(testNS.handle.AddrAdd(primary, primaryAddr)).To(Succeed())
+		Expect(testNS.handle.RouteAdd(&netlink.Route{
+			LinkIndex: primary.Attrs().Index,
+			Gw:        net.ParseIP("10.1.0.1"),
+			Priority:  100,
+		})).To(Succeed())
+
+		Expect(testNS.handle.LinkSetUp(peer)).To(Succeed())
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		nodeDefaultRoute, err := nics.GetDefaultRoute(mac, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nodeDefaultRoute.LinkName).To(Equal("eth0"))
+		Expect(nodeDefaultRoute.Metric).To(Equal(100))
+
+		route, err := ParseRoute("0.0.0.0/0", "192.168.1.1")
+		Expect(err).NotTo(HaveOccurred())
+		route = route.AboveDefaultRoute(nodeDefaultRoute, 100)
+		Expect(route.Metric).To(Equal(200))
+		Expect(nics.SyncRoutes(mac, 0, []Route{route})).To(Succeed())
+
+		routes, err := testNS.handle.RouteGet(net.ParseIP("1.1.1.1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).NotTo(BeEmpty())
+		Expect(routes[0].LinkIndex).To(Equal(primary.Attrs().Index))
+		nodeDefaultRoute, err = nics.GetDefaultRoute(mac, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nodeDefaultRoute.Metric).To(Equal(100))
+
+		// netlink lists the default route without destination, it is still seen as installed
+		results, err := nics.ApplyRoutes(mac, 0, []Route{route})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Applied).To(BeFalse())
+		Expect(nics.DeleteRoute(mac, 0, route)).To(Succeed())
+		routes, err = testNS.handle.RouteList(link, netlink.FAMILY_V4)
+		Expect(err).NotTo(HaveOccurred())
+		for _, existing := range routes {
+			Expect(existing.Dst).NotTo(BeNil())
+		}
+	})
+	It("restores the mac address of the link after a restart", func() {
+		const linkMAC = "02:00:00:00:00:aa"
+		Expect(nics.SetLinkMAC(mac, linkMAC)).To(Succeed())
+		current, err := nics.GetLinkMAC(mac)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current).To(Equal(linkMAC))
+
+		// the daemon restarted, the overriding mac address comes from the status
+		restarted := testNS.nics()
+		_, err = restarted.GetLinkMAC(mac)
+		Expect(IsNotFound(err)).To(BeTrue())
+		restarted.TrackLinkMAC(mac, current)
+		Expect(restarted.RestoreLinkMAC(mac)).To(Succeed())
+
+		restored, err := testNS.handle.LinkByIndex(link.Attrs().Index)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restored.Attrs().HardwareAddr.String()).To(Equal(mac))
+	})
+	It("restores a default route without gateway in its family", func() {
+		Expect(testNS.handle.LinkSetUp(peer)).To(Succeed())
+		Expect(nics.ConfigureStaticLink(mac, nil, ipv6Address)).To(Succeed())
+		Expect(nics.RestoreDefaultRoute(DefaultRoute{Family: netlink.FAMILY_V6, LinkName: "pn0"})).To(Succeed())
+
+		hasDefaultRoute := func(family int) bool {
+			routes, err := testNS.handle.RouteList(link, family)
+			Expect(err).NotTo(HaveOccurred())
+			for _, route := range routes {
+				if isDefault(route.Dst) {
+					return true
+				}
+			}
+			return false
+		}
+		Expect(hasDefaultRoute(netlink.FAMILY_V6)).To(BeTrue())
+		Expect(hasDefaultRoute(netlink.FAMILY_V4)).To(BeFalse())
+	})
+	It("only removes the ingress qdisc it added with the rate limit", func() {
+		hasIngressQdisc := func() bool {
+			qdiscs, err := testNS.handle.QdiscList(link)
+			Expect(err).NotTo(HaveOccurred())
+			for _, qdisc := range qdiscs {
+				if _, ok := qdisc.(*netlink.Ingress); ok {
+					return true
+				}
+			}
+			return false
+		}
+		limit := RateLimit{Rate: 1000000, Burst: 10000}
+
+		added, err := nics.SetIngressRateLimit(mac, limit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(added).To(BeTrue())
+		Expect(nics.RemoveIngressRateLimit(mac, added)).To(Succeed())
+		Expect(hasIngressQdisc()).To(BeFalse())
+
+		// the ingress qdisc of another agent
+		Expect(testNS.handle.QdiscAdd(&netlink.Ingress{
+			QdiscAttrs: netlink.QdiscAttrs{
+				LinkIndex: link.Attrs().Index,
+				Handle:    netlink.MakeHandle(0xffff, 0),
+				Parent:    netlink.HANDLE_INGRESS,
+			},
+		})).To(Succeed())
+		added, err = nics.SetIngressRateLimit(mac, limit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(added).To(BeFalse())
+		Expect(nics.RemoveIngressRateLimit(mac, added)).To(Succeed())
+		Expect(hasIngressQdisc()).To(BeTrue())
+		filters, err := testNS.handle.FilterList(link, netlink.MakeHandle(0xffff, 0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filters).To(BeEmpty())
+	})
+	It("keeps the gateway route of a DHCP lease in a route table until it is deleted", func() {
+		Expect(testNS.handle.LinkSetUp(peer)).To(Succeed())
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+
+		route, err := ParseRoute("0.0.0.0/0", "192.168.1.1")
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 2; i++ {
+			results, err := nics.ApplyRoutes(mac, table, []Route{route})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Applied).To(Equal(i == 0))
+		}
+		Expect(routeDsts(table)).To(HaveLen(1))
+
+		Expect(nics.DeleteRoute(mac, table, route)).To(Succeed())
+		Expect(routeDsts(table)).To(BeEmpty())
+	})
+	It("only prunes the routes of its own link in a shared route table", func() {
+		const otherMAC = "02:00:00:00:00:03"
+		other, otherPeer := testNS.addVeth("pn1", "pn1-peer", otherMAC)
+		Expect(testNS.handle.LinkSetUp(peer)).To(Succeed())
+		Expect(testNS.handle.LinkSetUp(otherPeer)).To(Succeed())
+		Expect(nics.ConfigureStaticLink(mac, nil, address)).To(Succeed())
+		Expect(nics.ConfigureStaticLink(otherMAC, nil, "192.168.2.10/24")).To(Succeed())
+
+		route, err := ParseRoute("10.0.0.0/16", "192.168.1.1")
+		Expect(err).NotTo(HaveOccurred())
+		otherRoute, err := ParseRoute("10.1.0.0/16", "192.168.2.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nics.SyncRoutes(mac, table, []Route{route})).To(Succeed())
+		Expect(nics.SyncRoutes(otherMAC, table, []Route{otherRoute})).To(Succeed())
+
+		Expect(nics.SyncRoutes(otherMAC, table, nil)).To(Succeed())
+		Expect(routeDsts(table)).To(ConsistOf("10.0.0.0/16"))
+		routes, err := testNS.handle.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+			LinkIndex: other.Attrs().Index,
+			Table:     table,
+		}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(routes).To(BeEmpty())
+	})
+})
@@ -0,0 +1,114 @@
+package nics
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+// vrfAlias is the alias of the VRF devices created by the node daemon, the only ones it ever deletes
+const vrfAlias = "scaleway-k8s-vpc"
+
+// SetLinkVRF enslaves the link with the given mac to the VRF device with the given name, creating
+// the VRF with the given routing table when it does not exist. An existing VRF needs to use this table
+func (n *NICs) SetLinkVRF(mac string, name string, table int) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		return err
+	}
+
+	vrf, err := n.ensureVRF(name, table)
+	if err != nil {
+		return err
+	}
+	if link.Attrs().MasterIndex == vrf.Attrs().Index {
+		return nil
+	}
+	return n.linkSetMaster(link, vrf)
+}
+
+// RemoveLinkVRF removes the link with the given mac from the VRF device it is enslaved to, if any
+func (n *NICs) RemoveLinkVRF(mac string) error {
+	link, err := n.getLink(mac)
+	if err != nil {
+		if errors.Is(err, nicNotFoundErr) {
+			return nil
+		}
+		return err
+	}
+	if link.Attrs().MasterIndex == 0 {
+		return nil
+	}
+
+	master, err := n.Handle.LinkByIndex(link.Attrs().MasterIndex)
+	if err != nil {
+		return err
+	}
+	if _, ok := master.(*netlink.Vrf); !ok {
+		return nil
+	}
+	return n.linkSetNoMaster(link, master)
+}
+
+// DeleteVRF deletes the VRF device with the given name when it was created by the node daemon
+// and no link is enslaved to it anymore
+func (n *NICs) DeleteVRF(name string) error {
+	vrf, err := n.Handle.LinkByName(name)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+		return err
+	}
+	if _, ok := vrf.(*netlink.Vrf); !ok || vrf.Attrs().Alias != vrfAlias {
+		return nil
+	}
+
+	links, err := n.Handle.LinkList()
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if link.Attrs().MasterIndex == vrf.Attrs().Index {
+			return nil
+		}
+	}
+	return n.linkDel(vrf)
+}
+
+// ensureVRF returns the VRF device with the given name, creating it up with the given routing table
+// when it does not exist
+func (n *NICs) ensureVRF(name string, table int) (netlink.Link, error) {
+	link, err := n.Handle.LinkByName(name)
+	if err == nil {
+		vrf, ok := link.(*netlink.Vrf)
+		if !ok {
+			return nil, fmt.Errorf("link %s is not a VRF", name)
+		}
+		if int(vrf.Table) != table {
+			return nil, fmt.Errorf("VRF %s uses routing table %d, not %d", name, vrf.Table, table)
+		}
+		return vrf, n.linkSetUp(vrf)
+	}
+	if _, ok := err.(netlink.LinkNotFoundError); !ok {
+		return nil, err
+	}
+
+	err = n.linkAdd(&netlink.Vrf{
+		LinkAttrs: netlink.LinkAttrs{Name: name},
+		Table:     uint32(table),
+	})
+	if err != nil {
+		return nil, err
+	}
+	link, err = n.Handle.LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+	err = n.linkSetAlias(link, vrfAlias)
+	if err != nil {
+		return nil, err
+	}
+	return link, n.linkSetUp(link)
+}
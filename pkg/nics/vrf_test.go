@@ -0,0 +1,73 @@
+package nics
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("VRF", func() {
+	const mac = "02:00:00:00:00:01"
+
+	var (
+		testNS *testNamespace
+		handle *netlink.Handle
+		nics   *NICs
+		link   netlink.Link
+	)
+
+	BeforeEach(func() {
+		testNS = newTestNamespace()
+		handle = testNS.handle
+		nics = testNS.nics()
+		link = testNS.addDummy("pn0", mac)
+	})
+
+	AfterEach(func() {
+		testNS.close()
+		testNS = nil
+	})
+
+	It("enslaves the link to a VRF it creates, and deletes it once empty", func() {
+		Expect(nics.SetLinkVRF(mac, "vrf-pn", 100)).To(Succeed())
+
+		vrf, err := handle.LinkByName("vrf-pn")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vrf).To(BeAssignableToTypeOf(&netlink.Vrf{}))
+		Expect(vrf.(*netlink.Vrf).Table).To(Equal(uint32(100)))
+		link, err = handle.LinkByIndex(link.Attrs().Index)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.Attrs().MasterIndex).To(Equal(vrf.Attrs().Index))
+
+		Expect(nics.SetLinkVRF(mac, "vrf-pn", 100)).To(Succeed())
+		Expect(nics.DeleteVRF("vrf-pn")).To(Succeed())
+		_, err = handle.LinkByName("vrf-pn")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(nics.RemoveLinkVRF(mac)).To(Succeed())
+		link, err = handle.LinkByIndex(link.Attrs().Index)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.Attrs().MasterIndex).To(BeZero())
+
+		Expect(nics.DeleteVRF("vrf-pn")).To(Succeed())
+		_, err = handle.LinkByName("vrf-pn")
+		Expect(err).To(BeAssignableToTypeOf(netlink.LinkNotFoundError{}))
+	})
+
+	It("refuses a VRF using another table", func() {
+		Expect(handle.LinkAdd(&netlink.Vrf{LinkAttrs: netlink.LinkAttrs{Name: "vrf-pn"}, Table: 200})).To(Succeed())
+
+		Expect(nics.SetLinkVRF(mac, "vrf-pn", 100)).NotTo(Succeed())
+	})
+
+	It("does not delete a VRF it did not create", func() {
+		Expect(handle.LinkAdd(&netlink.Vrf{LinkAttrs: netlink.LinkAttrs{Name: "vrf-pn"}, Table: 100})).To(Succeed())
+
+		Expect(nics.SetLinkVRF(mac, "vrf-pn", 100)).To(Succeed())
+		Expect(nics.RemoveLinkVRF(mac)).To(Succeed())
+		Expect(nics.DeleteVRF("vrf-pn")).To(Succeed())
+
+		_, err := handle.LinkByName("vrf-pn")
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
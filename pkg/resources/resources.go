@@ -0,0 +1,362 @@
+// Package resources builds and validates the PrivateNetwork and NetworkInterface objects,
+// for the controllers creating them programmatically
+package resources
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/unix"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+	"github.com/Sh4d1/scaleway-k8s-vpc/pkg/nics"
+)
+
+// PrivateNetworkOption configures a PrivateNetwork built by NewPrivateNetwork
+type PrivateNetworkOption func(*vpcv1alpha1.PrivateNetwork)
+
+// WithZone sets the zone of the Scaleway private network
+func WithZone(zone string) PrivateNetworkOption {
+	return func(pn *vpcv1alpha1.PrivateNetwork) {
+		pn.Spec.Zone = zone
+	}
+}
+
+// WithStaticIPAM allocates the addresses of the NetworkInterfaces in the given cidrs,
+// the IPv6 one being optional
+func WithStaticIPAM(cidr, ipv6CIDR string) PrivateNetworkOption {
+	return func(pn *vpcv1alpha1.PrivateNetwork) {
+		pn.Spec.IPAM = &vpcv1alpha1.PrivateNetworkIPAM{
+			Type: vpcv1alpha1.IPAMTypeStatic,
+			Static: &vpcv1alpha1.PrivateNetworkIPAMStatic{
+				CIDR:     cidr,
+				IPv6CIDR: ipv6CIDR,
+			},
+		}
+	}
+}
+
+// WithDHCP gets the addresses of the NetworkInterfaces from a DHCP server of the private network
+func WithDHCP() PrivateNetworkOption {
+	return func(pn *vpcv1alpha1.PrivateNetwork) {
+		pn.Spec.IPAM = &vpcv1alpha1.PrivateNetworkIPAM{
+			Type: vpcv1alpha1.IPAMTypeDHCP,
+		}
+	}
+}
+
+// WithRoute adds a route to the given destination through the given gateway
+func WithRoute(to, via string) PrivateNetworkOption {
+	return func(pn *vpcv1alpha1.PrivateNetwork) {
+		pn.Spec.Routes = append(pn.Spec.Routes, vpcv1alpha1.PrivateNetworkRoute{
+			To:  to,
+			Via: via,
+		})
+	}
+}
+
+// NewPrivateNetwork returns a validated PrivateNetwork attaching the Scaleway private network
+// with the given ID to the nodes
+func NewPrivateNetwork(name, id string, opts ...PrivateNetworkOption) (*vpcv1alpha1.PrivateNetwork, error) {
+	pn := &vpcv1alpha1.PrivateNetwork{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: vpcv1alpha1.GroupVersion.String(),
+			Kind:       "PrivateNetwork",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: vpcv1alpha1.PrivateNetworkSpec{
+			ID:         id,
+			Masquerade: true,
+		},
+	}
+	for _, opt := range opts {
+		opt(pn)
+	}
+
+	err := ValidatePrivateNetwork(pn)
+	if err != nil {
+		return nil, err
+	}
+	return pn, nil
+}
+
+// NewNetworkInterface returns the NetworkInterface attaching the private NIC with the given ID of the node
+// to the PrivateNetwork, labelled and owned by it
+// The scheme needs the PrivateNetwork type registered
+func NewNetworkInterface(pn *vpcv1alpha1.PrivateNetwork, nodeName, nicID string, scheme *runtime.Scheme) (*vpcv1alpha1.NetworkInterface, error) {
+	nic := &vpcv1alpha1.NetworkInterface{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:       make(map[string]string),
+			Annotations:  make(map[string]string),
+			GenerateName: pn.Name + "-",
+		},
+		Spec: vpcv1alpha1.NetworkInterfaceSpec{
+			ID:       nicID,
+			NodeName: nodeName,
+		},
+	}
+	for k, v := range pn.Annotations {
+		nic.Annotations[k] = v
+	}
+	for k, v := range pn.Labels {
+		nic.Labels[k] = v
+	}
+	nic.Labels[constants.PrivateNetworkLabel] = pn.Name
+	nic.Labels[constants.NodeLabel] = nodeName
+	if err := controllerutil.SetControllerReference(pn, nic, scheme); err != nil {
+		return nil, err
+	}
+	controllerutil.AddFinalizer(nic, constants.FinalizerName)
+	controllerutil.AddFinalizer(nic, constants.IPFinalizerName)
+
+	return nic, nil
+}
+
+// SetNetworkMetadata labels the NetworkInterface with the ID of its Scaleway private network and annotates it
+// with its name, returning whether they changed
+func SetNetworkMetadata(nic *vpcv1alpha1.NetworkInterface, id, name string) bool {
+	if nic.Labels[constants.NetworkLabel] == id && nic.Annotations[constants.NetworkNameAnnotation] == name {
+		return false
+	}
+	if nic.Labels == nil {
+		nic.Labels = make(map[string]string)
+	}
+	if nic.Annotations == nil {
+		nic.Annotations = make(map[string]string)
+	}
+	nic.Labels[constants.NetworkLabel] = id
+	nic.Annotations[constants.NetworkNameAnnotation] = name
+	return true
+}
+
+// ValidatePrivateNetwork checks the spec of a PrivateNetwork beyond the validation of its CRD
+func ValidatePrivateNetwork(pn *vpcv1alpha1.PrivateNetwork) error {
+	if pn.Spec.ID == "" {
+		return fmt.Errorf("private network id is required")
+	}
+
+	if pn.Spec.IPAM != nil && pn.Spec.IPAM.Type == vpcv1alpha1.IPAMTypeStatic {
+		static := pn.Spec.IPAM.Static
+		if static == nil {
+			return fmt.Errorf("static IPAM needs a static configuration")
+		}
+		if _, _, err := net.ParseCIDR(static.CIDR); err != nil {
+			return fmt.Errorf("invalid static IPAM cidr %s: %w", static.CIDR, err)
+		}
+		if static.IPv6CIDR != "" {
+			ip, _, err := net.ParseCIDR(static.IPv6CIDR)
+			if err != nil {
+				return fmt.Errorf("invalid static IPAM IPv6 cidr %s: %w", static.IPv6CIDR, err)
+			}
+			if ip.To4() != nil {
+				return fmt.Errorf("static IPAM IPv6 cidr %s is not an IPv6 cidr", static.IPv6CIDR)
+			}
+		}
+	}
+
+	if pn.Spec.IPAM != nil && pn.Spec.IPAM.Type != vpcv1alpha1.IPAMTypeDHCP && pn.Spec.IPAM.DHCP != nil {
+		return fmt.Errorf("DHCP options need the DHCP IPAM")
+	}
+
+	if pn.Spec.RouteRealm < 0 || pn.Spec.RouteRealm > nics.MaxRouteRealm {
+		return fmt.Errorf("route realm %d is out of range, it must be between 0 and %d", pn.Spec.RouteRealm, nics.MaxRouteRealm)
+	}
+
+	for _, route := range pn.Spec.Routes {
+		parsedRoute, err := nics.ParseRoute(route.To, route.Via)
+		if err != nil {
+			return err
+		}
+		if route.Pref != "" && parsedRoute.To.IP.To4() != nil {
+			return fmt.Errorf("route to %s: pref is only supported on IPv6 routes", route.To)
+		}
+		if route.Realm < 0 || route.Realm > nics.MaxRouteRealm {
+			return fmt.Errorf("route to %s: realm %d is out of range, it must be between 0 and %d", route.To, route.Realm, nics.MaxRouteRealm)
+		}
+		if route.Realm != 0 && parsedRoute.To.IP.To4() == nil {
+			return fmt.Errorf("route to %s: realm is only supported on IPv4 routes", route.To)
+		}
+		if route.BackupFor == pn.Name {
+			return fmt.Errorf("route to %s: can't be a backup for its own private network", route.To)
+		}
+	}
+
+	if pn.Spec.VRF != nil && pn.Spec.RouteTable == 0 {
+		return fmt.Errorf("VRF %s needs a route table", pn.Spec.VRF.Name)
+	}
+	if pn.Spec.ConnectedRoutesInTable && pn.Spec.RouteTable == 0 {
+		return fmt.Errorf("connected routes in table need a route table")
+	}
+	if pn.Spec.ConnectedRoutesInTable && pn.Spec.VRF != nil {
+		return fmt.Errorf("connected routes of VRF %s are already in its table", pn.Spec.VRF.Name)
+	}
+	if pn.Spec.NoPrefixRoute && !pn.Spec.ConnectedRoutesInTable {
+		return fmt.Errorf("addresses without prefix route need the connected routes in table")
+	}
+	if pn.Spec.NoPrefixRoute && pn.Spec.IPAM != nil && pn.Spec.IPAM.Type == vpcv1alpha1.IPAMTypeDHCP {
+		return fmt.Errorf("addresses without prefix route are not supported with the DHCP IPAM")
+	}
+
+	if check := pn.Spec.RouteHealthCheck; check != nil {
+		if net.ParseIP(check.Target) == nil {
+			return fmt.Errorf("invalid route health check target %s", check.Target)
+		}
+		if check.Window != nil && (check.Window.Duration < time.Second || check.Window.Duration > vpcv1alpha1.MaxRouteHealthCheckWindow) {
+			return fmt.Errorf("route health check window %s is out of range, it must be between 1s and %s", check.Window.Duration, vpcv1alpha1.MaxRouteHealthCheckWindow)
+		}
+	}
+
+	_, err := ParseRules(pn)
+	return err
+}
+
+// NetworkInterfacePrivateNetwork returns the name of the PrivateNetwork of a NetworkInterface, from its controller
+// owner reference or its private-network label, which must agree when both are set
+func NetworkInterfacePrivateNetwork(nic *vpcv1alpha1.NetworkInterface) (string, error) {
+	label := nic.Labels[constants.PrivateNetworkLabel]
+	owner := metav1.GetControllerOf(nic)
+	if owner == nil {
+		if label == "" {
+			return "", fmt.Errorf("no private network owner reference nor %s label", constants.PrivateNetworkLabel)
+		}
+		return label, nil
+	}
+	if owner.Kind != "PrivateNetwork" || owner.APIVersion != vpcv1alpha1.GroupVersion.String() {
+		return "", fmt.Errorf("controller owner %s %s is not a private network", owner.Kind, owner.Name)
+	}
+	if label != "" && label != owner.Name {
+		return "", fmt.Errorf("%s label %s doesn't match owner private network %s", constants.PrivateNetworkLabel, label, owner.Name)
+	}
+	return owner.Name, nil
+}
+
+// ValidateNetworkInterface checks the spec of a NetworkInterface attached to the given PrivateNetwork
+// beyond the validation of its CRD
+func ValidateNetworkInterface(nic *vpcv1alpha1.NetworkInterface, pn *vpcv1alpha1.PrivateNetwork) error {
+	if nic.Spec.ID == "" {
+		return fmt.Errorf("nic id is required")
+	}
+	if nic.Spec.NodeName == "" {
+		return fmt.Errorf("node name is required")
+	}
+	if nodeLabel, ok := nic.Labels[constants.NodeLabel]; ok && nodeLabel != nic.Spec.NodeName {
+		return fmt.Errorf("%s label %s doesn't match node name %s", constants.NodeLabel, nodeLabel, nic.Spec.NodeName)
+	}
+
+	if nic.Spec.SNAT != nil {
+		if _, _, err := net.ParseCIDR(nic.Spec.SNAT.SourceCIDR); err != nil {
+			return fmt.Errorf("invalid snat source cidr %s: %w", nic.Spec.SNAT.SourceCIDR, err)
+		}
+	}
+
+	if nic.Spec.IngressRateLimit != nil {
+		if err := ValidateRateLimit(nic.Spec.IngressRateLimit); err != nil {
+			return fmt.Errorf("invalid ingress rate limit: %w", err)
+		}
+	}
+
+	// the address referenced by addressFrom is resolved in the status, the address written in the spec
+	// by the previous versions of the controller is ignored
+	if nic.Spec.AddressFrom != nil {
+		if pn.Spec.IPAM != nil {
+			return fmt.Errorf("addressFrom is only supported on private networks without IPAM")
+		}
+		return nil
+	}
+	if nic.Spec.Address == "" {
+		return nil
+	}
+	ip, _, err := net.ParseCIDR(nic.Spec.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", nic.Spec.Address, err)
+	}
+	cidrs := []string{pn.Spec.CIDR}
+	if pn.Spec.IPAM != nil && pn.Spec.IPAM.Static != nil {
+		cidrs = append(cidrs, pn.Spec.IPAM.Static.CIDR, pn.Spec.IPAM.Static.IPv6CIDR)
+	}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil || (ipNet.IP.To4() == nil) != (ip.To4() == nil) {
+			continue
+		}
+		if !ipNet.Contains(ip) {
+			return fmt.Errorf("address %s is not in the private network cidr %s", nic.Spec.Address, cidr)
+		}
+	}
+	return nil
+}
+
+// ValidateRateLimit checks the rate of a rate limit is positive, as well as its burst when set
+func ValidateRateLimit(limit *vpcv1alpha1.RateLimit) error {
+	if limit.Rate.Value() <= 0 {
+		return fmt.Errorf("rate %s must be positive", limit.Rate.String())
+	}
+	if limit.Burst != nil && limit.Burst.Value() <= 0 {
+		return fmt.Errorf("burst %s must be positive", limit.Burst.String())
+	}
+	return nil
+}
+
+// ParseRules parses and validates the policy routing rules of a PrivateNetwork
+func ParseRules(pn *vpcv1alpha1.PrivateNetwork) ([]nics.Rule, error) {
+	if len(pn.Spec.Rules) != 0 && pn.Spec.RouteTable == 0 {
+		return nil, fmt.Errorf("rules need a route table")
+	}
+	if pn.Spec.RouteTable >= unix.RT_TABLE_DEFAULT {
+		return nil, fmt.Errorf("route table %d is reserved", pn.Spec.RouteTable)
+	}
+
+	rules := []nics.Rule{}
+	for _, rule := range pn.Spec.Rules {
+		parsedRule := nics.Rule{
+			Priority: int(rule.Priority),
+		}
+		if rule.From != "" {
+			_, from, err := net.ParseCIDR(rule.From)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule source %s: %w", rule.From, err)
+			}
+			parsedRule.Src = from
+		}
+		if rule.To != "" {
+			_, to, err := net.ParseCIDR(rule.To)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule destination %s: %w", rule.To, err)
+			}
+			parsedRule.Dst = to
+		}
+		if parsedRule.Src != nil && parsedRule.Dst != nil && (parsedRule.Src.IP.To4() == nil) != (parsedRule.Dst.IP.To4() == nil) {
+			return nil, fmt.Errorf("rule source %s and destination %s are not of the same family", rule.From, rule.To)
+		}
+		rules = append(rules, parsedRule)
+	}
+	return rules, nil
+}
+
+// RouteTableConflicts returns the names of the PrivateNetworks among others using the route table of pn
+// without both of them allowing it to be shared
+func RouteTableConflicts(pn *vpcv1alpha1.PrivateNetwork, others []vpcv1alpha1.PrivateNetwork) []string {
+	conflicts := []string{}
+	if pn.Spec.RouteTable == 0 {
+		return conflicts
+	}
+	for _, other := range others {
+		if other.Name == pn.Name || other.Spec.RouteTable != pn.Spec.RouteTable {
+			continue
+		}
+		if pn.Annotations[constants.AllowSharedRouteTableAnnotation] != "true" ||
+			other.Annotations[constants.AllowSharedRouteTableAnnotation] != "true" {
+			conflicts = append(conflicts, other.Name)
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
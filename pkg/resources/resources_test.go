@@ -0,0 +1,358 @@
+package resources
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpcv1alpha1 "github.com/Sh4d1/scaleway-k8s-vpc/api/v1alpha1"
+	"github.com/Sh4d1/scaleway-k8s-vpc/internal/constants"
+)
+
+var _ = Describe("PrivateNetwork building", func() {
+	It("builds a valid private network", func() {
+		pn, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111",
+			WithZone("fr-par-1"),
+			WithStaticIPAM("192.168.0.0/24", "fd00::/64"),
+			WithRoute("10.0.0.0/16", "192.168.0.1"),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pn.Spec.Zone).To(Equal("fr-par-1"))
+		Expect(pn.Spec.IPAM.Static.IPv6CIDR).To(Equal("fd00::/64"))
+		Expect(pn.Spec.Routes).To(HaveLen(1))
+	})
+
+	It("rejects invalid private networks", func() {
+		for _, opts := range [][]PrivateNetworkOption{
+			{WithStaticIPAM("192.168.0.0", "")},
+			{WithStaticIPAM("192.168.0.0/24", "10.0.0.0/16")},
+			{WithDHCP(), WithRoute("10.0.0.0/16", "fd00::1")},
+			{WithStaticIPAM("192.168.0.0/24", ""), func(pn *vpcv1alpha1.PrivateNetwork) {
+				pn.Spec.IPAM.DHCP = &vpcv1alpha1.PrivateNetworkIPAMDHCP{ApplyMTU: true}
+			}},
+		} {
+			_, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", opts...)
+			Expect(err).To(HaveOccurred())
+		}
+
+		_, err := NewPrivateNetwork("pn", "", WithDHCP())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("only accepts a route preference on IPv6 routes", func() {
+		withPref := func(to, via string, pref vpcv1alpha1.RoutePreference) PrivateNetworkOption {
+			return func(pn *vpcv1alpha1.PrivateNetwork) {
+				pn.Spec.Routes = append(pn.Spec.Routes, vpcv1alpha1.PrivateNetworkRoute{To: to, Via: via, Pref: pref})
+			}
+		}
+
+		_, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(),
+			withPref("fd01::/64", "fd00::1", vpcv1alpha1.RoutePreferenceHigh))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(),
+			withPref("10.0.0.0/16", "192.168.0.1", vpcv1alpha1.RoutePreferenceHigh))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("only accepts a route realm in range on IPv4 routes", func() {
+		withRealm := func(to, via string, realm int32) PrivateNetworkOption {
+			return func(pn *vpcv1alpha1.PrivateNetwork) {
+				pn.Spec.Routes = append(pn.Spec.Routes, vpcv1alpha1.PrivateNetworkRoute{To: to, Via: via, Realm: realm})
+			}
+		}
+
+		_, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(),
+			withRealm("10.0.0.0/16", "192.168.0.1", 10))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(),
+			withRealm("10.0.0.0/16", "192.168.0.1", 256))
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(),
+			withRealm("fd01::/64", "fd00::1", 10))
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(),
+			func(pn *vpcv1alpha1.PrivateNetwork) { pn.Spec.RouteRealm = -1 })
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects a VRF without a route table", func() {
+		withVRF := func(table int32) PrivateNetworkOption {
+			return func(pn *vpcv1alpha1.PrivateNetwork) {
+				pn.Spec.VRF = &vpcv1alpha1.PrivateNetworkVRF{Name: "vrf-pn"}
+				pn.Spec.RouteTable = table
+			}
+		}
+
+		_, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withVRF(100))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withVRF(0))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects connected routes in table without a route table or with a VRF", func() {
+		withConnectedRoutes := func(table int32, vrf *vpcv1alpha1.PrivateNetworkVRF) PrivateNetworkOption {
+			return func(pn *vpcv1alpha1.PrivateNetwork) {
+				pn.Spec.ConnectedRoutesInTable = true
+				pn.Spec.RouteTable = table
+				pn.Spec.VRF = vrf
+			}
+		}
+
+		_, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withConnectedRoutes(100, nil))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withConnectedRoutes(0, nil))
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(),
+			withConnectedRoutes(100, &vpcv1alpha1.PrivateNetworkVRF{Name: "vrf-pn"}))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects addresses without prefix route without connected routes in table or with DHCP", func() {
+		withNoPrefixRoute := func(connectedRoutesInTable bool) PrivateNetworkOption {
+			return func(pn *vpcv1alpha1.PrivateNetwork) {
+				pn.Spec.NoPrefixRoute = true
+				pn.Spec.ConnectedRoutesInTable = connectedRoutesInTable
+				pn.Spec.RouteTable = 100
+			}
+		}
+
+		_, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithStaticIPAM("192.168.0.0/24", ""), withNoPrefixRoute(true))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithStaticIPAM("192.168.0.0/24", ""), withNoPrefixRoute(false))
+		Expect(err).To(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withNoPrefixRoute(true))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects a route backing up its own private network", func() {
+		_, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(),
+			func(pn *vpcv1alpha1.PrivateNetwork) {
+				pn.Spec.Routes = append(pn.Spec.Routes, vpcv1alpha1.PrivateNetworkRoute{To: "10.0.0.0/16", Via: "192.168.0.1", BackupFor: "pn"})
+			})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("checks the route health check target and window", func() {
+		withRouteHealthCheck := func(target string, window time.Duration) PrivateNetworkOption {
+			return func(pn *vpcv1alpha1.PrivateNetwork) {
+				pn.Spec.RouteHealthCheck = &vpcv1alpha1.RouteHealthCheck{Target: target}
+				if window != 0 {
+					pn.Spec.RouteHealthCheck.Window = &metav1.Duration{Duration: window}
+				}
+			}
+		}
+
+		_, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withRouteHealthCheck("10.0.0.1", 0))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withRouteHealthCheck("fd00::1", 30*time.Second))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withRouteHealthCheck("10.0.0.0/24", 0))
+		Expect(err).To(HaveOccurred())
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withRouteHealthCheck("10.0.0.1", 500*time.Millisecond))
+		Expect(err).To(HaveOccurred())
+		_, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP(), withRouteHealthCheck("10.0.0.1", 2*time.Minute))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("NetworkInterface building", func() {
+	It("labels the network interface and sets its owner", func() {
+		scheme := runtime.NewScheme()
+		Expect(vpcv1alpha1.AddToScheme(scheme)).To(Succeed())
+		pn, err := NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithDHCP())
+		Expect(err).NotTo(HaveOccurred())
+		pn.Labels = map[string]string{"team": "a"}
+
+		nic, err := NewNetworkInterface(pn, "node-1", "22222222-2222-2222-2222-222222222222", scheme)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nic.GenerateName).To(Equal("pn-"))
+		Expect(nic.Spec.ID).To(Equal("22222222-2222-2222-2222-222222222222"))
+		Expect(nic.Labels).To(Equal(map[string]string{
+			"team":                        "a",
+			constants.PrivateNetworkLabel: "pn",
+			constants.NodeLabel:           "node-1",
+		}))
+		Expect(nic.OwnerReferences).To(HaveLen(1))
+		Expect(nic.OwnerReferences[0].Name).To(Equal("pn"))
+		Expect(controllerutil.ContainsFinalizer(nic, constants.FinalizerName)).To(BeTrue())
+		Expect(controllerutil.ContainsFinalizer(nic, constants.IPFinalizerName)).To(BeTrue())
+	})
+
+	It("stamps the Scaleway private network and reports the changes", func() {
+		nic := &vpcv1alpha1.NetworkInterface{}
+		Expect(SetNetworkMetadata(nic, "11111111-1111-1111-1111-111111111111", "pn")).To(BeTrue())
+		Expect(nic.Labels).To(HaveKeyWithValue(constants.NetworkLabel, "11111111-1111-1111-1111-111111111111"))
+		Expect(nic.Annotations).To(HaveKeyWithValue(constants.NetworkNameAnnotation, "pn"))
+
+		Expect(SetNetworkMetadata(nic, "11111111-1111-1111-1111-111111111111", "pn")).To(BeFalse())
+		Expect(SetNetworkMetadata(nic, "11111111-1111-1111-1111-111111111111", "renamed")).To(BeTrue())
+		Expect(nic.Annotations).To(HaveKeyWithValue(constants.NetworkNameAnnotation, "renamed"))
+	})
+})
+
+var _ = Describe("NetworkInterface validation", func() {
+	var (
+		pn  *vpcv1alpha1.PrivateNetwork
+		nic *vpcv1alpha1.NetworkInterface
+	)
+
+	BeforeEach(func() {
+		var err error
+		pn, err = NewPrivateNetwork("pn", "11111111-1111-1111-1111-111111111111", WithStaticIPAM("192.168.0.0/24", ""))
+		Expect(err).NotTo(HaveOccurred())
+		scheme := runtime.NewScheme()
+		Expect(vpcv1alpha1.AddToScheme(scheme)).To(Succeed())
+		nic, err = NewNetworkInterface(pn, "node-1", "22222222-2222-2222-2222-222222222222", scheme)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("resolves the private network from the owner reference or the label", func() {
+		Expect(NetworkInterfacePrivateNetwork(nic)).To(Equal("pn"))
+
+		nic.OwnerReferences = nil
+		Expect(NetworkInterfacePrivateNetwork(nic)).To(Equal("pn"))
+
+		delete(nic.Labels, constants.PrivateNetworkLabel)
+		_, err := NetworkInterfacePrivateNetwork(nic)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects an owner reference not matching the label", func() {
+		nic.Labels[constants.PrivateNetworkLabel] = "other"
+		_, err := NetworkInterfacePrivateNetwork(nic)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("checks the address is in the private network cidr", func() {
+		nic.Spec.Address = "192.168.0.10/24"
+		Expect(ValidateNetworkInterface(nic, pn)).To(Succeed())
+
+		nic.Spec.Address = "192.168.1.10/24"
+		Expect(ValidateNetworkInterface(nic, pn)).NotTo(Succeed())
+
+		nic.Spec.Address = "192.168.0.10"
+		Expect(ValidateNetworkInterface(nic, pn)).NotTo(Succeed())
+	})
+
+	It("rejects a node label not matching the node name", func() {
+		Expect(ValidateNetworkInterface(nic, pn)).To(Succeed())
+		nic.Labels[constants.NodeLabel] = "node-2"
+		Expect(ValidateNetworkInterface(nic, pn)).NotTo(Succeed())
+	})
+
+	It("needs a valid snat source range", func() {
+		nic.Spec.SNAT = &vpcv1alpha1.SNAT{SourceCIDR: "10.1.0.0/16"}
+		Expect(ValidateNetworkInterface(nic, pn)).To(Succeed())
+		nic.Spec.SNAT.SourceCIDR = ""
+		Expect(ValidateNetworkInterface(nic, pn)).NotTo(Succeed())
+	})
+
+	It("needs a positive ingress rate and burst", func() {
+		burst := resource.MustParse("64Ki")
+		nic.Spec.IngressRateLimit = &vpcv1alpha1.RateLimit{Rate: resource.MustParse("100M"), Burst: &burst}
+		Expect(ValidateNetworkInterface(nic, pn)).To(Succeed())
+
+		for _, rate := range []string{"0", "-100M"} {
+			nic.Spec.IngressRateLimit.Rate = resource.MustParse(rate)
+			Expect(ValidateNetworkInterface(nic, pn)).NotTo(Succeed(), rate)
+		}
+
+		nic.Spec.IngressRateLimit.Rate = resource.MustParse("100M")
+		for _, invalid := range []string{"0", "-1Ki"} {
+			burst := resource.MustParse(invalid)
+			nic.Spec.IngressRateLimit.Burst = &burst
+			Expect(ValidateNetworkInterface(nic, pn)).NotTo(Succeed(), invalid)
+		}
+	})
+
+	It("only accepts an address reference without IPAM", func() {
+		nic.Spec.AddressFrom = &vpcv1alpha1.AddressSource{}
+		Expect(ValidateNetworkInterface(nic, pn)).NotTo(Succeed())
+
+		pn.Spec.IPAM = nil
+		Expect(ValidateNetworkInterface(nic, pn)).To(Succeed())
+		// exported from a cluster where the address was resolved in the spec
+		nic.Spec.Address = "192.168.0.10/24"
+		Expect(ValidateNetworkInterface(nic, pn)).To(Succeed())
+	})
+})
+
+var _ = Describe("Policy routing rules", func() {
+	It("parses the rules of a private network", func() {
+		rules, err := ParseRules(&vpcv1alpha1.PrivateNetwork{
+			Spec: vpcv1alpha1.PrivateNetworkSpec{
+				RouteTable: 100,
+				Rules: []vpcv1alpha1.PrivateNetworkRule{
+					{From: "192.168.0.0/24", Priority: 1000},
+					{To: "fd00::/64", Priority: 1001},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules).To(HaveLen(2))
+		Expect(rules[0].Priority).To(Equal(1000))
+		Expect(rules[0].Src.String()).To(Equal("192.168.0.0/24"))
+		Expect(rules[0].Dst).To(BeNil())
+		Expect(rules[1].Dst.String()).To(Equal("fd00::/64"))
+	})
+
+	It("rejects invalid rules", func() {
+		for _, spec := range []vpcv1alpha1.PrivateNetworkSpec{
+			{Rules: []vpcv1alpha1.PrivateNetworkRule{{From: "192.168.0.0/24", Priority: 1000}}},
+			{RouteTable: 254},
+			{RouteTable: 100, Rules: []vpcv1alpha1.PrivateNetworkRule{{From: "192.168.0.0", Priority: 1000}}},
+			{RouteTable: 100, Rules: []vpcv1alpha1.PrivateNetworkRule{{From: "192.168.0.0/24", To: "fd00::/64", Priority: 1000}}},
+		} {
+			_, err := ParseRules(&vpcv1alpha1.PrivateNetwork{Spec: spec})
+			Expect(err).To(HaveOccurred())
+		}
+	})
+})
+
+var _ = Describe("Route table conflicts", func() {
+	pn := func(name string, table int32, shared bool) vpcv1alpha1.PrivateNetwork {
+		pn := vpcv1alpha1.PrivateNetwork{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{}},
+			Spec:       vpcv1alpha1.PrivateNetworkSpec{RouteTable: table},
+		}
+		if shared {
+			pn.Annotations[constants.AllowSharedRouteTableAnnotation] = "true"
+		}
+		return pn
+	}
+
+	It("reports the private networks using the same route table", func() {
+		a := pn("pn-a", 100, false)
+		Expect(RouteTableConflicts(&a, []vpcv1alpha1.PrivateNetwork{
+			a,
+			pn("pn-c", 100, true),
+			pn("pn-b", 100, false),
+			pn("pn-d", 101, false),
+		})).To(Equal([]string{"pn-b", "pn-c"}))
+	})
+
+	It("allows sharing a route table when all the private networks allow it", func() {
+		a := pn("pn-a", 100, true)
+		Expect(RouteTableConflicts(&a, []vpcv1alpha1.PrivateNetwork{pn("pn-b", 100, true)})).To(BeEmpty())
+	})
+
+	It("never reports the main table", func() {
+		a := pn("pn-a", 0, false)
+		Expect(RouteTableConflicts(&a, []vpcv1alpha1.PrivateNetwork{pn("pn-b", 0, false)})).To(BeEmpty())
+	})
+})